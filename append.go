@@ -0,0 +1,190 @@
+package goeql
+
+// Append style encoding of the EncryptedColumn envelope.
+//
+// AppendEncrypted writes the {"k","p","i","v","q"} envelope straight into a
+// caller supplied buffer, producing the same bytes as marshaling the
+// EncryptedColumn returned by ToEncryptedColumn with encoding/json, without
+// building the struct or allocating intermediate strings. Payloads take the
+// shape of the column's EQL version, see version.go.
+
+import (
+	"context"
+	"strconv"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// AppendEncrypted appends the EQL payload for a plaintext string to dst and returns
+// the extended buffer. An empty queryType is encoded as a null "q".
+func AppendEncrypted(dst []byte, plaintext string, table string, column string, queryType string) []byte {
+	call := startHooks(context.Background(), encodeOperation(queryType), table, column, queryType)
+	start := len(dst)
+	dst = append(dst, `{"k":"pt","p":`...)
+	dst = appendJSONString(dst, plaintext)
+	dst = appendEnvelopeSuffix(dst, table, column, queryType, columnKeyset(table, column), columnVersion(table, column))
+	call.end(len(dst)-start, nil)
+	return dst
+}
+
+// AppendEncryptedValue appends the EQL payload for value to dst, converting it to a
+// plaintext string with the same rules as ToEncryptedColumn
+func AppendEncryptedValue(dst []byte, value any, table string, column string, queryType string) ([]byte, error) {
+	call := startHooks(context.Background(), encodeOperation(queryType), table, column, queryType)
+	start := len(dst)
+	dst, err := appendEncryptedValue(dst, value, table, column, queryType, columnKeyset(table, column), columnVersion(table, column))
+	call.end(len(dst)-start, err)
+	return dst, err
+}
+
+// AppendEncryptedContext is AppendEncrypted using the keyset and EQL version from ctx.
+// It returns dst unchanged if the keyset or version in ctx is invalid.
+func AppendEncryptedContext(ctx context.Context, dst []byte, plaintext string, table string, column string, queryType string) ([]byte, error) {
+	return AppendEncryptedValueContext(ctx, dst, plaintext, table, column, queryType)
+}
+
+// AppendEncryptedValueContext is AppendEncryptedValue using the keyset and EQL version from ctx
+func AppendEncryptedValueContext(ctx context.Context, dst []byte, value any, table string, column string, queryType string) (_ []byte, err error) {
+	call := startHooks(ctx, encodeOperation(queryType), table, column, queryType)
+	start := len(dst)
+	defer func() { call.end(len(dst)-start, err) }()
+
+	keyset, err := payloadKeyset(ctx, table, column)
+	if err != nil {
+		return dst, err
+	}
+	version, err := payloadVersion(ctx, table, column)
+	if err != nil {
+		return dst, err
+	}
+	dst, err = appendEncryptedValue(dst, value, table, column, queryType, keyset, version)
+	return dst, err
+}
+
+func appendEncryptedValue(dst []byte, value any, table string, column string, queryType string, keyset *Keyset, version EQLVersion) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, `{"k":"pt","p":`...)
+	dst, err := appendPlaintext(dst, value)
+	if err != nil {
+		return dst[:start], err
+	}
+	return appendEnvelopeSuffix(dst, table, column, queryType, keyset, version), nil
+}
+
+func appendEnvelopeSuffix(dst []byte, table string, column string, queryType string, keyset *Keyset, version EQLVersion) []byte {
+	dst = append(dst, `,"i":{"t":`...)
+	dst = appendJSONString(dst, table)
+	dst = append(dst, `,"c":`...)
+	dst = appendJSONString(dst, column)
+	dst = append(dst, `},"v":`...)
+	dst = strconv.AppendInt(dst, int64(version), 10)
+	switch {
+	case queryType != "":
+		dst = append(dst, `,"q":`...)
+		dst = appendJSONString(dst, queryType)
+	case version == EQLv1:
+		// v1 payloads send a null "q" for stored values, later versions omit it
+		dst = append(dst, `,"q":null`...)
+	}
+	if keyset != nil {
+		dst = append(dst, `,"ks":`...)
+		dst = keyset.appendJSON(dst)
+	}
+	return append(dst, '}')
+}
+
+// appendPlaintext appends value as a JSON string, formatting common scalar types
+// directly into dst and falling back to convertToString for everything else
+func appendPlaintext(dst []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return appendJSONString(dst, v), nil
+	case bool:
+		return appendQuoted(strconv.AppendBool(append(dst, '"'), v)), nil
+	case int:
+		return appendQuoted(strconv.AppendInt(append(dst, '"'), int64(v), 10)), nil
+	case int64:
+		return appendQuoted(strconv.AppendInt(append(dst, '"'), v, 10)), nil
+	case int32:
+		return appendQuoted(strconv.AppendInt(append(dst, '"'), int64(v), 10)), nil
+	case uint64:
+		return appendQuoted(strconv.AppendUint(append(dst, '"'), v, 10)), nil
+	case float64:
+		return appendQuoted(strconv.AppendFloat(append(dst, '"'), v, 'f', 6, 64)), nil
+	case float32:
+		return appendQuoted(strconv.AppendFloat(append(dst, '"'), float64(v), 'f', 6, 32)), nil
+	}
+
+	str, err := convertToString(value)
+	if err != nil {
+		return dst, err
+	}
+	return appendJSONString(dst, str), nil
+}
+
+func appendQuoted(dst []byte) []byte {
+	return append(dst, '"')
+}
+
+// appendJSONString appends s as a JSON string using the same escaping as encoding/json,
+// including HTML characters, U+2028 and U+2029, and replacing invalid UTF-8
+func appendJSONString[T ~string | ~[]byte](dst []byte, s T) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := decodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// decodeRune decodes the first rune of s, which may be a string or a byte slice,
+// without converting s and so without copying plaintext to the heap
+func decodeRune[T ~string | ~[]byte](s T) (rune, int) {
+	var buf [utf8.UTFMax]byte
+	n := copy(buf[:], s)
+	return utf8.DecodeRune(buf[:n])
+}
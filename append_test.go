@@ -0,0 +1,130 @@
+package goeql
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+var appendTestValues = []any{
+	"Hello, World!",
+	"",
+	`"quoted" \ backslash`,
+	"<script>alert('x')</script> & more",
+	"line\nbreak\ttab\rreturn\bback\fform",
+	"\x00\x01\x1f\x7f",
+	"line separator \u2028 paragraph \u2029",
+	"invalid \xff\xfe utf8",
+	"unicode \u4f60\u597d \U0001f510",
+	123,
+	int8(-128),
+	int64(-9223372036854775808),
+	uint64(18446744073709551615),
+	123.456,
+	float32(123.456),
+	true,
+	false,
+	map[string]any{"key": "<value>"},
+	[]string{"a", "b"},
+}
+
+// Test AppendEncryptedValue matches ToEncryptedColumn and json.Marshal
+func TestAppendEncryptedValue(t *testing.T) {
+	for _, queryType := range []string{"", "match", "ore"} {
+		for _, value := range appendTestValues {
+			var q any
+			if queryType != "" {
+				q = queryType
+			}
+			ec, err := ToEncryptedColumn(value, "test<table>", `test"column`, q)
+			if err != nil {
+				t.Fatalf("ToEncryptedColumn returned error: %v", err)
+			}
+			expected, err := json.Marshal(ec)
+			if err != nil {
+				t.Fatalf("Error marshaling EncryptedColumn: %v", err)
+			}
+
+			got, err := AppendEncryptedValue(nil, value, "test<table>", `test"column`, queryType)
+			if err != nil {
+				t.Fatalf("AppendEncryptedValue returned error: %v", err)
+			}
+			if !bytes.Equal(got, expected) {
+				t.Errorf("Expected %s, got %s for value %#v", expected, got, value)
+			}
+		}
+	}
+}
+
+// Test AppendEncrypted appends to an existing buffer
+func TestAppendEncrypted(t *testing.T) {
+	dst := []byte("prefix:")
+	dst = AppendEncrypted(dst, "secret", "users", "email", "unique")
+
+	expected := `prefix:{"k":"pt","p":"secret","i":{"t":"users","c":"email"},"v":1,"q":"unique"}`
+	if string(dst) != expected {
+		t.Errorf("Expected %s, got %s", expected, dst)
+	}
+}
+
+// Test AppendEncryptedValue leaves the buffer unchanged on error
+func TestAppendEncryptedValue_Error(t *testing.T) {
+	dst := []byte("prefix:")
+	dst, err := AppendEncryptedValue(dst, struct{}{}, "users", "email", "")
+	if err == nil {
+		t.Errorf("Expected error for unsupported type, but got none")
+	}
+	if string(dst) != "prefix:" {
+		t.Errorf("Expected buffer to be unchanged, got %s", dst)
+	}
+}
+
+// Test AppendEncrypted does not allocate when the buffer has capacity
+func TestAppendEncrypted_Allocs(t *testing.T) {
+	buf := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = AppendEncrypted(buf[:0], "alice@example.com", "users", "email", "match")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkAppendEncrypted(b *testing.B) {
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = AppendEncrypted(buf[:0], "alice@example.com", "users", "email", "match")
+	}
+}
+
+func BenchmarkAppendEncryptedValue_Int(b *testing.B) {
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendEncryptedValue(buf[:0], 1234567, "users", "age", "ore")
+	}
+}
+
+func BenchmarkToEncryptedColumnMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ec, _ := ToEncryptedColumn("alice@example.com", "users", "email", "match")
+		_, _ = json.Marshal(ec)
+	}
+}
+
+func BenchmarkToEncryptedColumnMarshal_Int(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ec, _ := ToEncryptedColumn(1234567, "users", "age", "ore")
+		_, _ = json.Marshal(ec)
+	}
+}
+
+func BenchmarkMatchQuery(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = MatchQuery("alice@example.com", "users", "email")
+	}
+}
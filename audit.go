@@ -0,0 +1,143 @@
+package goeql
+
+// Audit trail of encrypted column access.
+//
+// AuditHook is a Hook that records an AuditEvent for every decrypt and query
+// call to an AuditSink, identifying the principal that made the call from the
+// context, or the hook's default principal for calls without a context such
+// as Deserialize. Failed calls record the class of the error, as error messages
+// can quote the plaintext. JSONLSink writes events as JSON lines to a writer or file.
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEvent records an access to an encrypted column
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	Table     string    `json:"table"`
+	Column    string    `json:"column"`
+	QueryType string    `json:"query_type,omitempty"`
+	Principal string    `json:"principal,omitempty"`
+	// Error is the class of the error returned by a failed call, see ErrorType
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink stores audit events. Sinks must be safe for concurrent use.
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event AuditEvent) error
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal recorded in audit events
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, if any
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(string)
+	return principal, ok
+}
+
+// AuditHook is a Hook that records decrypt and query events to a sink
+type AuditHook struct {
+	Sink AuditSink
+	// Principal is recorded when the context does not carry one, e.g. the service name
+	Principal string
+	// OnError is optional, and is called when the sink fails to write an event
+	OnError func(error)
+
+	now func() time.Time
+}
+
+// NewAuditHook returns an AuditHook writing to sink, recording principal for calls
+// whose context does not carry one
+func NewAuditHook(sink AuditSink, principal string) *AuditHook {
+	return &AuditHook{Sink: sink, Principal: principal}
+}
+
+// Before returns ctx unchanged
+func (h *AuditHook) Before(ctx context.Context, event Event) context.Context {
+	return ctx
+}
+
+// After records decrypt and query events
+func (h *AuditHook) After(ctx context.Context, event Event) {
+	if event.Operation != OperationDecrypt && event.Operation != OperationQuery {
+		return
+	}
+
+	now := time.Now
+	if h.now != nil {
+		now = h.now
+	}
+	audit := AuditEvent{
+		Time:      now().UTC(),
+		Operation: event.Operation,
+		Table:     event.Table,
+		Column:    event.Column,
+		QueryType: event.QueryType,
+		Principal: h.Principal,
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		audit.Principal = principal
+	}
+	if event.Err != nil {
+		audit.Error = ErrorType(event.Err)
+	}
+
+	if err := h.Sink.WriteAuditEvent(ctx, audit); err != nil && h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// JSONLSink is an AuditSink that writes each event as a line of JSON
+type JSONLSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLSink returns a JSONLSink writing to w
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: w}
+}
+
+// OpenJSONLFile returns a JSONLSink appending to the file at path, creating it if it does not exist
+func OpenJSONLFile(path string) (*JSONLSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{w: f, closer: f}, nil
+}
+
+// WriteAuditEvent writes the event as a single line
+func (s *JSONLSink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the file opened by OpenJSONLFile, and does nothing for other writers
+func (s *JSONLSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
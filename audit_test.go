@@ -0,0 +1,161 @@
+package goeql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	events []AuditEvent
+	err    error
+}
+
+func (s *memorySink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func useAuditHook(t *testing.T, sink AuditSink) *AuditHook {
+	hook := NewAuditHook(sink, "billing-service")
+	hook.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	UseHooks(hook)
+	t.Cleanup(func() { UseHooks() })
+	return hook
+}
+
+// Test decrypt and query calls are audited, and encrypt calls are not
+func TestAuditHook_Events(t *testing.T) {
+	sink := &memorySink{}
+	useAuditHook(t, sink)
+
+	data, err := EncryptedText("alice@example.com").Serialize("users", "email")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	var et EncryptedText
+	if _, err := et.Deserialize(data); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	ctx := WithPrincipal(context.Background(), "user:42")
+	if _, err := MatchQueryContext(ctx, "alice", "users", "email"); err != nil {
+		t.Fatalf("MatchQueryContext returned error: %v", err)
+	}
+	if _, err := NewColumn("users", "email").DecodeContext(ctx, data); err != nil {
+		t.Fatalf("DecodeContext returned error: %v", err)
+	}
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expected := []AuditEvent{
+		{Time: at, Operation: OperationDecrypt, Table: "users", Column: "email", Principal: "billing-service"},
+		{Time: at, Operation: OperationQuery, Table: "users", Column: "email", QueryType: "match", Principal: "user:42"},
+		{Time: at, Operation: OperationDecrypt, Table: "users", Column: "email", Principal: "user:42"},
+	}
+	if len(sink.events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(sink.events), sink.events)
+	}
+	for i, event := range sink.events {
+		if event != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], event)
+		}
+	}
+}
+
+// Test failed calls record the error, and sink errors are reported
+func TestAuditHook_Errors(t *testing.T) {
+	sink := &memorySink{err: errors.New("disk full")}
+	hook := useAuditHook(t, sink)
+	var sinkErr error
+	hook.OnError = func(err error) { sinkErr = err }
+
+	var eb EncryptedBool
+	if _, err := eb.Deserialize([]byte(`{"k":"pt","p":"maybe","i":{"t":"users","c":"active"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected error for invalid boolean")
+	}
+
+	if len(sink.events) != 1 || sink.events[0].Error != "*errors.errorString" {
+		t.Fatalf("Expected an event recording the error type, got %+v", sink.events)
+	}
+	if sinkErr == nil || sinkErr.Error() != "disk full" {
+		t.Errorf("Expected the sink error to be reported, got %v", sinkErr)
+	}
+}
+
+// Test failed calls record the class of the error, and never the plaintext or selector
+// quoted by its message
+func TestAuditHook_ErrorPlaintext(t *testing.T) {
+	var buf bytes.Buffer
+	useAuditHook(t, NewJSONLSink(&buf))
+
+	selector := "$.ssn_123-45-6789.x["
+	if _, err := NewColumn("users", "profile").Query("123-45-6789", EJsonPathDescriptor{Selector: selector, Op: OpGt}); err == nil {
+		t.Fatalf("Expected error for invalid selector")
+	}
+	var eb EncryptedBool
+	if _, err := eb.Deserialize([]byte(`{"k":"pt","p":"ssn 123-45-6789","i":{"t":"users","c":"active"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected error for invalid boolean")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 events, got %q", buf.String())
+	}
+	for _, line := range lines {
+		var event AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Unmarshal returned error: %v", err)
+		}
+		if event.Error == "" {
+			t.Errorf("Expected the error type to be recorded, got %s", line)
+		}
+		if strings.Contains(line, "123-45-6789") || strings.Contains(line, "ssn") {
+			t.Errorf("Expected no plaintext or selector in the audit log, got %s", line)
+		}
+	}
+}
+
+// Test the JSONL file sink appends one event per line
+func TestJSONLSink_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := OpenJSONLFile(path)
+		if err != nil {
+			t.Fatalf("OpenJSONLFile returned error: %v", err)
+		}
+		event := AuditEvent{Operation: OperationQuery, Table: "users", Column: "email", QueryType: "unique", Principal: "billing-service"}
+		if err := sink.WriteAuditEvent(context.Background(), event); err != nil {
+			t.Fatalf("WriteAuditEvent returned error: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Unmarshal returned error: %v", err)
+		}
+		if event.Table != "users" || event.QueryType != "unique" || event.Principal != "billing-service" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", lines)
+	}
+}
@@ -0,0 +1,291 @@
+package goeql
+
+// Bulk serialization for batch inserts and COPY.
+//
+// EncodeBatch encodes many values for the same column into a single shared
+// buffer, reusing the column identity for every payload, and reports the values
+// that could not be encoded by index rather than failing the whole batch.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BatchError is a value in a batch that could not be encoded
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("index %d: %v", e.Index, e.Err)
+}
+
+// BatchErrors is returned by EncodeBatch when some values could not be encoded
+type BatchErrors []BatchError
+
+func (e BatchErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("error encoding %d values: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// EncodeBatch encodes each value into an EQL payload for table and column.
+//
+// Payloads share a single backing buffer. Nil values, and Encrypted* values that
+// Serialize treats as NULL, produce a nil payload. If any value cannot be encoded
+// its payload is nil and the error is a BatchErrors listing each failed index.
+func EncodeBatch[T any](table string, column string, values []T) ([][]byte, error) {
+	return EncodeBatchContext(context.Background(), table, column, values)
+}
+
+// EncodeBatchContext is EncodeBatch using the keyset from ctx for every payload
+func EncodeBatchContext[T any](ctx context.Context, table string, column string, values []T) ([][]byte, error) {
+	keyset, err := payloadKeyset(ctx, table, column)
+	if err != nil {
+		return nil, err
+	}
+	version, err := payloadVersion(ctx, table, column)
+	if err != nil {
+		return nil, err
+	}
+	enc := newBatchEncoder(table, column, keyset, version)
+
+	payloads := make([][]byte, len(values))
+	offsets := make([]int, len(values)+1)
+	var errs BatchErrors
+	for i, v := range values {
+		offsets[i] = len(enc.buf)
+		if err := enc.append(ctx, v); err != nil {
+			errs = append(errs, BatchError{Index: i, Err: err})
+		}
+	}
+	offsets[len(values)] = len(enc.buf)
+
+	for i := range values {
+		start, end := offsets[i], offsets[i+1]
+		if start != end {
+			payloads[i] = enc.buf[start:end:end]
+		}
+	}
+
+	if len(errs) > 0 {
+		return payloads, errs
+	}
+	return payloads, nil
+}
+
+// batchEncoder appends payloads for a single column to a shared buffer
+type batchEncoder struct {
+	table  string
+	column string
+	buf    []byte
+	suffix []byte
+}
+
+func newBatchEncoder(table string, column string, keyset *Keyset, version EQLVersion) *batchEncoder {
+	return &batchEncoder{table: table, column: column, suffix: appendEnvelopeSuffix(nil, table, column, "", keyset, version)}
+}
+
+// append encodes v, appending nothing for NULL values or on error, and calls the
+// installed hooks for each value it encodes
+func (e *batchEncoder) append(ctx context.Context, v any) (err error) {
+	value, ok := batchPlaintext(v)
+	if !ok {
+		return nil
+	}
+
+	start := len(e.buf)
+	call := startHooks(ctx, OperationEncrypt, e.table, e.column, "")
+	defer func() { call.end(len(e.buf)-start, err) }()
+
+	buf, err := appendPlaintext(append(e.buf, `{"k":"pt","p":`...), value)
+	if err != nil {
+		e.buf = buf[:start]
+		return err
+	}
+	e.buf = append(buf, e.suffix...)
+	return nil
+}
+
+// batchPlaintext unwraps Encrypted* values to the plaintext their Serialize method encodes,
+// returning false for values that are stored as NULL
+func batchPlaintext(v any) (any, bool) {
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case EncryptedText:
+		return string(val), len(val) > 0
+	case EncryptedInt:
+		return int(val), true
+	case EncryptedBool:
+		return bool(val), bool(val)
+	case EncryptedJsonb:
+		return map[string]any(val), len(val) > 0
+	case EncryptedJsonbArray:
+		return []interface{}(val), len(val) > 0
+	default:
+		return v, true
+	}
+}
+
+// CopySource encodes encrypted columns of each row as it is read, and implements
+// pgx.CopyFromSource for use with pgx.Conn.CopyFrom
+type CopySource struct {
+	// ctx is kept for the hooks called as each row is encoded, as pgx.CopyFromSource has no context
+	ctx       context.Context
+	rows      [][]any
+	encrypted map[int]*batchEncoder
+	index     int
+	current   []any
+	err       error
+}
+
+// NewCopySource returns a CopySource for rows, where encrypted maps the position of
+// each encrypted column in a row to its EQL table and column identity
+func NewCopySource(rows [][]any, encrypted map[int]TableColumn) (*CopySource, error) {
+	return NewCopySourceContext(context.Background(), rows, encrypted)
+}
+
+// NewCopySourceContext is NewCopySource using the keyset and EQL version from ctx for every payload
+func NewCopySourceContext(ctx context.Context, rows [][]any, encrypted map[int]TableColumn) (*CopySource, error) {
+	encoders, err := newColumnEncoders(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return &CopySource{ctx: ctx, rows: rows, encrypted: encoders, index: -1}, nil
+}
+
+// newColumnEncoders returns an encoder for each encrypted column, resolving the keyset
+// and version from the registry and ctx as EncodeBatchContext does
+func newColumnEncoders(ctx context.Context, encrypted map[int]TableColumn) (map[int]*batchEncoder, error) {
+	keyset, err := keysetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoders := make(map[int]*batchEncoder, len(encrypted))
+	for pos, tc := range encrypted {
+		if pos < 0 {
+			return nil, fmt.Errorf("invalid encrypted column position %d", pos)
+		}
+		version, err := payloadVersion(ctx, tc.T, tc.C)
+		if err != nil {
+			return nil, err
+		}
+		ks := keyset
+		if registered := columnKeyset(tc.T, tc.C); registered != nil {
+			ks = registered
+		}
+		encoders[pos] = newBatchEncoder(tc.T, tc.C, ks, version)
+	}
+	return encoders, nil
+}
+
+// encodeRow returns a copy of row with each encrypted column replaced by its payload
+func encodeRow(ctx context.Context, row []any, encoders map[int]*batchEncoder) ([]any, error) {
+	out := make([]any, len(row))
+	copy(out, row)
+	for pos, enc := range encoders {
+		if pos >= len(row) {
+			return nil, fmt.Errorf("encrypted column position %d out of range for row of %d values", pos, len(row))
+		}
+		enc.buf = enc.buf[:0]
+		if err := enc.append(ctx, row[pos]); err != nil {
+			return nil, fmt.Errorf("column %d: %v", pos, err)
+		}
+		if len(enc.buf) == 0 {
+			out[pos] = nil
+		} else {
+			out[pos] = string(enc.buf)
+		}
+	}
+	return out, nil
+}
+
+// Next advances to the next row, returning false when there are no more rows or on error
+func (s *CopySource) Next() bool {
+	if s.err != nil || s.index+1 >= len(s.rows) {
+		return false
+	}
+	s.index++
+	s.current, s.err = encodeRow(s.ctx, s.rows[s.index], s.encrypted)
+	if s.err != nil {
+		s.err = fmt.Errorf("row %d: %v", s.index, s.err)
+		return false
+	}
+	return true
+}
+
+// Values returns the current row with encrypted columns encoded
+func (s *CopySource) Values() ([]any, error) {
+	return s.current, s.err
+}
+
+// Err returns the error encountered encoding a row, if any
+func (s *CopySource) Err() error {
+	return s.err
+}
+
+// maxParams is the maximum number of bind parameters PostgreSQL accepts in a statement
+const maxParams = 65535
+
+// ValuesStatement builds a multi-row INSERT statement and its arguments for rows,
+// encoding the encrypted columns of each row. encrypted maps the position of each
+// encrypted column in a row to its EQL table and column identity.
+func ValuesStatement(table string, columns []string, rows [][]any, encrypted map[int]TableColumn) (string, []any, error) {
+	return ValuesStatementContext(context.Background(), table, columns, rows, encrypted)
+}
+
+// ValuesStatementContext is ValuesStatement using the keyset and EQL version from ctx for every payload
+func ValuesStatementContext(ctx context.Context, table string, columns []string, rows [][]any, encrypted map[int]TableColumn) (string, []any, error) {
+	if len(rows) == 0 {
+		return "", nil, fmt.Errorf("no rows to insert")
+	}
+	if len(rows)*len(columns) > maxParams {
+		return "", nil, fmt.Errorf("too many parameters: %d rows of %d columns exceeds %d", len(rows), len(columns), maxParams)
+	}
+
+	encoders, err := newColumnEncoders(ctx, encrypted)
+	if err != nil {
+		return "", nil, err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", quoteIdent(table), strings.Join(quoted, ", "))
+
+	args := make([]any, 0, len(rows)*len(columns))
+	for r, row := range rows {
+		if len(row) != len(columns) {
+			return "", nil, fmt.Errorf("row %d: expected %d values, got %d", r, len(columns), len(row))
+		}
+		values, err := encodeRow(ctx, row, encoders)
+		if err != nil {
+			return "", nil, fmt.Errorf("row %d: %v", r, err)
+		}
+
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for i := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(len(args) + i + 1))
+		}
+		b.WriteByte(')')
+		args = append(args, values...)
+	}
+
+	return b.String(), args, nil
+}
@@ -0,0 +1,177 @@
+package goeql
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Test EncodeBatch produces the same payloads as Serialize
+func TestEncodeBatch(t *testing.T) {
+	values := []any{
+		"Hello, World!",
+		`<script>"quoted"</script>`,
+		42,
+		true,
+		EncryptedText("text"),
+		EncryptedInt(7),
+		map[string]any{"key": "value"},
+	}
+
+	payloads, err := EncodeBatch("test_table", "test_column", values)
+	if err != nil {
+		t.Fatalf("EncodeBatch returned error: %v", err)
+	}
+
+	var expected [][]byte
+	for _, v := range []any{"Hello, World!", `<script>"quoted"</script>`, 42, true, "text", 7, map[string]any{"key": "value"}} {
+		ec, err := ToEncryptedColumn(v, "test_table", "test_column", nil)
+		if err != nil {
+			t.Fatalf("ToEncryptedColumn returned error: %v", err)
+		}
+		serialized, err := json.Marshal(ec)
+		if err != nil {
+			t.Fatalf("Error marshaling EncryptedColumn: %v", err)
+		}
+		expected = append(expected, serialized)
+	}
+
+	if !reflect.DeepEqual(payloads, expected) {
+		for i := range payloads {
+			if string(payloads[i]) != string(expected[i]) {
+				t.Errorf("Expected payload %d to be %s, got %s", i, expected[i], payloads[i])
+			}
+		}
+	}
+
+	// Payloads share a backing buffer but must not overlap when appended to
+	payloads[0] = append(payloads[0], '!')
+	if string(payloads[1]) != string(expected[1]) {
+		t.Errorf("Appending to a payload modified the next payload: %s", payloads[1])
+	}
+}
+
+// Test EncodeBatch NULL values and per-index errors
+func TestEncodeBatch_Errors(t *testing.T) {
+	values := []any{nil, EncryptedText(""), EncryptedBool(false), struct{}{}, "ok", make(chan int)}
+
+	payloads, err := EncodeBatch("test_table", "test_column", values)
+
+	var errs BatchErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected BatchErrors, got %v", err)
+	}
+	if len(errs) != 2 || errs[0].Index != 3 || errs[1].Index != 5 {
+		t.Errorf("Expected errors at index 3 and 5, got %v", errs)
+	}
+
+	for i, p := range payloads {
+		if (i == 4) != (p != nil) {
+			t.Errorf("Unexpected payload at index %d: %s", i, p)
+		}
+	}
+}
+
+// Test EncodeBatch with a typed slice
+func TestEncodeBatch_Typed(t *testing.T) {
+	payloads, err := EncodeBatch("test_table", "test_column", []EncryptedText{"a", "b"})
+	if err != nil {
+		t.Fatalf("EncodeBatch returned error: %v", err)
+	}
+
+	var et EncryptedText
+	for i, expected := range []EncryptedText{"a", "b"} {
+		deserialized, err := et.Deserialize(payloads[i])
+		if err != nil {
+			t.Fatalf("Deserialize returned error: %v", err)
+		}
+		if deserialized != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, deserialized)
+		}
+	}
+}
+
+// Test CopySource encodes encrypted columns
+func TestCopySource(t *testing.T) {
+	rows := [][]any{
+		{1, "alice@example.com", EncryptedInt(30)},
+		{2, nil, EncryptedInt(41)},
+	}
+	encrypted := map[int]TableColumn{1: {T: "users", C: "email"}, 2: {T: "users", C: "age"}}
+
+	source, err := NewCopySource(rows, encrypted)
+	if err != nil {
+		t.Fatalf("NewCopySource returned error: %v", err)
+	}
+
+	var got [][]any
+	for source.Next() {
+		values, err := source.Values()
+		if err != nil {
+			t.Fatalf("Values returned error: %v", err)
+		}
+		got = append(got, values)
+	}
+	if err := source.Err(); err != nil {
+		t.Fatalf("Err returned error: %v", err)
+	}
+
+	expected := [][]any{
+		{1, `{"k":"pt","p":"alice@example.com","i":{"t":"users","c":"email"},"v":1,"q":null}`, `{"k":"pt","p":"30","i":{"t":"users","c":"age"},"v":1,"q":null}`},
+		{2, nil, `{"k":"pt","p":"41","i":{"t":"users","c":"age"},"v":1,"q":null}`},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, got)
+	}
+
+	if rows[0][1] != "alice@example.com" {
+		t.Errorf("CopySource modified the source rows")
+	}
+}
+
+// Test CopySource stops on an encoding error
+func TestCopySource_Error(t *testing.T) {
+	source, err := NewCopySource([][]any{{1, struct{}{}}}, map[int]TableColumn{1: {T: "users", C: "email"}})
+	if err != nil {
+		t.Fatalf("NewCopySource returned error: %v", err)
+	}
+	if source.Next() {
+		t.Errorf("Expected Next to return false on an encoding error")
+	}
+	if source.Err() == nil {
+		t.Errorf("Expected error encoding row, but got none")
+	}
+}
+
+// Test ValuesStatement
+func TestValuesStatement(t *testing.T) {
+	rows := [][]any{
+		{1, "alice@example.com"},
+		{2, "bob@example.com"},
+	}
+
+	query, args, err := ValuesStatement("users", []string{"id", "email"}, rows, map[int]TableColumn{1: {T: "users", C: "email"}})
+	if err != nil {
+		t.Fatalf("ValuesStatement returned error: %v", err)
+	}
+
+	expectedQuery := `INSERT INTO "users" ("id", "email") VALUES ($1, $2), ($3, $4)`
+	if query != expectedQuery {
+		t.Errorf("Expected query %s, got %s", expectedQuery, query)
+	}
+	expectedArgs := []any{
+		1, `{"k":"pt","p":"alice@example.com","i":{"t":"users","c":"email"},"v":1,"q":null}`,
+		2, `{"k":"pt","p":"bob@example.com","i":{"t":"users","c":"email"},"v":1,"q":null}`,
+	}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Expected args %v, got %v", expectedArgs, args)
+	}
+
+	if _, _, err := ValuesStatement("users", []string{"id", "email"}, [][]any{{1}}, nil); err == nil {
+		t.Errorf("Expected error for short row, but got none")
+	}
+	if _, _, err := ValuesStatement("users", []string{"id"}, nil, nil); err == nil {
+		t.Errorf("Expected error for no rows, but got none")
+	}
+}
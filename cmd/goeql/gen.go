@@ -0,0 +1,435 @@
+package main
+
+// goeql gen generates typed column accessors from a schema file in the EQL
+// configuration format. Each column gets a Go type whose Encrypt and Decode
+// methods take and return the Encrypted* type for its cast, and which only has
+// query methods for its configured indexes, so unsupported queries fail to
+// compile instead of failing in CipherStash Proxy. A jsonb column holds either
+// JSON objects or arrays, so its Decode returns a goeql.EncryptedJsonb or a
+// goeql.EncryptedJsonbArray as an any, and EncryptArray stores arrays.
+//
+// Each column also gets a goeql.SQLValue alias for database/sql, plus an
+// ArrayValue alias for jsonb columns, and with -sqlc the command prints the
+// sqlc overrides that map columns to their Value aliases.
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/cipherstash/goeql"
+)
+
+func gen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("gen", stderr)
+	schema := fs.String("schema", "", "schema file in the EQL configuration format, as JSON or YAML (required)")
+	pkg := fs.String("package", "", "package name of the generated code (required)")
+	output := fs.String("o", "", "output file (default: stdout)")
+	sqlc := fs.String("sqlc", "", "print sqlc go_type overrides for the generated package with this import path, instead of Go code")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *schema == "" || (*pkg == "" && *sqlc == "") || fs.NArg() > 0 {
+		_, _ = io.WriteString(stderr, "goeql gen: -schema and either -package or -sqlc are required\n")
+		fs.Usage()
+		return errUsage
+	}
+
+	columns, err := loadSchema(*schema)
+	if err != nil {
+		return err
+	}
+	var code []byte
+	if *sqlc != "" {
+		code, err = generateSQLCOverrides(*sqlc, filepath.Base(*schema), columns)
+	} else {
+		code, err = generate(*pkg, filepath.Base(*schema), columns)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(*output, code, 0o644)
+}
+
+// loadSchema reads the columns from a schema file, parsing files with a .yaml or .yml
+// extension as YAML and others as JSON
+func loadSchema(path string) ([]goeql.Column, error) {
+	cfg, err := goeql.ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := cfg.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("schema %s declares no columns", path)
+	}
+	return columns, nil
+}
+
+// genTable is a table in the generated code
+type genTable struct {
+	Name    string
+	Ident   string
+	Columns []genColumn
+}
+
+// genColumn is a column in the generated code
+type genColumn struct {
+	goeql.Column
+	Ident string
+	// Type is the unexported accessor type
+	Type string
+	// Encrypted is the Encrypted* type for the cast, and Zero its zero value
+	Encrypted string
+	Zero      string
+	// QueryValue is the Go type of query values
+	QueryValue string
+	// Jsonb is set for jsonb columns, which decode objects and arrays
+	Jsonb bool
+	// Queries are the query methods the column's indexes serve
+	Queries []genQuery
+}
+
+// genQuery is a query method in the generated code
+type genQuery struct {
+	Method string
+	Index  string
+	Value  string
+}
+
+// castTypes maps each cast to its Encrypted* type, zero value and query value type.
+// goeql has no Encrypted* type for real and double columns, so they are not generated.
+var castTypes = map[goeql.CastType][3]string{
+	goeql.CastText:     {"goeql.EncryptedText", `""`, "string"},
+	goeql.CastDate:     {"goeql.EncryptedText", `""`, "string"},
+	goeql.CastInt:      {"goeql.EncryptedInt", "0", "int"},
+	goeql.CastSmallInt: {"goeql.EncryptedInt", "0", "int"},
+	goeql.CastBigInt:   {"goeql.EncryptedInt", "0", "int64"},
+	goeql.CastBoolean:  {"goeql.EncryptedBool", "false", "bool"},
+	goeql.CastJsonb:    {"goeql.EncryptedJsonb", "nil", "map[string]any"},
+}
+
+// indexConstants maps each index to its goeql constant
+var indexConstants = map[goeql.IndexType]string{
+	goeql.MatchIndex:  "goeql.MatchIndex",
+	goeql.OreIndex:    "goeql.OreIndex",
+	goeql.UniqueIndex: "goeql.UniqueIndex",
+	goeql.SteVecIndex: "goeql.SteVecIndex",
+}
+
+// castConstants maps each cast to its goeql constant
+var castConstants = map[goeql.CastType]string{
+	goeql.CastText:     "goeql.CastText",
+	goeql.CastInt:      "goeql.CastInt",
+	goeql.CastSmallInt: "goeql.CastSmallInt",
+	goeql.CastBigInt:   "goeql.CastBigInt",
+	goeql.CastBoolean:  "goeql.CastBoolean",
+	goeql.CastDate:     "goeql.CastDate",
+	goeql.CastJsonb:    "goeql.CastJsonb",
+}
+
+// generate returns the formatted Go source for the columns
+func generate(pkg string, source string, columns []goeql.Column) ([]byte, error) {
+	var tables []genTable
+	for _, c := range columns {
+		if len(tables) == 0 || tables[len(tables)-1].Name != c.Table {
+			tables = append(tables, genTable{Name: c.Table, Ident: exportedIdent(c.Table)})
+		}
+		t := &tables[len(tables)-1]
+
+		if err := checkName(c.Table); err != nil {
+			return nil, fmt.Errorf("table %q: %v", c.Table, err)
+		}
+		if err := checkName(c.Name); err != nil {
+			return nil, fmt.Errorf("column %q: %v", c.Table+"."+c.Name, err)
+		}
+		types, ok := castTypes[c.Cast]
+		if !ok {
+			return nil, fmt.Errorf("column %s.%s: cast %s has no Encrypted* type to generate", c.Table, c.Name, c.Cast)
+		}
+		col := genColumn{
+			Column:     c,
+			Ident:      t.Ident + exportedIdent(c.Name),
+			Encrypted:  types[0],
+			Zero:       types[1],
+			QueryValue: types[2],
+			Jsonb:      c.Cast == goeql.CastJsonb,
+		}
+		col.Type = unexportedIdent(col.Ident) + "Column"
+		for _, index := range c.Indexes {
+			col.Queries = append(col.Queries, queriesFor(index, col.QueryValue)...)
+		}
+		t.Columns = append(t.Columns, col)
+	}
+
+	if err := checkIdents(tables); err != nil {
+		return nil, err
+	}
+
+	// Only the typed Decode methods of columns other than jsonb format errors
+	usesFmt := false
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			usesFmt = usesFmt || !c.Jsonb
+		}
+	}
+
+	var buf bytes.Buffer
+	err := genTemplate.Execute(&buf, struct {
+		Package string
+		Source  string
+		Tables  []genTable
+		UsesFmt bool
+	}{pkg, source, tables, usesFmt})
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated code: %v", err)
+	}
+	return code, nil
+}
+
+// generateSQLCOverrides returns the sqlc overrides mapping each column to its generated
+// Value type in the package at importPath
+func generateSQLCOverrides(importPath string, source string, columns []goeql.Column) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by goeql gen from %s. Add to the go overrides in sqlc.yaml.\n", source)
+	b.WriteString("overrides:\n")
+	for _, c := range columns {
+		fmt.Fprintf(&b, "  - column: %q\n", c.Table+"."+c.Name)
+		b.WriteString("    go_type:\n")
+		fmt.Fprintf(&b, "      import: %q\n", importPath)
+		fmt.Fprintf(&b, "      type: %q\n", exportedIdent(c.Table)+exportedIdent(c.Name)+"Value")
+	}
+	return []byte(b.String()), nil
+}
+
+// checkIdents returns an error if two generated declarations have the same name
+func checkIdents(tables []genTable) error {
+	seen := make(map[string]string)
+	declare := func(ident string, name string) error {
+		if other, ok := seen[ident]; ok {
+			return fmt.Errorf("%s and %s both generate the identifier %s", other, name, ident)
+		}
+		seen[ident] = name
+		return nil
+	}
+
+	for _, t := range tables {
+		if err := declare(t.Ident+"Table", "table "+t.Name); err != nil {
+			return err
+		}
+		for _, c := range t.Columns {
+			name := "column " + c.Table + "." + c.Name
+			idents := []string{c.Ident, c.Ident + "Column", c.Ident + "Value", "New" + c.Ident + "Value", c.Type}
+			if c.Jsonb {
+				idents = append(idents, c.Ident+"ArrayValue", "New"+c.Ident+"ArrayValue")
+			}
+			for _, ident := range idents {
+				if err := declare(ident, name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkName rejects names that cannot be written into comments of the generated code
+func checkName(name string) error {
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("name contains the unprintable character %q", r)
+		}
+	}
+	return nil
+}
+
+// queriesFor returns the query methods served by an index
+func queriesFor(index goeql.IndexType, value string) []genQuery {
+	switch index {
+	case goeql.MatchIndex:
+		return []genQuery{{Method: "Match", Index: "match", Value: "string"}}
+	case goeql.OreIndex:
+		return []genQuery{{Method: "Ore", Index: "ore", Value: value}}
+	case goeql.UniqueIndex:
+		return []genQuery{{Method: "Unique", Index: "unique", Value: value}}
+	case goeql.SteVecIndex:
+		return []genQuery{
+			{Method: "Jsonb", Index: "ste_vec", Value: "any"},
+			{Method: "EJsonPath", Index: "ejson path", Value: "goeql.JSONPath"},
+		}
+	}
+	return nil
+}
+
+// initialisms are written in upper case in identifiers, following Go naming conventions
+var initialisms = map[string]bool{
+	"api": true, "id": true, "ip": true, "json": true, "sql": true, "ssn": true, "uid": true, "url": true, "uuid": true,
+}
+
+// exportedIdent converts a snake_case SQL name to an exported Go identifier
+func exportedIdent(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	ident := b.String()
+	if ident == "" || !unicode.IsLetter([]rune(ident)[0]) {
+		ident = "X" + ident
+	}
+	return ident
+}
+
+// unexportedIdent lower cases the leading letter, or leading initialism, of an exported identifier
+func unexportedIdent(ident string) string {
+	runes := []rune(ident)
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+	if i > 1 && i < len(runes) {
+		// Keep the start of the next word upper case, e.g. SSNColumn becomes ssnColumn
+		i--
+	}
+	for j := 0; j < i || j == 0; j++ {
+		runes[j] = unicode.ToLower(runes[j])
+	}
+	return string(runes)
+}
+
+var genTemplate = template.Must(template.New("gen").Funcs(template.FuncMap{
+	"cast":       func(c goeql.CastType) string { return castConstants[c] },
+	"indexConst": func(i goeql.IndexType) string { return indexConstants[i] },
+}).Parse(`// Code generated by goeql gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	{{- if .UsesFmt}}
+	"fmt"
+	{{- end}}
+
+	"github.com/cipherstash/goeql"
+)
+
+{{range $t := .Tables}}
+// {{$t.Ident}}Table is the {{$t.Name}} table
+const {{$t.Ident}}Table = {{printf "%q" $t.Name}}
+
+// Encrypted columns of the {{$t.Name}} table
+const (
+{{- range $t.Columns}}
+	{{.Ident}}Column = {{printf "%q" .Name}}
+{{- end}}
+)
+
+{{range $c := $t.Columns}}
+// {{.Ident}} is the {{.Table}}.{{.Name}} encrypted column, cast as {{.Cast}}
+var {{.Ident}} = {{.Type}}{column: goeql.Column{
+	Table: {{$t.Ident}}Table,
+	Name: {{.Ident}}Column,
+	Cast: {{cast .Cast}},
+	{{- if .Indexes}}
+	Indexes: []goeql.IndexType{ {{- range $i, $index := .Indexes}}{{if $i}}, {{end}}{{indexConst $index}}{{end -}} },
+	{{- end}}
+}}
+
+// {{.Type}} serializes values and queries for the {{.Table}}.{{.Name}} column
+type {{.Type}} struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c {{.Type}}) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c {{.Type}}) Encrypt(ctx context.Context, value {{.Encrypted}}) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+{{- if .Jsonb}}
+
+// EncryptArray serializes a JSON array to be stored in the column
+func (c {{.Type}}) EncryptArray(ctx context.Context, value goeql.EncryptedJsonbArray) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column, returning a goeql.EncryptedJsonb for a JSON
+// object or a goeql.EncryptedJsonbArray for a JSON array
+func (c {{.Type}}) Decode(ctx context.Context, data []byte) (any, error) {
+	return c.column.DecodeContext(ctx, data)
+}
+{{- else}}
+
+// Decode decodes a payload from the column
+func (c {{.Type}}) Decode(ctx context.Context, data []byte) ({{.Encrypted}}, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return {{.Zero}}, err
+	}
+	decoded, ok := value.({{.Encrypted}})
+	if !ok {
+		return {{.Zero}}, fmt.Errorf("%s.%s: unexpected %T value", {{$t.Ident}}Table, {{.Ident}}Column, value)
+	}
+	return decoded, nil
+}
+{{- end}}
+
+// EQLColumn returns the column identity, so {{.Type}} identifies the column of a goeql.SQLValue
+func ({{.Type}}) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: {{$t.Ident}}Table, C: {{.Ident}}Column}
+}
+
+// {{.Ident}}Value is a nullable {{.Table}}.{{.Name}} value for database/sql, e.g. as a sqlc go_type override
+type {{.Ident}}Value = goeql.SQLValue[{{.Encrypted}}, {{.Type}}]
+
+// New{{.Ident}}Value returns a non-NULL {{.Ident}}Value
+func New{{.Ident}}Value(plaintext {{.Encrypted}}) {{.Ident}}Value {
+	return {{.Ident}}Value{Plaintext: plaintext, Valid: true}
+}
+{{- if .Jsonb}}
+
+// {{.Ident}}ArrayValue is a nullable {{.Table}}.{{.Name}} JSON array value for database/sql
+type {{.Ident}}ArrayValue = goeql.SQLValue[goeql.EncryptedJsonbArray, {{.Type}}]
+
+// New{{.Ident}}ArrayValue returns a non-NULL {{.Ident}}ArrayValue
+func New{{.Ident}}ArrayValue(plaintext goeql.EncryptedJsonbArray) {{.Ident}}ArrayValue {
+	return {{.Ident}}ArrayValue{Plaintext: plaintext, Valid: true}
+}
+{{- end}}
+{{range $q := .Queries}}
+// {{$q.Method}} serializes a value used in {{if eq $q.Index "ore" "ejson path"}}an{{else}}a{{end}} {{$q.Index}} query on the column
+func (c {{$c.Type}}) {{$q.Method}}(ctx context.Context, value {{$q.Value}}) ([]byte, error) {
+	return c.column.{{$q.Method}}Context(ctx, value)
+}
+{{end}}
+{{end}}
+{{end}}
+`))
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cipherstash/goeql"
+)
+
+// Test the checked in generated code is up to date with the generator
+func TestGen_UpToDate(t *testing.T) {
+	expected, err := os.ReadFile("internal/gentest/columns_eql.go")
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+
+	output := filepath.Join(t.TempDir(), "columns_eql.go")
+	if _, stderr, code := runCommand(t, "", "gen", "-schema", "testdata/schema.yaml", "-package", "gentest", "-o", output); code != 0 {
+		t.Fatalf("gen exited with %d: %s", code, stderr)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Generated code is out of date, run go generate ./cmd/goeql/internal/gentest")
+	}
+}
+
+// Test query methods are only generated for configured indexes
+func TestGen_Queries(t *testing.T) {
+	code, err := generate("models", "schema.json", []goeql.Column{
+		{Table: "users", Name: "email", Cast: goeql.CastText, Indexes: []goeql.IndexType{goeql.UniqueIndex}},
+	})
+	if err != nil {
+		t.Fatalf("generate returned error: %v", err)
+	}
+	src := string(code)
+	if !strings.Contains(src, "func (c usersEmailColumn) Unique(ctx context.Context, value string) ([]byte, error)") {
+		t.Errorf("Expected a Unique method, got:\n%s", src)
+	}
+	for _, method := range []string{"Match", "Ore", "Jsonb", "EJsonPath"} {
+		if strings.Contains(src, ") "+method+"(") {
+			t.Errorf("Expected no %s method, got:\n%s", method, src)
+		}
+	}
+}
+
+// Test identifier conversion
+func TestExportedIdent(t *testing.T) {
+	tests := map[string]string{
+		"users":       "Users",
+		"total_cents": "TotalCents",
+		"user_id":     "UserID",
+		"ssn":         "SSN",
+		"2fa_secret":  "X2faSecret",
+		"api-key":     "APIKey",
+	}
+	for name, expected := range tests {
+		if got := exportedIdent(name); got != expected {
+			t.Errorf("exportedIdent(%q): expected %s, got %s", name, expected, got)
+		}
+	}
+
+	if got := unexportedIdent("UsersSSN"); got != "usersSSN" {
+		t.Errorf("Expected usersSSN, got %s", got)
+	}
+	if got := unexportedIdent("SSNHash"); got != "ssnHash" {
+		t.Errorf("Expected ssnHash, got %s", got)
+	}
+}
+
+// Test identifier collisions are reported
+func TestGen_Collision(t *testing.T) {
+	_, err := generate("models", "schema.json", []goeql.Column{
+		{Table: "user_email", Name: "x", Cast: goeql.CastText},
+		{Table: "user", Name: "email_x", Cast: goeql.CastText},
+	})
+	if err == nil {
+		t.Errorf("Expected an identifier collision error")
+	}
+}
+
+// Test table and column names are quoted as Go string literals
+func TestGen_Quoting(t *testing.T) {
+	code, err := generate("models", "schema.json", []goeql.Column{
+		{Table: "users", Name: `e"mail\`, Cast: goeql.CastText},
+	})
+	if err != nil {
+		t.Fatalf("generate returned error: %v", err)
+	}
+	if !strings.Contains(string(code), `UsersEMailColumn = "e\"mail\\"`) {
+		t.Errorf("Expected a quoted column name, got:\n%s", code)
+	}
+
+	if _, err := generate("models", "schema.json", []goeql.Column{{Table: "users\nvar x = 1", Name: "email", Cast: goeql.CastText}}); err == nil {
+		t.Errorf("Expected an error for a table name with a line break")
+	}
+}
+
+// Test gen rejects casts without an Encrypted* type
+func TestGen_UnsupportedCast(t *testing.T) {
+	for _, cast := range []goeql.CastType{goeql.CastReal, goeql.CastDouble} {
+		_, err := generate("models", "schema.json", []goeql.Column{{Table: "orders", Name: "total", Cast: cast}})
+		if err == nil || !strings.Contains(err.Error(), "orders.total") {
+			t.Errorf("Expected an error for cast %s, got %v", cast, err)
+		}
+	}
+}
+
+// Test jsonb columns generate accessors for objects and arrays, without the fmt import
+func TestGen_Jsonb(t *testing.T) {
+	code, err := generate("models", "schema.json", []goeql.Column{
+		{Table: "users", Name: "attrs", Cast: goeql.CastJsonb},
+	})
+	if err != nil {
+		t.Fatalf("generate returned error: %v", err)
+	}
+	src := string(code)
+	for _, decl := range []string{
+		"func (c usersAttrsColumn) Decode(ctx context.Context, data []byte) (any, error)",
+		"func (c usersAttrsColumn) EncryptArray(ctx context.Context, value goeql.EncryptedJsonbArray) ([]byte, error)",
+		"type UsersAttrsArrayValue = goeql.SQLValue[goeql.EncryptedJsonbArray, usersAttrsColumn]",
+	} {
+		if !strings.Contains(src, decl) {
+			t.Errorf("Expected %s, got:\n%s", decl, src)
+		}
+	}
+	if strings.Contains(src, `"fmt"`) {
+		t.Errorf("Expected no fmt import for jsonb columns, got:\n%s", src)
+	}
+}
+
+// Test gen rejects missing flags and invalid schemas
+func TestGen_Invalid(t *testing.T) {
+	if _, _, code := runCommand(t, "", "gen", "-package", "models"); code != 2 {
+		t.Errorf("Expected exit 2 without -schema, got %d", code)
+	}
+
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(schema, []byte(`{"v":1,"tables":{"users":{"email":{"cast_as":"varchar"}}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if _, stderr, code := runCommand(t, "", "gen", "-schema", schema, "-package", "models"); code != 1 || !strings.Contains(stderr, "unknown cast type") {
+		t.Errorf("Expected exit 1 for an unknown cast, got %d: %s", code, stderr)
+	}
+}
+
+// Test gen -sqlc prints an override for every column
+func TestGen_SQLC(t *testing.T) {
+	stdout, stderr, code := runCommand(t, "", "gen", "-schema", "testdata/schema.yaml", "-sqlc", "example.com/app/models")
+	if code != 0 {
+		t.Fatalf("gen -sqlc exited with %d: %s", code, stderr)
+	}
+	expected := `  - column: "users.ssn"
+    go_type:
+      import: "example.com/app/models"
+      type: "UsersSSNValue"
+`
+	if !strings.Contains(stdout, expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, stdout)
+	}
+	if got := strings.Count(stdout, "- column:"); got != 6 {
+		t.Errorf("Expected 6 overrides, got %d", got)
+	}
+}
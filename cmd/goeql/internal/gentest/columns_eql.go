@@ -0,0 +1,369 @@
+// Code generated by goeql gen from schema.yaml. DO NOT EDIT.
+
+package gentest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cipherstash/goeql"
+)
+
+// OrdersTable is the orders table
+const OrdersTable = "orders"
+
+// Encrypted columns of the orders table
+const (
+	OrdersShippedColumn    = "shipped"
+	OrdersTotalCentsColumn = "total_cents"
+)
+
+// OrdersShipped is the orders.shipped encrypted column, cast as boolean
+var OrdersShipped = ordersShippedColumn{column: goeql.Column{
+	Table:   OrdersTable,
+	Name:    OrdersShippedColumn,
+	Cast:    goeql.CastBoolean,
+	Indexes: []goeql.IndexType{goeql.UniqueIndex},
+}}
+
+// ordersShippedColumn serializes values and queries for the orders.shipped column
+type ordersShippedColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c ordersShippedColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c ordersShippedColumn) Encrypt(ctx context.Context, value goeql.EncryptedBool) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column
+func (c ordersShippedColumn) Decode(ctx context.Context, data []byte) (goeql.EncryptedBool, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return false, err
+	}
+	decoded, ok := value.(goeql.EncryptedBool)
+	if !ok {
+		return false, fmt.Errorf("%s.%s: unexpected %T value", OrdersTable, OrdersShippedColumn, value)
+	}
+	return decoded, nil
+}
+
+// EQLColumn returns the column identity, so ordersShippedColumn identifies the column of a goeql.SQLValue
+func (ordersShippedColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: OrdersTable, C: OrdersShippedColumn}
+}
+
+// OrdersShippedValue is a nullable orders.shipped value for database/sql, e.g. as a sqlc go_type override
+type OrdersShippedValue = goeql.SQLValue[goeql.EncryptedBool, ordersShippedColumn]
+
+// NewOrdersShippedValue returns a non-NULL OrdersShippedValue
+func NewOrdersShippedValue(plaintext goeql.EncryptedBool) OrdersShippedValue {
+	return OrdersShippedValue{Plaintext: plaintext, Valid: true}
+}
+
+// Unique serializes a value used in a unique query on the column
+func (c ordersShippedColumn) Unique(ctx context.Context, value bool) ([]byte, error) {
+	return c.column.UniqueContext(ctx, value)
+}
+
+// OrdersTotalCents is the orders.total_cents encrypted column, cast as big_int
+var OrdersTotalCents = ordersTotalCentsColumn{column: goeql.Column{
+	Table:   OrdersTable,
+	Name:    OrdersTotalCentsColumn,
+	Cast:    goeql.CastBigInt,
+	Indexes: []goeql.IndexType{goeql.OreIndex, goeql.UniqueIndex},
+}}
+
+// ordersTotalCentsColumn serializes values and queries for the orders.total_cents column
+type ordersTotalCentsColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c ordersTotalCentsColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c ordersTotalCentsColumn) Encrypt(ctx context.Context, value goeql.EncryptedInt) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column
+func (c ordersTotalCentsColumn) Decode(ctx context.Context, data []byte) (goeql.EncryptedInt, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	decoded, ok := value.(goeql.EncryptedInt)
+	if !ok {
+		return 0, fmt.Errorf("%s.%s: unexpected %T value", OrdersTable, OrdersTotalCentsColumn, value)
+	}
+	return decoded, nil
+}
+
+// EQLColumn returns the column identity, so ordersTotalCentsColumn identifies the column of a goeql.SQLValue
+func (ordersTotalCentsColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: OrdersTable, C: OrdersTotalCentsColumn}
+}
+
+// OrdersTotalCentsValue is a nullable orders.total_cents value for database/sql, e.g. as a sqlc go_type override
+type OrdersTotalCentsValue = goeql.SQLValue[goeql.EncryptedInt, ordersTotalCentsColumn]
+
+// NewOrdersTotalCentsValue returns a non-NULL OrdersTotalCentsValue
+func NewOrdersTotalCentsValue(plaintext goeql.EncryptedInt) OrdersTotalCentsValue {
+	return OrdersTotalCentsValue{Plaintext: plaintext, Valid: true}
+}
+
+// Ore serializes a value used in an ore query on the column
+func (c ordersTotalCentsColumn) Ore(ctx context.Context, value int64) ([]byte, error) {
+	return c.column.OreContext(ctx, value)
+}
+
+// Unique serializes a value used in a unique query on the column
+func (c ordersTotalCentsColumn) Unique(ctx context.Context, value int64) ([]byte, error) {
+	return c.column.UniqueContext(ctx, value)
+}
+
+// UsersTable is the users table
+const UsersTable = "users"
+
+// Encrypted columns of the users table
+const (
+	UsersAgeColumn   = "age"
+	UsersAttrsColumn = "attrs"
+	UsersEmailColumn = "email"
+	UsersSSNColumn   = "ssn"
+)
+
+// UsersAge is the users.age encrypted column, cast as int
+var UsersAge = usersAgeColumn{column: goeql.Column{
+	Table:   UsersTable,
+	Name:    UsersAgeColumn,
+	Cast:    goeql.CastInt,
+	Indexes: []goeql.IndexType{goeql.OreIndex},
+}}
+
+// usersAgeColumn serializes values and queries for the users.age column
+type usersAgeColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c usersAgeColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c usersAgeColumn) Encrypt(ctx context.Context, value goeql.EncryptedInt) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column
+func (c usersAgeColumn) Decode(ctx context.Context, data []byte) (goeql.EncryptedInt, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	decoded, ok := value.(goeql.EncryptedInt)
+	if !ok {
+		return 0, fmt.Errorf("%s.%s: unexpected %T value", UsersTable, UsersAgeColumn, value)
+	}
+	return decoded, nil
+}
+
+// EQLColumn returns the column identity, so usersAgeColumn identifies the column of a goeql.SQLValue
+func (usersAgeColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: UsersTable, C: UsersAgeColumn}
+}
+
+// UsersAgeValue is a nullable users.age value for database/sql, e.g. as a sqlc go_type override
+type UsersAgeValue = goeql.SQLValue[goeql.EncryptedInt, usersAgeColumn]
+
+// NewUsersAgeValue returns a non-NULL UsersAgeValue
+func NewUsersAgeValue(plaintext goeql.EncryptedInt) UsersAgeValue {
+	return UsersAgeValue{Plaintext: plaintext, Valid: true}
+}
+
+// Ore serializes a value used in an ore query on the column
+func (c usersAgeColumn) Ore(ctx context.Context, value int) ([]byte, error) {
+	return c.column.OreContext(ctx, value)
+}
+
+// UsersAttrs is the users.attrs encrypted column, cast as jsonb
+var UsersAttrs = usersAttrsColumn{column: goeql.Column{
+	Table:   UsersTable,
+	Name:    UsersAttrsColumn,
+	Cast:    goeql.CastJsonb,
+	Indexes: []goeql.IndexType{goeql.SteVecIndex},
+}}
+
+// usersAttrsColumn serializes values and queries for the users.attrs column
+type usersAttrsColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c usersAttrsColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c usersAttrsColumn) Encrypt(ctx context.Context, value goeql.EncryptedJsonb) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// EncryptArray serializes a JSON array to be stored in the column
+func (c usersAttrsColumn) EncryptArray(ctx context.Context, value goeql.EncryptedJsonbArray) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column, returning a goeql.EncryptedJsonb for a JSON
+// object or a goeql.EncryptedJsonbArray for a JSON array
+func (c usersAttrsColumn) Decode(ctx context.Context, data []byte) (any, error) {
+	return c.column.DecodeContext(ctx, data)
+}
+
+// EQLColumn returns the column identity, so usersAttrsColumn identifies the column of a goeql.SQLValue
+func (usersAttrsColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: UsersTable, C: UsersAttrsColumn}
+}
+
+// UsersAttrsValue is a nullable users.attrs value for database/sql, e.g. as a sqlc go_type override
+type UsersAttrsValue = goeql.SQLValue[goeql.EncryptedJsonb, usersAttrsColumn]
+
+// NewUsersAttrsValue returns a non-NULL UsersAttrsValue
+func NewUsersAttrsValue(plaintext goeql.EncryptedJsonb) UsersAttrsValue {
+	return UsersAttrsValue{Plaintext: plaintext, Valid: true}
+}
+
+// UsersAttrsArrayValue is a nullable users.attrs JSON array value for database/sql
+type UsersAttrsArrayValue = goeql.SQLValue[goeql.EncryptedJsonbArray, usersAttrsColumn]
+
+// NewUsersAttrsArrayValue returns a non-NULL UsersAttrsArrayValue
+func NewUsersAttrsArrayValue(plaintext goeql.EncryptedJsonbArray) UsersAttrsArrayValue {
+	return UsersAttrsArrayValue{Plaintext: plaintext, Valid: true}
+}
+
+// Jsonb serializes a value used in a ste_vec query on the column
+func (c usersAttrsColumn) Jsonb(ctx context.Context, value any) ([]byte, error) {
+	return c.column.JsonbContext(ctx, value)
+}
+
+// EJsonPath serializes a value used in an ejson path query on the column
+func (c usersAttrsColumn) EJsonPath(ctx context.Context, value goeql.JSONPath) ([]byte, error) {
+	return c.column.EJsonPathContext(ctx, value)
+}
+
+// UsersEmail is the users.email encrypted column, cast as text
+var UsersEmail = usersEmailColumn{column: goeql.Column{
+	Table:   UsersTable,
+	Name:    UsersEmailColumn,
+	Cast:    goeql.CastText,
+	Indexes: []goeql.IndexType{goeql.MatchIndex, goeql.UniqueIndex},
+}}
+
+// usersEmailColumn serializes values and queries for the users.email column
+type usersEmailColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c usersEmailColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c usersEmailColumn) Encrypt(ctx context.Context, value goeql.EncryptedText) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column
+func (c usersEmailColumn) Decode(ctx context.Context, data []byte) (goeql.EncryptedText, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return "", err
+	}
+	decoded, ok := value.(goeql.EncryptedText)
+	if !ok {
+		return "", fmt.Errorf("%s.%s: unexpected %T value", UsersTable, UsersEmailColumn, value)
+	}
+	return decoded, nil
+}
+
+// EQLColumn returns the column identity, so usersEmailColumn identifies the column of a goeql.SQLValue
+func (usersEmailColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: UsersTable, C: UsersEmailColumn}
+}
+
+// UsersEmailValue is a nullable users.email value for database/sql, e.g. as a sqlc go_type override
+type UsersEmailValue = goeql.SQLValue[goeql.EncryptedText, usersEmailColumn]
+
+// NewUsersEmailValue returns a non-NULL UsersEmailValue
+func NewUsersEmailValue(plaintext goeql.EncryptedText) UsersEmailValue {
+	return UsersEmailValue{Plaintext: plaintext, Valid: true}
+}
+
+// Match serializes a value used in a match query on the column
+func (c usersEmailColumn) Match(ctx context.Context, value string) ([]byte, error) {
+	return c.column.MatchContext(ctx, value)
+}
+
+// Unique serializes a value used in a unique query on the column
+func (c usersEmailColumn) Unique(ctx context.Context, value string) ([]byte, error) {
+	return c.column.UniqueContext(ctx, value)
+}
+
+// UsersSSN is the users.ssn encrypted column, cast as text
+var UsersSSN = usersSSNColumn{column: goeql.Column{
+	Table: UsersTable,
+	Name:  UsersSSNColumn,
+	Cast:  goeql.CastText,
+}}
+
+// usersSSNColumn serializes values and queries for the users.ssn column
+type usersSSNColumn struct {
+	column goeql.Column
+}
+
+// Column returns the column declaration
+func (c usersSSNColumn) Column() goeql.Column {
+	return c.column
+}
+
+// Encrypt serializes a value to be stored in the column
+func (c usersSSNColumn) Encrypt(ctx context.Context, value goeql.EncryptedText) ([]byte, error) {
+	return value.SerializeContext(ctx, c.column.Table, c.column.Name)
+}
+
+// Decode decodes a payload from the column
+func (c usersSSNColumn) Decode(ctx context.Context, data []byte) (goeql.EncryptedText, error) {
+	value, err := c.column.DecodeContext(ctx, data)
+	if err != nil || value == nil {
+		return "", err
+	}
+	decoded, ok := value.(goeql.EncryptedText)
+	if !ok {
+		return "", fmt.Errorf("%s.%s: unexpected %T value", UsersTable, UsersSSNColumn, value)
+	}
+	return decoded, nil
+}
+
+// EQLColumn returns the column identity, so usersSSNColumn identifies the column of a goeql.SQLValue
+func (usersSSNColumn) EQLColumn() goeql.TableColumn {
+	return goeql.TableColumn{T: UsersTable, C: UsersSSNColumn}
+}
+
+// UsersSSNValue is a nullable users.ssn value for database/sql, e.g. as a sqlc go_type override
+type UsersSSNValue = goeql.SQLValue[goeql.EncryptedText, usersSSNColumn]
+
+// NewUsersSSNValue returns a non-NULL UsersSSNValue
+func NewUsersSSNValue(plaintext goeql.EncryptedText) UsersSSNValue {
+	return UsersSSNValue{Plaintext: plaintext, Valid: true}
+}
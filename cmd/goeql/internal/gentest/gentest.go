@@ -0,0 +1,5 @@
+// Package gentest holds code generated by goeql gen from testdata/schema.yaml,
+// so the generated code is compiled and tested with the rest of the module.
+package gentest
+
+//go:generate go run github.com/cipherstash/goeql/cmd/goeql gen -schema ../../testdata/schema.yaml -package gentest -o columns_eql.go
@@ -0,0 +1,122 @@
+package gentest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cipherstash/goeql"
+)
+
+// Test the generated accessors round trip values and serialize queries
+func TestGenerated_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	data, err := UsersEmail.Encrypt(ctx, goeql.EncryptedText("alice@example.com"))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	email, err := UsersEmail.Decode(ctx, data)
+	if err != nil || email.Reveal() != "alice@example.com" {
+		t.Errorf("Expected alice@example.com, got %q, %v", email.Reveal(), err)
+	}
+
+	data, err = OrdersTotalCents.Encrypt(ctx, goeql.EncryptedInt(1999))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	total, err := OrdersTotalCents.Decode(ctx, data)
+	if err != nil || total.Reveal() != 1999 {
+		t.Errorf("Expected 1999, got %d, %v", total.Reveal(), err)
+	}
+
+	query, err := OrdersTotalCents.Ore(ctx, 1000)
+	if err != nil {
+		t.Fatalf("OreQuery returned error: %v", err)
+	}
+	expected := `{"k":"pt","p":"1000","i":{"t":"orders","c":"total_cents"},"v":1,"q":"ore"}`
+	if string(query) != expected {
+		t.Errorf("Expected %s, got %s", expected, query)
+	}
+
+	path, err := goeql.ParseJSONPath("$.plan")
+	if err != nil {
+		t.Fatalf("ParseJSONPath returned error: %v", err)
+	}
+	if _, err := UsersAttrs.EJsonPath(ctx, path); err != nil {
+		t.Errorf("EJsonPathQuery returned error: %v", err)
+	}
+}
+
+// Test jsonb accessors decode both JSON objects and arrays
+func TestGenerated_Jsonb(t *testing.T) {
+	ctx := context.Background()
+
+	data, err := UsersAttrs.Encrypt(ctx, goeql.EncryptedJsonb{"plan": "pro"})
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	value, err := UsersAttrs.Decode(ctx, data)
+	if attrs, ok := value.(goeql.EncryptedJsonb); err != nil || !ok || attrs["plan"] != "pro" {
+		t.Errorf("Expected an object with plan pro, got %#v, %v", value, err)
+	}
+
+	data, err = UsersAttrs.EncryptArray(ctx, goeql.EncryptedJsonbArray{"admin", "billing"})
+	if err != nil {
+		t.Fatalf("EncryptArray returned error: %v", err)
+	}
+	value, err = UsersAttrs.Decode(ctx, data)
+	if attrs, ok := value.(goeql.EncryptedJsonbArray); err != nil || !ok || len(attrs) != 2 || attrs[0] != "admin" {
+		t.Errorf("Expected the array [admin billing], got %#v, %v", value, err)
+	}
+
+	var scanned UsersAttrsArrayValue
+	if err := scanned.Scan(data); err != nil || !scanned.Valid || len(scanned.Plaintext) != 2 {
+		t.Errorf("Expected to scan the array, got %+v, %v", scanned, err)
+	}
+}
+
+// Test Decode rejects payloads for another column
+func TestGenerated_WrongColumn(t *testing.T) {
+	ctx := context.Background()
+
+	data, err := UsersSSN.Encrypt(ctx, goeql.EncryptedText("123-45-6789"))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	if _, err := UsersEmail.Decode(ctx, data); err == nil {
+		t.Errorf("Expected error decoding a users.ssn payload as users.email")
+	}
+}
+
+// Test the generated columns match the schema for use with a registry
+func TestGenerated_Columns(t *testing.T) {
+	registry, err := goeql.NewRegistry(UsersEmail.Column(), UsersAge.Column(), UsersSSN.Column())
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	if err := registry.CheckQuery(UsersTable, UsersSSNColumn, "match"); !errors.Is(err, goeql.ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery for users.ssn, got %v", err)
+	}
+}
+
+// Test the generated SQL values encode through goeql
+func TestGenerated_SQLValue(t *testing.T) {
+	value, err := NewOrdersShippedValue(false).Value()
+	if err != nil {
+		t.Fatalf("Value returned error: %v", err)
+	}
+	expected := `{"k":"pt","p":"false","i":{"t":"orders","c":"shipped"},"v":1,"q":null}`
+	if value != expected {
+		t.Errorf("Expected %s, got %v", expected, value)
+	}
+
+	var shipped OrdersShippedValue
+	if err := shipped.Scan([]byte(expected)); err != nil || !shipped.Valid || shipped.Plaintext.Reveal() {
+		t.Errorf("Expected a valid false, got %+v, %v", shipped, err)
+	}
+	var email UsersEmailValue
+	if err := email.Scan([]byte(expected)); err == nil {
+		t.Errorf("Expected error scanning an orders.shipped payload into users.email")
+	}
+}
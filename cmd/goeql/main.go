@@ -0,0 +1,458 @@
+// Command goeql encodes, decodes, validates and pretty-prints EQL payloads.
+//
+// Usage:
+//
+//	goeql encode -table users -column email [-query match] [-type text] [plaintext]
+//	goeql decode [-type text] [payload]
+//	goeql validate [payload]
+//	goeql pretty [-json] [payload]
+//	goeql gen -schema schema.yaml -package models [-o columns_eql.go]
+//	goeql gen -schema schema.yaml -sqlc example.com/app/models
+//
+// The plaintext or payload is read from standard input when it is omitted or "-",
+// so payloads can be piped straight out of psql.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cipherstash/goeql"
+)
+
+const usage = `usage: goeql <command> [flags] [input]
+
+commands:
+  encode    encode a plaintext into an EQL payload for a table and column
+  decode    decode a plaintext payload back to its plaintext value
+  validate  check the shape and version of a plaintext or ciphertext payload
+  pretty    pretty-print a plaintext or ciphertext payload
+  gen       generate typed column accessors from a schema file
+
+Input is read from stdin when omitted or "-". Run "goeql <command> -h" for flags.
+`
+
+// errUsage is returned for invalid arguments, after the usage has been printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the exit status
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = io.WriteString(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func([]string, io.Reader, io.Writer, io.Writer) error{
+		"encode":   encode,
+		"decode":   decode,
+		"validate": validate,
+		"pretty":   pretty,
+		"gen":      gen,
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "goeql: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdin, stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		_, _ = fmt.Fprintf(stderr, "goeql %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("goeql "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// readInput returns the single positional argument, or stdin when it is omitted or "-"
+func readInput(fs *flag.FlagSet, stdin io.Reader) ([]byte, error) {
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		if fs.Arg(0) != "-" {
+			return []byte(fs.Arg(0)), nil
+		}
+	default:
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %v", err)
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+func encode(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("encode", stderr)
+	table := fs.String("table", "", "EQL table name (required)")
+	column := fs.String("column", "", "EQL column name (required)")
+	query := fs.String("query", "", "query type: match, ore, unique, ste_vec or ejson_path (default: a value to store)")
+	valueType := fs.String("type", "text", "plaintext type: text, int, bool, jsonb or jsonb_array")
+	keysetID := fs.String("keyset-id", "", "keyset ID to include in the payload")
+	keysetName := fs.String("keyset-name", "", "keyset name to include in the payload")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *table == "" || *column == "" {
+		_, _ = io.WriteString(stderr, "goeql encode: -table and -column are required\n")
+		fs.Usage()
+		return errUsage
+	}
+
+	input, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+	value, err := parsePlaintext(string(input), *valueType)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *keysetID != "" || *keysetName != "" {
+		ctx = goeql.WithKeyset(ctx, goeql.Keyset{ID: *keysetID, Name: *keysetName})
+	}
+
+	var payload []byte
+	switch *query {
+	case "":
+		val, err := goeql.ToEncryptedColumnContext(ctx, value, *table, *column, nil)
+		if err != nil {
+			return err
+		}
+		payload, err = json.Marshal(val)
+		if err != nil {
+			return err
+		}
+	case "match":
+		payload, err = goeql.MatchQueryContext(ctx, value, *table, *column)
+	case "ore":
+		payload, err = goeql.OreQueryContext(ctx, value, *table, *column)
+	case "unique":
+		payload, err = goeql.UniqueQueryContext(ctx, value, *table, *column)
+	case "ste_vec":
+		payload, err = goeql.JsonbQueryContext(ctx, value, *table, *column)
+	case "ejson_path":
+		payload, err = goeql.EJsonPathQueryContext(ctx, value, *table, *column)
+	default:
+		return fmt.Errorf("unknown query type %q", *query)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s\n", payload)
+	return err
+}
+
+// parsePlaintext converts the input to the Go type encoded for valueType
+func parsePlaintext(input string, valueType string) (any, error) {
+	switch valueType {
+	case "text":
+		return input, nil
+	case "int":
+		n, err := strconv.Atoi(strings.TrimSpace(input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid int plaintext")
+		}
+		return n, nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool plaintext")
+		}
+		return b, nil
+	case "jsonb":
+		var v map[string]any
+		if err := json.Unmarshal([]byte(input), &v); err != nil {
+			return nil, fmt.Errorf("invalid jsonb plaintext: %v", err)
+		}
+		return v, nil
+	case "jsonb_array":
+		var v []any
+		if err := json.Unmarshal([]byte(input), &v); err != nil {
+			return nil, fmt.Errorf("invalid jsonb_array plaintext: %v", err)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", valueType)
+}
+
+func decode(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	valueType := fs.String("type", "text", "plaintext type: text, int, bool, jsonb or jsonb_array")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	var plaintext any
+	switch *valueType {
+	case "text":
+		var et goeql.EncryptedText
+		v, err := et.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		plaintext = v.Reveal()
+	case "int":
+		var ei goeql.EncryptedInt
+		v, err := ei.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		plaintext = v.Reveal()
+	case "bool":
+		var eb goeql.EncryptedBool
+		v, err := eb.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		plaintext = v.Reveal()
+	case "jsonb":
+		var ej goeql.EncryptedJsonb
+		v, err := ej.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		return writeJSON(stdout, v.Reveal())
+	case "jsonb_array":
+		var eja goeql.EncryptedJsonbArray
+		v, err := eja.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		return writeJSON(stdout, v.Reveal())
+	default:
+		return fmt.Errorf("unknown type %q", *valueType)
+	}
+
+	_, err = fmt.Fprintln(stdout, plaintext)
+	return err
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// record holds the fields of a plaintext or ciphertext payload
+type record struct {
+	K  string             `json:"k"`
+	P  *string            `json:"p"`
+	C  *string            `json:"c"`
+	I  *goeql.TableColumn `json:"i"`
+	V  *int               `json:"v"`
+	Q  any                `json:"q"`
+	KS *goeql.Keyset      `json:"ks"`
+
+	// Index terms of ciphertext records
+	Match  []any `json:"m"`
+	Ore    []any `json:"o"`
+	Unique any   `json:"u"`
+	SteVec []any `json:"sv"`
+
+	// Fields not known to goeql, kept for pretty-printing
+	extra map[string]json.RawMessage
+}
+
+// parseRecord parses and validates a payload
+func parseRecord(data []byte) (record, error) {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if err := json.Unmarshal(data, &r.extra); err != nil {
+		return r, fmt.Errorf("invalid format: payload must be a JSON object")
+	}
+	for _, known := range []string{"k", "p", "c", "i", "v", "q", "ks", "m", "o", "u", "sv"} {
+		delete(r.extra, known)
+	}
+
+	switch r.K {
+	case "pt":
+		if _, err := goeql.DecodePayload(data); err != nil {
+			return r, err
+		}
+	case "ct":
+		if r.C == nil || *r.C == "" {
+			return r, fmt.Errorf("invalid format: missing 'c' field")
+		}
+		if r.I == nil || r.I.T == "" || r.I.C == "" {
+			return r, fmt.Errorf("invalid format: missing table or column in 'i' field")
+		}
+		if r.V == nil {
+			return r, fmt.Errorf("invalid format: missing 'v' field")
+		}
+		if goeql.EQLVersion(*r.V).Validate() != nil {
+			return r, fmt.Errorf("invalid format: unsupported payload version %d", *r.V)
+		}
+	case "":
+		return r, fmt.Errorf("invalid format: missing 'k' field")
+	default:
+		return r, fmt.Errorf("invalid format: unsupported payload kind %q", r.K)
+	}
+	if r.KS != nil {
+		if err := r.KS.Validate(); err != nil {
+			return r, fmt.Errorf("invalid format: %v", err)
+		}
+	}
+	return r, nil
+}
+
+func validate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("validate", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRecord(data)
+	if err != nil {
+		return err
+	}
+	kind := "plaintext"
+	if r.K == "ct" {
+		kind = "ciphertext"
+	}
+	_, err = fmt.Fprintf(stdout, "valid %s payload for %s.%s, version %d\n", kind, r.I.T, r.I.C, *r.V)
+	return err
+}
+
+func pretty(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("pretty", stderr)
+	asJSON := fs.Bool("json", false, "print indented JSON instead of a summary")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRecord(data)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", out.Bytes())
+		return err
+	}
+
+	var b strings.Builder
+	line := func(label string, value string) {
+		fmt.Fprintf(&b, "%-12s %s\n", label+":", value)
+	}
+	line("kind", r.K)
+	line("table", r.I.T)
+	line("column", r.I.C)
+	line("version", strconv.Itoa(*r.V))
+	if r.KS != nil {
+		if r.KS.ID != "" {
+			line("keyset", "id "+r.KS.ID)
+		} else {
+			line("keyset", "name "+r.KS.Name)
+		}
+	}
+	if r.K == "pt" {
+		line("plaintext", strconv.Quote(*r.P))
+		switch q := r.Q.(type) {
+		case nil:
+		case string:
+			line("query", q)
+		default:
+			// Query descriptors are printed as compact JSON
+			data, err := json.Marshal(q)
+			if err != nil {
+				return err
+			}
+			line("query", string(data))
+		}
+	} else {
+		line("ciphertext", fmt.Sprintf("%s (%d chars)", abbreviate(*r.C, 32), len(*r.C)))
+		line("indexes", indexSummary(r))
+	}
+	if len(r.extra) > 0 {
+		keys := make([]string, 0, len(r.extra))
+		for k := range r.extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		line("other", strings.Join(keys, ", "))
+	}
+
+	_, err = io.WriteString(stdout, b.String())
+	return err
+}
+
+// indexSummary lists the index terms present in a ciphertext record
+func indexSummary(r record) string {
+	var indexes []string
+	if r.Match != nil {
+		indexes = append(indexes, "match ("+count(len(r.Match), "term")+")")
+	}
+	if r.Ore != nil {
+		indexes = append(indexes, "ore ("+count(len(r.Ore), "term")+")")
+	}
+	if r.Unique != nil {
+		indexes = append(indexes, "unique")
+	}
+	if r.SteVec != nil {
+		indexes = append(indexes, "ste_vec ("+count(len(r.SteVec), "entry")+")")
+	}
+	if len(indexes) == 0 {
+		return "none"
+	}
+	return strings.Join(indexes, ", ")
+}
+
+// count formats n with noun, pluralized unless n is 1
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "y") {
+		return strconv.Itoa(n) + " " + strings.TrimSuffix(noun, "y") + "ies"
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+func abbreviate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
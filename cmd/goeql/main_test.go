@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+// Test encode produces payloads for values and queries
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{
+			[]string{"encode", "-table", "users", "-column", "email", "alice@example.com"},
+			"",
+			`{"k":"pt","p":"alice@example.com","i":{"t":"users","c":"email"},"v":1,"q":null}`,
+		},
+		{
+			[]string{"encode", "-table", "users", "-column", "email", "-query", "match"},
+			"alice\n",
+			`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":"match"}`,
+		},
+		{
+			[]string{"encode", "-table", "users", "-column", "age", "-type", "int", "-query", "ore", "42"},
+			"",
+			`{"k":"pt","p":"42","i":{"t":"users","c":"age"},"v":1,"q":"ore"}`,
+		},
+		{
+			[]string{"encode", "-table", "users", "-column", "active", "-type", "bool", "false"},
+			"",
+			`{"k":"pt","p":"false","i":{"t":"users","c":"active"},"v":1,"q":null}`,
+		},
+		{
+			[]string{"encode", "-table", "users", "-column", "email", "-keyset-name", "tenant-acme", "alice"},
+			"",
+			`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null,"ks":{"name":"tenant-acme"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, code := runCommand(t, tt.stdin, tt.args...)
+		if code != 0 {
+			t.Fatalf("%v exited with %d: %s", tt.args, code, stderr)
+		}
+		if strings.TrimSpace(stdout) != tt.expected {
+			t.Errorf("%v: expected %s, got %s", tt.args, tt.expected, stdout)
+		}
+	}
+}
+
+// Test encode rejects invalid arguments
+func TestEncode_Invalid(t *testing.T) {
+	if _, _, code := runCommand(t, "", "encode", "-column", "email", "alice"); code != 2 {
+		t.Errorf("Expected exit 2 without -table, got %d", code)
+	}
+	if _, stderr, code := runCommand(t, "", "encode", "-table", "users", "-column", "age", "-type", "int", "abc"); code != 1 || strings.Contains(stderr, "abc") {
+		t.Errorf("Expected exit 1 without the plaintext in the error, got %d: %s", code, stderr)
+	}
+	if _, _, code := runCommand(t, "", "encode", "-table", "users", "-column", "email", "-query", "like", "alice"); code != 1 {
+		t.Errorf("Expected exit 1 for an unknown query type, got %d", code)
+	}
+}
+
+// Test decode applies the type-specific Deserialize rules
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		valueType string
+		payload   string
+		expected  string
+	}{
+		{"text", `{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null}`, "alice\n"},
+		{"int", `{"k":"pt","p":"42","i":{"t":"users","c":"age"},"v":1,"q":null}`, "42\n"},
+		{"bool", `{"k":"pt","p":"true","i":{"t":"users","c":"active"},"v":1,"q":null}`, "true\n"},
+		{"jsonb", `{"k":"pt","p":"{\"a\":1}","i":{"t":"users","c":"data"},"v":1,"q":null}`, "{\n  \"a\": 1\n}\n"},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, code := runCommand(t, tt.payload+"\n", "decode", "-type", tt.valueType)
+		if code != 0 {
+			t.Fatalf("decode -type %s exited with %d: %s", tt.valueType, code, stderr)
+		}
+		if stdout != tt.expected {
+			t.Errorf("decode -type %s: expected %q, got %q", tt.valueType, tt.expected, stdout)
+		}
+	}
+
+	if _, _, code := runCommand(t, "", "decode", "-type", "int", `{"k":"pt","p":"x","i":{"t":"users","c":"age"},"v":1,"q":null}`); code != 1 {
+		t.Errorf("Expected exit 1 for an invalid int, got %d", code)
+	}
+}
+
+// Test validate checks plaintext and ciphertext payloads
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		payload string
+		valid   bool
+	}{
+		{`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null}`, true},
+		{`{"k":"ct","c":"mBbK","u":"abc","i":{"t":"users","c":"email"},"v":1}`, true},
+		{`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":3,"q":null}`, false},
+		{`{"k":"ct","i":{"t":"users","c":"email"},"v":1}`, false},
+		{`{"k":"xx","v":1}`, false},
+		{`{"k":"pt","p":"alice","i":{"t":"users"},"v":1}`, false},
+		{`not json`, false},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, code := runCommand(t, "", "validate", tt.payload)
+		if tt.valid && (code != 0 || !strings.HasPrefix(stdout, "valid")) {
+			t.Errorf("Expected %s to be valid, got %d: %s", tt.payload, code, stderr)
+		}
+		if !tt.valid && code != 1 {
+			t.Errorf("Expected %s to be invalid, got %d", tt.payload, code)
+		}
+	}
+}
+
+// Test pretty summarizes ciphertext records
+func TestPretty(t *testing.T) {
+	payload := `{"k":"ct","c":"mBbKSqWLK8MMbbLYqAqCRoWPFDXBSEdnUcmBCXkNxbsGSbZf","m":[1,2,3],"o":["a"],"u":"abc","i":{"t":"users","c":"email"},"v":1}`
+	stdout, stderr, code := runCommand(t, "", "pretty", payload)
+	if code != 0 {
+		t.Fatalf("pretty exited with %d: %s", code, stderr)
+	}
+	for _, expected := range []string{
+		"kind:        ct\n",
+		"table:       users\n",
+		"column:      email\n",
+		"ciphertext:  mBbKSqWLK8MMbbLYqAqCRoWPFDXBSEdn... (48 chars)\n",
+		"indexes:     match (3 terms), ore (1 term), unique\n",
+	} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout)
+		}
+	}
+
+	stdout, _, code = runCommand(t, payload, "pretty", "-json")
+	if code != 0 || !strings.Contains(stdout, "\n  \"k\": \"ct\",\n") {
+		t.Errorf("Expected indented JSON, got %d:\n%s", code, stdout)
+	}
+}
+
+// Test unknown commands print the usage
+func TestRun_Usage(t *testing.T) {
+	if _, stderr, code := runCommand(t, "", "frobnicate"); code != 2 || !strings.Contains(stderr, "usage: goeql") {
+		t.Errorf("Expected usage and exit 2, got %d: %s", code, stderr)
+	}
+	if _, _, code := runCommand(t, ""); code != 2 {
+		t.Errorf("Expected exit 2 without a command, got %d", code)
+	}
+}
@@ -0,0 +1,217 @@
+package goeql
+
+// Column methods serialize values and queries for a declared column, so the
+// table and column identity travel together instead of as positional strings
+// that are easy to swap.
+//
+// Each method has a Context variant that carries the keyset, and anything
+// else the caller attaches to the context, through to the serializers.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// NewColumn returns a text column declaration for table and column
+func NewColumn(table string, column string, indexes ...IndexType) Column {
+	return Column{Table: table, Name: column, Cast: CastText, Indexes: indexes}
+}
+
+// Identifier returns the table and column identity used in payloads
+func (c Column) Identifier() TableColumn {
+	return TableColumn{T: c.Table, C: c.Name}
+}
+
+// Encrypt serializes value into a jsonb payload for the column.
+// Encrypted* values keep the NULL semantics of their Serialize method, and nil serializes to nil.
+func (c Column) Encrypt(value any) ([]byte, error) {
+	return c.EncryptContext(context.Background(), value)
+}
+
+// EncryptContext serializes value into a jsonb payload for the column, using the keyset from ctx
+// unless the column declares its own
+func (c Column) EncryptContext(ctx context.Context, value any) ([]byte, error) {
+	if err := c.checkIdentity(); err != nil {
+		return nil, err
+	}
+	ctx = c.context(ctx)
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case EncryptedText:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	case EncryptedJsonb:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	case EncryptedJsonbArray:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	case EncryptedInt:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	case EncryptedBool:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	case *SecretText:
+		return v.SerializeContext(ctx, c.Table, c.Name)
+	}
+
+	return encryptValue(ctx, value, c.Table, c.Name)
+}
+
+// Match serializes a plaintext value used in a match query on the column
+func (c Column) Match(value any) ([]byte, error) {
+	return c.MatchContext(context.Background(), value)
+}
+
+// MatchContext serializes a plaintext value used in a match query on the column, using the keyset from ctx
+func (c Column) MatchContext(ctx context.Context, value any) ([]byte, error) {
+	return c.query(ctx, value, MatchDescriptor{})
+}
+
+// Ore serializes a plaintext value used in an ore query on the column
+func (c Column) Ore(value any) ([]byte, error) {
+	return c.OreContext(context.Background(), value)
+}
+
+// OreContext serializes a plaintext value used in an ore query on the column, using the keyset from ctx
+func (c Column) OreContext(ctx context.Context, value any) ([]byte, error) {
+	return c.query(ctx, value, OreDescriptor{})
+}
+
+// Unique serializes a plaintext value used in a unique query on the column
+func (c Column) Unique(value any) ([]byte, error) {
+	return c.UniqueContext(context.Background(), value)
+}
+
+// UniqueContext serializes a plaintext value used in a unique query on the column, using the keyset from ctx
+func (c Column) UniqueContext(ctx context.Context, value any) ([]byte, error) {
+	return c.query(ctx, value, UniqueDescriptor{})
+}
+
+// Jsonb serializes a plaintext value used in a jsonb query on the column
+func (c Column) Jsonb(value any) ([]byte, error) {
+	return c.JsonbContext(context.Background(), value)
+}
+
+// JsonbContext serializes a plaintext value used in a jsonb query on the column, using the keyset from ctx
+func (c Column) JsonbContext(ctx context.Context, value any) ([]byte, error) {
+	return c.query(ctx, value, SteVecDescriptor{})
+}
+
+// EJsonPath serializes an ejson path used in an ejson path query on the column
+func (c Column) EJsonPath(path any) ([]byte, error) {
+	return c.EJsonPathContext(context.Background(), path)
+}
+
+// EJsonPathContext serializes an ejson path used in an ejson path query on the column,
+// using the keyset from ctx
+func (c Column) EJsonPathContext(ctx context.Context, path any) ([]byte, error) {
+	if err := c.checkQuery("ejson_path"); err != nil {
+		return nil, err
+	}
+	return EJsonPathQueryContext(c.context(ctx), path, c.Table, c.Name)
+}
+
+// Query serializes a plaintext value used in the query described by q on the column
+func (c Column) Query(value any, q QueryDescriptor) ([]byte, error) {
+	return c.QueryContext(context.Background(), value, q)
+}
+
+// QueryContext serializes a plaintext value used in the query described by q on the column,
+// using the keyset from ctx
+func (c Column) QueryContext(ctx context.Context, value any, q QueryDescriptor) ([]byte, error) {
+	if q == nil {
+		return nil, fmt.Errorf("invalid query descriptor: nil")
+	}
+	if err := c.checkQuery(q.QueryType()); err != nil {
+		return nil, err
+	}
+	return QueryContext(c.context(ctx), value, c.Table, c.Name, q)
+}
+
+// Decode decodes a payload for the column into the Encrypted* type for its cast:
+// EncryptedInt for integer casts, EncryptedBool for boolean, EncryptedJsonb or
+// EncryptedJsonbArray for jsonb, and EncryptedText otherwise. An error is returned
+// if the payload identifies a different table or column. An empty payload decodes to nil.
+func (c Column) Decode(data []byte) (any, error) {
+	return c.DecodeContext(context.Background(), data)
+}
+
+// DecodeContext decodes a payload for the column
+func (c Column) DecodeContext(ctx context.Context, data []byte) (_ any, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	var d Decoder
+	h, err := d.scanCall(ctx, call, data)
+	if err != nil {
+		return nil, err
+	}
+	if string(h.t) != c.Table || string(h.c) != c.Name {
+		return nil, fmt.Errorf("payload for %s.%s does not belong to column %s.%s", h.t, h.c, c.Table, c.Name)
+	}
+
+	switch c.Cast {
+	case CastInt, CastSmallInt, CastBigInt:
+		return h.int()
+	case CastBoolean:
+		return h.bool()
+	case CastJsonb:
+		var pData any
+		if err := json.Unmarshal(h.p, &pData); err != nil {
+			return nil, fmt.Errorf("error unmarshaling 'p' JSON string: %v", err)
+		}
+		switch p := pData.(type) {
+		case map[string]any:
+			return EncryptedJsonb(p), nil
+		case []any:
+			return EncryptedJsonbArray(p), nil
+		}
+		return nil, fmt.Errorf("invalid format: 'p' field must be a JSON object or array")
+	default:
+		return EncryptedText(h.p), nil
+	}
+}
+
+// query checks the column serves the query type and serializes the query value
+func (c Column) query(ctx context.Context, value any, q QueryDescriptor) ([]byte, error) {
+	if err := c.checkQuery(q.QueryType()); err != nil {
+		return nil, err
+	}
+	return serializeQuery(c.context(ctx), value, c.Table, c.Name, q)
+}
+
+// checkQuery returns an error if the column declares indexes and none of them serves the query type.
+// Columns without declared indexes are checked against the installed registry only.
+func (c Column) checkQuery(queryType string) error {
+	if err := c.checkIdentity(); err != nil {
+		return err
+	}
+	if len(c.Indexes) > 0 && !c.HasIndex(queryIndexes[queryType]) {
+		return fmt.Errorf("%w: %s.%s does not support %s queries", ErrUnsupportedQuery, c.Table, c.Name, queryType)
+	}
+	return nil
+}
+
+func (c Column) checkIdentity() error {
+	if c.Table == "" || c.Name == "" {
+		return fmt.Errorf("invalid column %q.%q: table and column name are required", c.Table, c.Name)
+	}
+	return nil
+}
+
+// context returns ctx carrying the column's keyset and EQL version, if it declares them
+func (c Column) context(ctx context.Context) context.Context {
+	if c.Keyset != nil {
+		ctx = WithKeyset(ctx, *c.Keyset)
+	}
+	if c.Version != 0 {
+		ctx = WithEQLVersion(ctx, c.Version)
+	}
+	return ctx
+}
@@ -0,0 +1,158 @@
+package goeql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Test Column.Encrypt matches Serialize for the same identity
+func TestColumn_Encrypt(t *testing.T) {
+	col := NewColumn("users", "email")
+
+	tests := []struct {
+		value    any
+		expected func() ([]byte, error)
+	}{
+		{"alice@example.com", func() ([]byte, error) { return EncryptedText("alice@example.com").Serialize("users", "email") }},
+		{EncryptedText("alice@example.com"), func() ([]byte, error) { return EncryptedText("alice@example.com").Serialize("users", "email") }},
+		{42, func() ([]byte, error) { return EncryptedInt(42).Serialize("users", "email") }},
+		{EncryptedBool(false), func() ([]byte, error) { return EncryptedBool(false).Serialize("users", "email") }},
+		{nil, func() ([]byte, error) { return nil, nil }},
+	}
+
+	for _, tt := range tests {
+		expected, err := tt.expected()
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		got, err := col.Encrypt(tt.value)
+		if err != nil {
+			t.Fatalf("Encrypt(%#v) returned error: %v", tt.value, err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("Encrypt(%#v): expected %s, got %s", tt.value, expected, got)
+		}
+	}
+}
+
+// Test Column query methods match the package query functions
+func TestColumn_Queries(t *testing.T) {
+	col := NewColumn("users", "data")
+
+	tests := []struct {
+		name     string
+		got      func() ([]byte, error)
+		expected func() ([]byte, error)
+	}{
+		{"Match", func() ([]byte, error) { return col.Match("alice") }, func() ([]byte, error) { return MatchQuery("alice", "users", "data") }},
+		{"Ore", func() ([]byte, error) { return col.Ore(42) }, func() ([]byte, error) { return OreQuery(42, "users", "data") }},
+		{"Unique", func() ([]byte, error) { return col.Unique("alice") }, func() ([]byte, error) { return UniqueQuery("alice", "users", "data") }},
+		{"Jsonb", func() ([]byte, error) { return col.Jsonb(map[string]any{"a": 1}) }, func() ([]byte, error) { return JsonbQuery(map[string]any{"a": 1}, "users", "data") }},
+		{"EJsonPath", func() ([]byte, error) { return col.EJsonPath("$.a") }, func() ([]byte, error) { return EJsonPathQuery("$.a", "users", "data") }},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.got()
+		if err != nil {
+			t.Fatalf("%s returned error: %v", tt.name, err)
+		}
+		expected, err := tt.expected()
+		if err != nil {
+			t.Fatalf("%s returned error: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, expected, got)
+		}
+	}
+}
+
+// Test Column query methods reject queries the declared indexes cannot serve
+func TestColumn_UnsupportedQuery(t *testing.T) {
+	col := NewColumn("users", "email", UniqueIndex)
+
+	if _, err := col.Unique("alice"); err != nil {
+		t.Errorf("Unique returned error: %v", err)
+	}
+	if _, err := col.Match("alice"); !errors.Is(err, ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery, got %v", err)
+	}
+	if _, err := col.EJsonPath("$.a"); !errors.Is(err, ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery, got %v", err)
+	}
+	if _, err := (Column{Table: "users"}).Encrypt("alice"); err == nil {
+		t.Errorf("Expected error for column without a name")
+	}
+}
+
+// Test the column keyset takes precedence over the context keyset
+func TestColumn_Keyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), KeysetName("tenant-acme"))
+
+	col := NewColumn("users", "email")
+	got, err := col.EncryptContext(ctx, "alice")
+	if err != nil {
+		t.Fatalf("EncryptContext returned error: %v", err)
+	}
+	if !bytes.Contains(got, []byte(`"ks":{"name":"tenant-acme"}`)) {
+		t.Errorf("Expected context keyset, got %s", got)
+	}
+
+	keyset := KeysetID("shared")
+	col.Keyset = &keyset
+	got, err = col.MatchContext(ctx, "alice")
+	if err != nil {
+		t.Fatalf("MatchQueryContext returned error: %v", err)
+	}
+	if !bytes.Contains(got, []byte(`"ks":{"id":"shared"}`)) {
+		t.Errorf("Expected column keyset, got %s", got)
+	}
+}
+
+// Test Column.Decode returns the Encrypted* type for the column cast
+func TestColumn_Decode(t *testing.T) {
+	tests := []struct {
+		cast     CastType
+		value    any
+		expected any
+	}{
+		{CastText, EncryptedText("alice"), EncryptedText("alice")},
+		{CastBigInt, EncryptedInt(42), EncryptedInt(42)},
+		{CastBoolean, EncryptedBool(true), EncryptedBool(true)},
+		{CastJsonb, EncryptedJsonb{"a": "b"}, EncryptedJsonb{"a": "b"}},
+		{CastJsonb, `["a","b"]`, EncryptedJsonbArray{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		col := Column{Table: "users", Name: "data", Cast: tt.cast}
+		data, err := col.Encrypt(tt.value)
+		if err != nil {
+			t.Fatalf("Encrypt returned error: %v", err)
+		}
+		got, err := col.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode returned error: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Decode for %s: expected %#v, got %#v", tt.cast, tt.expected, got)
+		}
+	}
+}
+
+// Test Column.Decode rejects payloads for another column
+func TestColumn_Decode_WrongColumn(t *testing.T) {
+	data, err := EncryptedText("alice").Serialize("users", "name")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	if _, err := NewColumn("users", "email").Decode(data); err == nil {
+		t.Errorf("Expected error decoding a payload for another column")
+	}
+
+	got, err := NewColumn("users", "email").Decode(nil)
+	if err != nil || got != nil {
+		t.Errorf("Expected empty payload to decode to nil, got %#v, %v", got, err)
+	}
+}
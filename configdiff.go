@@ -0,0 +1,267 @@
+package goeql
+
+// Reads the live EQL configuration from cs_configuration_v1, or eql_v2_configuration
+// for EQL v2, and compares it with the columns declared in a Registry, so
+// configuration drift can be detected when a service starts rather than when a
+// query fails.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// activeConfigQuery selects the active EQL configuration of the version
+func activeConfigQuery(v EQLVersion) string {
+	return "SELECT data FROM " + v.sql().configuration + " WHERE state = 'active'"
+}
+
+// EQLConfig is the EQL configuration document stored in cs_configuration_v1
+type EQLConfig struct {
+	V      int                                `json:"v"`
+	Tables map[string]map[string]ColumnConfig `json:"tables"`
+}
+
+// ColumnConfig is the configuration of a single encrypted column
+type ColumnConfig struct {
+	CastAs  string                     `json:"cast_as"`
+	Indexes map[string]json.RawMessage `json:"indexes"`
+}
+
+// ParseConfig parses an EQL configuration document
+func ParseConfig(data []byte) (EQLConfig, error) {
+	var cfg EQLConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return EQLConfig{}, fmt.Errorf("error unmarshaling EQL configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+// ParseConfigYAML parses an EQL configuration document written in YAML, e.g. a schema file
+func ParseConfigYAML(data []byte) (EQLConfig, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return EQLConfig{}, fmt.Errorf("error unmarshaling EQL configuration: %v", err)
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return EQLConfig{}, fmt.Errorf("error converting EQL configuration to JSON: %v", err)
+	}
+	return ParseConfig(jsonData)
+}
+
+// ReadConfigFile reads an EQL configuration document, parsing files with a .yaml or .yml
+// extension as YAML and others as JSON
+func ReadConfigFile(path string) (EQLConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EQLConfig{}, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseConfigYAML(data)
+	default:
+		return ParseConfig(data)
+	}
+}
+
+// Columns returns the column declarations in the configuration, ordered by table and column name
+func (cfg EQLConfig) Columns() ([]Column, error) {
+	var columns []Column
+	for table, tableColumns := range cfg.Tables {
+		for name, configured := range tableColumns {
+			c := Column{Table: table, Name: name, Cast: CastType(configured.CastAs)}
+			for index, opts := range configured.Indexes {
+				c.Indexes = append(c.Indexes, IndexType(index))
+				if err := c.setIndexOptions(IndexType(index), opts); err != nil {
+					return nil, err
+				}
+			}
+			sort.Slice(c.Indexes, func(i, j int) bool { return c.Indexes[i] < c.Indexes[j] })
+			if err := c.Validate(); err != nil {
+				return nil, err
+			}
+			columns = append(columns, c)
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].Table != columns[j].Table {
+			return columns[i].Table < columns[j].Table
+		}
+		return columns[i].Name < columns[j].Name
+	})
+	return columns, nil
+}
+
+// setIndexOptions sets the match or ste_vec options configured for an index
+func (c *Column) setIndexOptions(index IndexType, opts json.RawMessage) error {
+	var target any
+	switch index {
+	case MatchIndex:
+		c.MatchOptions = &MatchOptions{}
+		target = c.MatchOptions
+	case SteVecIndex:
+		c.SteVec = &SteVecOptions{}
+		target = c.SteVec
+	default:
+		return nil
+	}
+	if len(opts) == 0 || string(opts) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(opts, target); err != nil {
+		return fmt.Errorf("invalid %s options for %s.%s: %v", index, c.Table, c.Name, err)
+	}
+	return nil
+}
+
+// RowScanner is a single result row, implemented by *sql.Row and pgx.Row
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// QueryRowFunc runs a query expected to return at most one row.
+//
+// With pgx, wrap the connection or pool:
+//
+//	func(ctx context.Context, query string, args ...any) goeql.RowScanner {
+//		return conn.QueryRow(ctx, query, args...)
+//	}
+type QueryRowFunc func(ctx context.Context, query string, args ...any) RowScanner
+
+// SQLQueryRow adapts a *sql.DB, *sql.Conn or *sql.Tx to a QueryRowFunc
+func SQLQueryRow(db interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}) QueryRowFunc {
+	return func(ctx context.Context, query string, args ...any) RowScanner {
+		return db.QueryRowContext(ctx, query, args...)
+	}
+}
+
+// LoadConfig reads the active EQL configuration from the database, from the configuration
+// table of the version set with UseEQLVersion
+func LoadConfig(ctx context.Context, queryRow QueryRowFunc) (EQLConfig, error) {
+	return DefaultEQLVersion().LoadConfig(ctx, queryRow)
+}
+
+// LoadConfig reads the active EQL configuration from the configuration table of the version
+func (v EQLVersion) LoadConfig(ctx context.Context, queryRow QueryRowFunc) (EQLConfig, error) {
+	if err := v.Validate(); err != nil {
+		return EQLConfig{}, err
+	}
+	var data []byte
+	if err := queryRow(ctx, activeConfigQuery(v)).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EQLConfig{}, fmt.Errorf("no active EQL configuration found")
+		}
+		return EQLConfig{}, fmt.Errorf("error reading EQL configuration: %v", err)
+	}
+	return ParseConfig(data)
+}
+
+// DriftKind describes how the live configuration differs from a declared column
+type DriftKind string
+
+const (
+	// MissingColumn means the column is declared but not configured
+	MissingColumn DriftKind = "missing_column"
+	// MissingIndex means an index is declared but not configured
+	MissingIndex DriftKind = "missing_index"
+	// CastMismatch means the column is configured with a different cast type
+	CastMismatch DriftKind = "cast_mismatch"
+)
+
+// Drift is a single difference between a declared column and the live configuration
+type Drift struct {
+	Kind     DriftKind
+	Table    string
+	Column   string
+	Index    IndexType
+	Expected string
+	Actual   string
+}
+
+func (d Drift) String() string {
+	switch d.Kind {
+	case MissingColumn:
+		return fmt.Sprintf("%s.%s: column is not configured", d.Table, d.Column)
+	case MissingIndex:
+		return fmt.Sprintf("%s.%s: %s index is not configured", d.Table, d.Column, d.Index)
+	case CastMismatch:
+		return fmt.Sprintf("%s.%s: cast type is %s, expected %s", d.Table, d.Column, d.Actual, d.Expected)
+	default:
+		return fmt.Sprintf("%s.%s: %s", d.Table, d.Column, d.Kind)
+	}
+}
+
+// DriftError reports every difference found between declared columns and the live configuration
+type DriftError struct {
+	Drifts []Drift
+}
+
+func (e *DriftError) Error() string {
+	lines := make([]string, len(e.Drifts))
+	for i, d := range e.Drifts {
+		lines[i] = d.String()
+	}
+	return "EQL configuration drift: " + strings.Join(lines, "; ")
+}
+
+// DiffConfig compares declared columns with an EQL configuration, reporting missing
+// columns, missing indexes and cast type mismatches. Configured columns and indexes
+// that are not declared are ignored.
+func DiffConfig(cfg EQLConfig, columns ...Column) []Drift {
+	var drifts []Drift
+	for _, c := range columns {
+		configured, ok := cfg.Tables[c.Table][c.Name]
+		if !ok {
+			drifts = append(drifts, Drift{Kind: MissingColumn, Table: c.Table, Column: c.Name})
+			continue
+		}
+
+		if configured.CastAs != string(c.Cast) {
+			drifts = append(drifts, Drift{Kind: CastMismatch, Table: c.Table, Column: c.Name, Expected: string(c.Cast), Actual: configured.CastAs})
+		}
+
+		indexes := append([]IndexType(nil), c.Indexes...)
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+		for _, index := range indexes {
+			if _, ok := configured.Indexes[string(index)]; !ok {
+				drifts = append(drifts, Drift{Kind: MissingIndex, Table: c.Table, Column: c.Name, Index: index})
+			}
+		}
+	}
+	return drifts
+}
+
+// Diff compares the registry's columns with an EQL configuration
+func (r *Registry) Diff(cfg EQLConfig) []Drift {
+	return DiffConfig(cfg, r.Columns()...)
+}
+
+// Verify loads the active EQL configuration and returns a *DriftError if it does
+// not match the registry's columns. Columns are compared with the configuration of
+// their Version, or the version set with UseEQLVersion.
+func (r *Registry) Verify(ctx context.Context, queryRow QueryRowFunc) error {
+	versions, byVersion := columnsByVersion(r.Columns())
+	var drifts []Drift
+	for _, v := range versions {
+		cfg, err := v.LoadConfig(ctx, queryRow)
+		if err != nil {
+			return err
+		}
+		drifts = append(drifts, DiffConfig(cfg, byVersion[v]...)...)
+	}
+	if len(drifts) > 0 {
+		return &DriftError{Drifts: drifts}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package goeql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadConfigFixture(t *testing.T) EQLConfig {
+	t.Helper()
+	data, err := os.ReadFile("testdata/cs_configuration_v1.json")
+	if err != nil {
+		t.Fatalf("Error reading configuration fixture: %v", err)
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	return cfg
+}
+
+// Test ParseConfigYAML parses the same document as ParseConfig
+func TestParseConfigYAML(t *testing.T) {
+	cfg, err := ParseConfigYAML([]byte(`
+v: 1
+tables:
+  users:
+    age:
+      cast_as: int
+      indexes:
+        ore: {}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigYAML returned error: %v", err)
+	}
+	if cfg.V != 1 || cfg.Tables["users"]["age"].CastAs != "int" {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+	if _, ok := cfg.Tables["users"]["age"].Indexes["ore"]; !ok {
+		t.Errorf("Expected users.age to have an ore index")
+	}
+
+	if _, err := ParseConfigYAML([]byte("tables: [")); err == nil {
+		t.Errorf("Expected error parsing invalid YAML, but got none")
+	}
+}
+
+// Test ReadConfigFile parses JSON and YAML files by extension
+func TestReadConfigFile(t *testing.T) {
+	cfg, err := ReadConfigFile("testdata/cs_configuration_v1.json")
+	if err != nil {
+		t.Fatalf("ReadConfigFile returned error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, loadConfigFixture(t)) {
+		t.Errorf("Expected the JSON fixture, got %+v", cfg)
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.yml")
+	if err := os.WriteFile(path, []byte("v: 1\ntables:\n  users:\n    age:\n      cast_as: int\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = ReadConfigFile(path)
+	if err != nil {
+		t.Fatalf("ReadConfigFile returned error: %v", err)
+	}
+	if cfg.Tables["users"]["age"].CastAs != "int" {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+
+	if _, err := ReadConfigFile("testdata/missing.json"); err == nil {
+		t.Errorf("Expected error reading a missing file, but got none")
+	}
+}
+
+// Test EQLConfig.Columns returns ordered column declarations with their index options
+func TestEQLConfig_Columns(t *testing.T) {
+	columns, err := loadConfigFixture(t).Columns()
+	if err != nil {
+		t.Fatalf("Columns returned error: %v", err)
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Table + "." + c.Name
+	}
+	if expected := []string{"users.age", "users.attrs", "users.email"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected columns %v, got %v", expected, names)
+	}
+
+	email := columns[2]
+	if email.Cast != CastText || !reflect.DeepEqual(email.Indexes, []IndexType{MatchIndex, UniqueIndex}) {
+		t.Errorf("Unexpected users.email declaration %+v", email)
+	}
+	if email.MatchOptions == nil || email.MatchOptions.K != 6 || email.MatchOptions.Tokenizer.Kind != "ngram" {
+		t.Errorf("Expected users.email match options, got %+v", email.MatchOptions)
+	}
+	if columns[1].SteVec == nil || columns[1].SteVec.Prefix != "users/attrs" {
+		t.Errorf("Expected users.attrs ste_vec prefix, got %+v", columns[1].SteVec)
+	}
+	if len(DiffConfig(loadConfigFixture(t), columns...)) != 0 {
+		t.Errorf("Expected columns read from the configuration not to drift from it")
+	}
+
+	cfg := EQLConfig{V: 1, Tables: map[string]map[string]ColumnConfig{"users": {"email": {CastAs: "varchar"}}}}
+	if _, err := cfg.Columns(); err == nil {
+		t.Errorf("Expected error for an unknown cast type")
+	}
+}
+
+// fixtureRow is a RowScanner returning a fixed value or error
+type fixtureRow struct {
+	data []byte
+	err  error
+}
+
+func (r fixtureRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*(dest[0].(*[]byte)) = r.data
+	return nil
+}
+
+// Test ParseConfig
+func TestParseConfig(t *testing.T) {
+	cfg := loadConfigFixture(t)
+
+	if cfg.V != 1 {
+		t.Errorf("Expected config version 1, got %d", cfg.V)
+	}
+	email, ok := cfg.Tables["users"]["email"]
+	if !ok {
+		t.Fatalf("Expected users.email to be configured")
+	}
+	if email.CastAs != "text" {
+		t.Errorf("Expected users.email cast to be text, got %s", email.CastAs)
+	}
+	if _, ok := email.Indexes["match"]; !ok {
+		t.Errorf("Expected users.email to have a match index")
+	}
+
+	if _, err := ParseConfig([]byte("not json")); err == nil {
+		t.Errorf("Expected error parsing invalid configuration, but got none")
+	}
+}
+
+// Test DiffConfig reports drift
+func TestDiffConfig(t *testing.T) {
+	cfg := loadConfigFixture(t)
+
+	columns := []Column{
+		{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{UniqueIndex, MatchIndex}},
+		{Table: "users", Name: "age", Cast: CastSmallInt, Indexes: []IndexType{OreIndex, UniqueIndex}},
+		{Table: "users", Name: "phone", Cast: CastText},
+		{Table: "orders", Name: "total", Cast: CastInt},
+	}
+
+	expected := []Drift{
+		{Kind: CastMismatch, Table: "users", Column: "age", Expected: "small_int", Actual: "int"},
+		{Kind: MissingIndex, Table: "users", Column: "age", Index: UniqueIndex},
+		{Kind: MissingColumn, Table: "users", Column: "phone"},
+		{Kind: MissingColumn, Table: "orders", Column: "total"},
+	}
+
+	drifts := DiffConfig(cfg, columns...)
+	if !reflect.DeepEqual(drifts, expected) {
+		t.Errorf("Expected drifts %+v, got %+v", expected, drifts)
+	}
+
+	if drifts := DiffConfig(cfg, columns[0]); len(drifts) != 0 {
+		t.Errorf("Expected no drift for users.email, got %+v", drifts)
+	}
+}
+
+// Test Registry Verify against a configuration row
+func TestRegistry_Verify(t *testing.T) {
+	data, err := os.ReadFile("testdata/cs_configuration_v1.json")
+	if err != nil {
+		t.Fatalf("Error reading configuration fixture: %v", err)
+	}
+	queryRow := func(ctx context.Context, query string, args ...any) RowScanner {
+		return fixtureRow{data: data}
+	}
+
+	r := testRegistry(t)
+	if err := r.Verify(context.Background(), queryRow); err != nil {
+		t.Errorf("Verify returned error: %v", err)
+	}
+
+	if err := r.Register(Column{Table: "users", Name: "phone", Cast: CastText}); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	err = r.Verify(context.Background(), queryRow)
+	var driftErr *DriftError
+	if !errors.As(err, &driftErr) {
+		t.Fatalf("Expected a DriftError, got %v", err)
+	}
+	if len(driftErr.Drifts) != 1 || driftErr.Drifts[0].Kind != MissingColumn {
+		t.Errorf("Expected a single missing column drift, got %+v", driftErr.Drifts)
+	}
+
+	noRows := func(ctx context.Context, query string, args ...any) RowScanner {
+		return fixtureRow{err: sql.ErrNoRows}
+	}
+	if err := r.Verify(context.Background(), noRows); err == nil {
+		t.Errorf("Expected error without an active configuration, but got none")
+	}
+}
+
+// Test Registry Verify compares each column with the configuration of its EQL version
+func TestRegistry_Verify_Versions(t *testing.T) {
+	v1 := []byte(`{"v":1,"tables":{"users":{"name":{"cast_as":"text","indexes":{}}}}}`)
+	v2 := []byte(`{"v":1,"tables":{"users":{"email":{"cast_as":"text","indexes":{"unique":{}}}}}}`)
+	var queries []string
+	queryRow := func(ctx context.Context, query string, args ...any) RowScanner {
+		queries = append(queries, query)
+		if strings.Contains(query, "eql_v2_configuration") {
+			return fixtureRow{data: v2}
+		}
+		return fixtureRow{data: v1}
+	}
+
+	r, err := NewRegistry(
+		Column{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{UniqueIndex}, Version: EQLv2},
+		Column{Table: "users", Name: "name", Cast: CastText},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	if err := r.Verify(context.Background(), queryRow); err != nil {
+		t.Errorf("Verify returned error: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("Expected the configuration of each version to be read, got %q", queries)
+	}
+}
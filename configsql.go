@@ -0,0 +1,236 @@
+package goeql
+
+// Generates the EQL configuration SQL for declared columns, so that the database
+// configuration and the Go types using it come from a single source of truth.
+//
+// The generated SQL is idempotent: each cs_add_column_v1 and cs_add_index_v1 call
+// is guarded by a check against cs_configuration_v1, and cs_encrypt_v1 and
+// cs_activate_v1 only run when there is a configuration waiting for them. The
+// SQL for EQL v2 uses the equivalent eql_v2 functions and configuration table.
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Tokenizer configures how the match index splits plaintext into tokens
+type Tokenizer struct {
+	// Kind is the tokenizer, either "ngram" or "standard"
+	Kind string `json:"kind"`
+	// TokenLength is the ngram length, only used by the ngram tokenizer
+	TokenLength int `json:"token_length,omitempty"`
+}
+
+// TokenFilter configures a filter applied to each token, e.g. "downcase"
+type TokenFilter struct {
+	Kind string `json:"kind"`
+}
+
+// MatchOptions configures a match index
+type MatchOptions struct {
+	Tokenizer       *Tokenizer    `json:"tokenizer,omitempty"`
+	TokenFilters    []TokenFilter `json:"token_filters,omitempty"`
+	K               int           `json:"k,omitempty"`
+	M               int           `json:"m,omitempty"`
+	IncludeOriginal bool          `json:"include_original,omitempty"`
+}
+
+// SteVecOptions configures a ste_vec index
+type SteVecOptions struct {
+	// Prefix is mixed into every term of the ste_vec, and defaults to "table/column"
+	Prefix string `json:"prefix"`
+}
+
+// indexOptions returns the EQL options document for an index on the column
+func (c Column) indexOptions(index IndexType) ([]byte, error) {
+	switch {
+	case index == MatchIndex && c.MatchOptions != nil:
+		return json.Marshal(c.MatchOptions)
+	case index == SteVecIndex:
+		opts := SteVecOptions{Prefix: c.Table + "/" + c.Name}
+		if c.SteVec != nil && c.SteVec.Prefix != "" {
+			opts.Prefix = c.SteVec.Prefix
+		}
+		return json.Marshal(opts)
+	default:
+		return []byte("{}"), nil
+	}
+}
+
+// ConfigSQL generates the idempotent EQL configuration SQL for the registry's columns
+func (r *Registry) ConfigSQL() (string, error) {
+	return ConfigSQL(r.Columns()...)
+}
+
+// ConfigSQL generates the idempotent EQL configuration SQL that adds each column and
+// its indexes, then encrypts and activates the configuration. Columns are configured
+// for their Version, or the version set with UseEQLVersion, and each version present
+// gets its own section of SQL.
+func ConfigSQL(columns ...Column) (string, error) {
+	versions, byVersion := columnsByVersion(columns)
+
+	var b strings.Builder
+	b.WriteString("-- EQL configuration generated by goeql\n")
+	for _, v := range versions {
+		if err := v.writeConfigSQL(&b, byVersion[v]); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// ConfigSQL generates the idempotent EQL configuration SQL for the columns in the version,
+// returning an error for columns declaring another version
+func (v EQLVersion) ConfigSQL(columns ...Column) (string, error) {
+	if err := v.Validate(); err != nil {
+		return "", err
+	}
+	for _, c := range columns {
+		if c.Version != 0 && c.Version != v {
+			return "", fmt.Errorf("column %s.%s is declared for EQL v%d, not v%d", c.Table, c.Name, c.Version, v)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("-- EQL configuration generated by goeql\n")
+	if err := v.writeConfigSQL(&b, columns); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// columnsByVersion groups columns by their version, or the default version, returning the versions in order
+func columnsByVersion(columns []Column) ([]EQLVersion, map[EQLVersion][]Column) {
+	byVersion := make(map[EQLVersion][]Column)
+	for _, c := range columns {
+		v := c.Version
+		if v == 0 {
+			v = DefaultEQLVersion()
+		}
+		byVersion[v] = append(byVersion[v], c)
+	}
+	versions := make([]EQLVersion, 0, len(byVersion))
+	for v := range byVersion {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	if len(versions) == 0 {
+		versions = append(versions, DefaultEQLVersion())
+	}
+	return versions, byVersion
+}
+
+// writeConfigSQL writes the configuration SQL for the columns in the version
+func (v EQLVersion) writeConfigSQL(b *strings.Builder, columns []Column) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	names := v.sql()
+
+	for _, c := range columns {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+
+		table, column, cast := quoteLiteral(c.Table), quoteLiteral(c.Name), quoteLiteral(string(c.Cast))
+		columnPath := fmt.Sprintf("data->'tables'->%s", table)
+
+		fmt.Fprintf(b, "\nSELECT %s(%s, %s, %s)\n", names.addColumn, table, column, cast)
+		fmt.Fprintf(b, "  WHERE NOT EXISTS (SELECT 1 FROM %s WHERE state IN ('pending', 'active') AND %s ? %s);\n", names.configuration, columnPath, column)
+
+		for _, index := range c.Indexes {
+			opts, err := c.indexOptions(index)
+			if err != nil {
+				return fmt.Errorf("error marshaling %s index options for %s.%s: %v", index, c.Table, c.Name, err)
+			}
+			name := quoteLiteral(string(index))
+
+			fmt.Fprintf(b, "SELECT %s(%s, %s, %s, %s, %s)\n", names.addIndex, table, column, name, cast, quoteLiteral(string(opts)))
+			fmt.Fprintf(b, "  WHERE NOT EXISTS (SELECT 1 FROM %s WHERE state IN ('pending', 'active') AND %s->%s->'indexes' ? %s);\n", names.configuration, columnPath, column, name)
+		}
+	}
+
+	fmt.Fprintf(b, "\nSELECT %s() WHERE EXISTS (SELECT 1 FROM %s WHERE state = 'pending');\n", names.encrypt, names.configuration)
+	fmt.Fprintf(b, "SELECT %s() WHERE EXISTS (SELECT 1 FROM %s WHERE state = 'encrypting');\n", names.activate, names.configuration)
+	return nil
+}
+
+// quoteLiteral quotes s as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// RegisterStruct declares a column for each field of the struct v tagged with `eql`.
+//
+// The tag holds the column name followed by the enabled indexes, and optionally the
+// cast type. When the cast is omitted it is inferred from the field's Encrypted* type:
+//
+//	type User struct {
+//		Email goeql.EncryptedText `eql:"email,match,unique"`
+//		Age   goeql.EncryptedInt  `eql:"age,ore,cast=small_int"`
+//	}
+func (r *Registry) RegisterStruct(table string, v any) error {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("error registering %s: expected a struct, got %T", table, v)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("eql")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		c, err := parseColumnTag(table, field.Type, tag)
+		if err != nil {
+			return fmt.Errorf("error registering field %s: %v", field.Name, err)
+		}
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseColumnTag parses an `eql` struct tag into a column declaration
+func parseColumnTag(table string, fieldType reflect.Type, tag string) (Column, error) {
+	parts := strings.Split(tag, ",")
+	c := Column{Table: table, Name: strings.TrimSpace(parts[0]), Cast: castForType(fieldType)}
+
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if cast, ok := strings.CutPrefix(part, "cast="); ok {
+			c.Cast = CastType(cast)
+			continue
+		}
+		c.Indexes = append(c.Indexes, IndexType(part))
+	}
+
+	if c.Cast == "" {
+		return Column{}, fmt.Errorf("cannot infer cast type for %s, add cast= to the eql tag", fieldType)
+	}
+	return c, nil
+}
+
+// castForType infers the EQL cast type for an Encrypted* type
+func castForType(t reflect.Type) CastType {
+	switch t {
+	case reflect.TypeOf(EncryptedText("")):
+		return CastText
+	case reflect.TypeOf(EncryptedInt(0)):
+		return CastInt
+	case reflect.TypeOf(EncryptedBool(false)):
+		return CastBoolean
+	case reflect.TypeOf(EncryptedJsonb(nil)), reflect.TypeOf(EncryptedJsonbArray(nil)):
+		return CastJsonb
+	default:
+		return ""
+	}
+}
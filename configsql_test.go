@@ -0,0 +1,172 @@
+package goeql
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test ConfigSQL generation
+func TestConfigSQL(t *testing.T) {
+	columns := []Column{
+		{
+			Table:   "users",
+			Name:    "email",
+			Cast:    CastText,
+			Indexes: []IndexType{UniqueIndex, MatchIndex},
+			MatchOptions: &MatchOptions{
+				Tokenizer:       &Tokenizer{Kind: "ngram", TokenLength: 3},
+				TokenFilters:    []TokenFilter{{Kind: "downcase"}},
+				K:               6,
+				M:               2048,
+				IncludeOriginal: true,
+			},
+		},
+		{Table: "users", Name: "attrs", Cast: CastJsonb, Indexes: []IndexType{SteVecIndex}},
+	}
+
+	sql, err := ConfigSQL(columns...)
+	if err != nil {
+		t.Fatalf("ConfigSQL returned error: %v", err)
+	}
+
+	expected := `-- EQL configuration generated by goeql
+
+SELECT cs_add_column_v1('users', 'email', 'text')
+  WHERE NOT EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state IN ('pending', 'active') AND data->'tables'->'users' ? 'email');
+SELECT cs_add_index_v1('users', 'email', 'unique', 'text', '{}')
+  WHERE NOT EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state IN ('pending', 'active') AND data->'tables'->'users'->'email'->'indexes' ? 'unique');
+SELECT cs_add_index_v1('users', 'email', 'match', 'text', '{"tokenizer":{"kind":"ngram","token_length":3},"token_filters":[{"kind":"downcase"}],"k":6,"m":2048,"include_original":true}')
+  WHERE NOT EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state IN ('pending', 'active') AND data->'tables'->'users'->'email'->'indexes' ? 'match');
+
+SELECT cs_add_column_v1('users', 'attrs', 'jsonb')
+  WHERE NOT EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state IN ('pending', 'active') AND data->'tables'->'users' ? 'attrs');
+SELECT cs_add_index_v1('users', 'attrs', 'ste_vec', 'jsonb', '{"prefix":"users/attrs"}')
+  WHERE NOT EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state IN ('pending', 'active') AND data->'tables'->'users'->'attrs'->'indexes' ? 'ste_vec');
+
+SELECT cs_encrypt_v1() WHERE EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state = 'pending');
+SELECT cs_activate_v1() WHERE EXISTS (SELECT 1 FROM cs_configuration_v1 WHERE state = 'encrypting');
+`
+
+	if sql != expected {
+		t.Errorf("Unexpected configuration SQL, expected:\n%s\ngot:\n%s", expected, sql)
+	}
+}
+
+// Test ConfigSQL quotes identifiers as literals
+func TestConfigSQL_Quoting(t *testing.T) {
+	sql, err := ConfigSQL(Column{Table: "o'brien", Name: "notes", Cast: CastText})
+	if err != nil {
+		t.Fatalf("ConfigSQL returned error: %v", err)
+	}
+	if !strings.Contains(sql, "cs_add_column_v1('o''brien', 'notes', 'text')") {
+		t.Errorf("Expected quoted table name in SQL, got:\n%s", sql)
+	}
+
+	if _, err := ConfigSQL(Column{Table: "users", Name: "notes", Cast: "varchar"}); err == nil {
+		t.Errorf("Expected error for invalid column, but got none")
+	}
+}
+
+// Test ConfigSQL configures each column for its own EQL version
+func TestConfigSQL_Versions(t *testing.T) {
+	columns := []Column{
+		{Table: "users", Name: "email", Cast: CastText, Version: EQLv2},
+		{Table: "users", Name: "name", Cast: CastText},
+	}
+	sql, err := ConfigSQL(columns...)
+	if err != nil {
+		t.Fatalf("ConfigSQL returned error: %v", err)
+	}
+	v1 := strings.Index(sql, "cs_add_column_v1('users', 'name', 'text')")
+	v2 := strings.Index(sql, "eql_v2.add_column('users', 'email', 'text')")
+	if v1 < 0 || v2 < v1 || strings.Contains(sql, "cs_add_column_v1('users', 'email'") {
+		t.Errorf("Expected a v1 section for users.name followed by a v2 section for users.email, got:\n%s", sql)
+	}
+	if !strings.Contains(sql, "SELECT cs_activate_v1()") || !strings.Contains(sql, "SELECT eql_v2.activate_config()") {
+		t.Errorf("Expected both configurations to be activated, got:\n%s", sql)
+	}
+
+	if _, err := EQLv2.ConfigSQL(columns...); err != nil {
+		t.Errorf("Expected EQLv2.ConfigSQL to configure columns without a version, got %v", err)
+	}
+	if _, err := EQLv1.ConfigSQL(columns...); err == nil {
+		t.Errorf("Expected EQLv1.ConfigSQL to reject a column declared for v2, got none")
+	}
+}
+
+// Test Registry struct tag declarations
+func TestRegistry_RegisterStruct(t *testing.T) {
+	type user struct {
+		ID     int
+		Email  EncryptedText       `eql:"email,match,unique"`
+		Age    EncryptedInt        `eql:"age,ore,cast=small_int"`
+		Active EncryptedBool       `eql:"active"`
+		Attrs  EncryptedJsonb      `eql:"attrs,ste_vec"`
+		Tags   EncryptedJsonbArray `eql:"tags"`
+		Secret string              `eql:"-"`
+	}
+
+	r, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	if err := r.RegisterStruct("users", &user{}); err != nil {
+		t.Fatalf("RegisterStruct returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cast    CastType
+		indexes []IndexType
+	}{
+		{name: "email", cast: CastText, indexes: []IndexType{MatchIndex, UniqueIndex}},
+		{name: "age", cast: CastSmallInt, indexes: []IndexType{OreIndex}},
+		{name: "active", cast: CastBoolean},
+		{name: "attrs", cast: CastJsonb, indexes: []IndexType{SteVecIndex}},
+		{name: "tags", cast: CastJsonb},
+	}
+
+	if len(r.Columns()) != len(tests) {
+		t.Errorf("Expected %d columns, got %d", len(tests), len(r.Columns()))
+	}
+	for _, tt := range tests {
+		c, ok := r.Lookup("users", tt.name)
+		if !ok {
+			t.Errorf("Expected users.%s to be registered", tt.name)
+			continue
+		}
+		if c.Cast != tt.cast {
+			t.Errorf("Expected users.%s cast to be %s, got %s", tt.name, tt.cast, c.Cast)
+		}
+		for _, index := range tt.indexes {
+			if !c.HasIndex(index) {
+				t.Errorf("Expected users.%s to have %s index", tt.name, index)
+			}
+		}
+	}
+}
+
+// Test Registry struct tag errors
+func TestRegistry_RegisterStruct_Error(t *testing.T) {
+	r, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+
+	type untyped struct {
+		Email string `eql:"email"`
+	}
+	type badIndex struct {
+		Email EncryptedText `eql:"email,bloom"`
+	}
+
+	if err := r.RegisterStruct("users", untyped{}); err == nil {
+		t.Errorf("Expected error for field without an inferable cast, but got none")
+	}
+	if err := r.RegisterStruct("users", badIndex{}); err == nil {
+		t.Errorf("Expected error for unknown index, but got none")
+	}
+	if err := r.RegisterStruct("users", "not a struct"); err == nil {
+		t.Errorf("Expected error for non struct value, but got none")
+	}
+}
@@ -0,0 +1,601 @@
+package goeql
+
+// Single pass decoding of EQL payloads returned by CipherStash Proxy.
+//
+// The Deserialize methods unmarshal every payload into a map[string]interface{}
+// just to read "p", and jsonb values then unmarshal "p" a second time. A Decoder
+// scans the payload bytes once, validates the "k", "i" and "v" fields, accepting
+// every supported EQL version, and decodes "p" directly into the target type. A
+// Decoder reuses its scratch buffer between calls, so one Decoder per goroutine
+// suits scanning large result sets.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Payload is a decoded EQL plaintext payload
+type Payload struct {
+	K string
+	P string
+	I TableColumn
+	V int
+	// Q describes the query of a query payload, and is nil for stored values
+	Q QueryDescriptor
+}
+
+// Decoder decodes EQL payloads in a single pass. The zero value is ready to use.
+// A Decoder must not be used concurrently.
+type Decoder struct {
+	scratch []byte
+}
+
+// header holds the fields scanned from a payload. Byte slices alias the payload
+// or the decoder's scratch buffer and are only valid until the next call.
+type header struct {
+	k, p, t, c       []byte
+	q                []byte
+	v                int
+	hasK, hasP, hasV bool
+	hasI             bool
+}
+
+// Decode decodes and validates a payload
+func (d *Decoder) Decode(data []byte) (_ Payload, err error) {
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return Payload{}, err
+	}
+	q, err := ParseQueryDescriptor(h.q)
+	if err != nil {
+		return Payload{}, fmt.Errorf("invalid format: %v", err)
+	}
+	return Payload{K: string(h.k), P: string(h.p), I: TableColumn{T: string(h.t), C: string(h.c)}, V: h.v, Q: q}, nil
+}
+
+// Text decodes a payload into an EncryptedText. An empty payload decodes to the zero value.
+func (d *Decoder) Text(data []byte) (_ EncryptedText, err error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return "", err
+	}
+	return EncryptedText(h.p), nil
+}
+
+// Int decodes a payload into an EncryptedInt
+func (d *Decoder) Int(data []byte) (_ EncryptedInt, err error) {
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return 0, err
+	}
+	return h.int()
+}
+
+// Bool decodes a payload into an EncryptedBool, accepting the same values as strconv.ParseBool
+func (d *Decoder) Bool(data []byte) (_ EncryptedBool, err error) {
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return false, err
+	}
+	return h.bool()
+}
+
+// Jsonb decodes a payload into an EncryptedJsonb. An empty payload decodes to nil.
+func (d *Decoder) Jsonb(data []byte) (_ EncryptedJsonb, err error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return nil, err
+	}
+	var pData map[string]interface{}
+	if err := json.Unmarshal(h.p, &pData); err != nil {
+		return nil, fmt.Errorf("error unmarshaling 'p' JSON string: %v", err)
+	}
+	return EncryptedJsonb(pData), nil
+}
+
+// JsonbArray decodes a payload into an EncryptedJsonbArray. An empty payload decodes to nil.
+func (d *Decoder) JsonbArray(data []byte) (_ EncryptedJsonbArray, err error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	call := startHooks(context.Background(), OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return nil, err
+	}
+	var pData []interface{}
+	if err := json.Unmarshal(h.p, &pData); err != nil {
+		return nil, fmt.Errorf("error unmarshaling 'p' JSON string: %v", err)
+	}
+	return EncryptedJsonbArray(pData), nil
+}
+
+// DecodePayload decodes and validates a payload
+func DecodePayload(data []byte) (Payload, error) {
+	var d Decoder
+	return d.Decode(data)
+}
+
+// scanCall parses the payload envelope, validates its fields, sets the column identity of
+// the hook call and checks the installed policy allows the principal in ctx to decode it
+func (d *Decoder) scanCall(ctx context.Context, call *hookCall, data []byte) (header, error) {
+	h, err := d.scanPayload(data)
+	if err != nil {
+		return h, err
+	}
+	if call != nil {
+		call.identify(string(h.t), string(h.c))
+	}
+	if err := checkDecodePolicy(ctx, h.t, h.c); err != nil {
+		return header{}, err
+	}
+	return h, nil
+}
+
+// scanPayload parses the payload envelope and validates its fields
+func (d *Decoder) scanPayload(data []byte) (header, error) {
+	var h header
+	s := scanner{data: data, scratch: d.scratch[:0]}
+	defer func() { d.scratch = s.scratch }()
+
+	s.skipWS()
+	if !s.consume('{') {
+		return h, s.errorf("expected '{'")
+	}
+	s.skipWS()
+	if !s.consume('}') {
+		for {
+			key, err := s.readString()
+			if err != nil {
+				return h, err
+			}
+			s.skipWS()
+			if !s.consume(':') {
+				return h, s.errorf("expected ':'")
+			}
+			s.skipWS()
+
+			switch string(key) {
+			case "k":
+				h.k, err = s.readString()
+				h.hasK = true
+			case "p":
+				if s.peek() != '"' {
+					return h, fmt.Errorf("invalid format: 'p' field must be a string")
+				}
+				h.p, err = s.readString()
+				h.hasP = true
+			case "v":
+				h.v, err = s.readInt()
+				h.hasV = true
+			case "i":
+				h.t, h.c, err = s.readIdentifier()
+				h.hasI = true
+			case "q":
+				start := s.pos
+				err = s.skipValue()
+				h.q = s.data[start:s.pos]
+			default:
+				err = s.skipValue()
+			}
+			if err != nil {
+				return h, err
+			}
+
+			s.skipWS()
+			if s.consume('}') {
+				break
+			}
+			if !s.consume(',') {
+				return h, s.errorf("expected ',' or '}'")
+			}
+			s.skipWS()
+		}
+	}
+	s.skipWS()
+	if s.pos != len(s.data) {
+		return h, s.errorf("unexpected data after payload")
+	}
+
+	return h, h.validate()
+}
+
+// int parses the plaintext as an EncryptedInt
+func (h *header) int() (EncryptedInt, error) {
+	n, ok := parseIntBytes(h.p)
+	if !ok {
+		return 0, fmt.Errorf("invalid number format in 'p' field")
+	}
+	return EncryptedInt(n), nil
+}
+
+// bool parses the plaintext as an EncryptedBool, accepting the same values as strconv.ParseBool
+func (h *header) bool() (EncryptedBool, error) {
+	switch string(h.p) {
+	case "1", "t", "T", "TRUE", "true", "True":
+		return true, nil
+	case "0", "f", "F", "FALSE", "false", "False":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean format in 'p' field")
+}
+
+func (h *header) validate() error {
+	if !h.hasK {
+		return fmt.Errorf("invalid format: missing 'k' field")
+	}
+	if string(h.k) != "pt" {
+		return fmt.Errorf("invalid format: unsupported payload kind %q", h.k)
+	}
+	if !h.hasI || len(h.t) == 0 || len(h.c) == 0 {
+		return fmt.Errorf("invalid format: missing table or column in 'i' field")
+	}
+	if !h.hasV {
+		return fmt.Errorf("invalid format: missing 'v' field")
+	}
+	if EQLVersion(h.v).Validate() != nil {
+		return fmt.Errorf("invalid format: unsupported payload version %d", h.v)
+	}
+	if !h.hasP {
+		return fmt.Errorf("invalid format: missing 'p' field")
+	}
+	return nil
+}
+
+// scanner reads JSON tokens from a payload, unescaping strings into scratch
+type scanner struct {
+	data    []byte
+	pos     int
+	scratch []byte
+}
+
+func (s *scanner) errorf(msg string) error {
+	return fmt.Errorf("invalid format: %s at offset %d", msg, s.pos)
+}
+
+func (s *scanner) skipWS() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *scanner) peek() byte {
+	if s.pos < len(s.data) {
+		return s.data[s.pos]
+	}
+	return 0
+}
+
+func (s *scanner) consume(c byte) bool {
+	if s.peek() == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// readString reads a JSON string. Strings without escapes or invalid UTF-8 are returned
+// as a slice of the payload, others are unescaped into the scratch buffer.
+func (s *scanner) readString() ([]byte, error) {
+	if !s.consume('"') {
+		return nil, s.errorf("expected string")
+	}
+
+	start := s.pos
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			raw := s.data[start:s.pos]
+			s.pos++
+			return raw, nil
+		case c == '\\':
+			s.pos = start
+			return s.unescape()
+		case c < 0x20:
+			return nil, s.errorf("control character in string")
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(s.data[s.pos:])
+			if r == utf8.RuneError && size == 1 {
+				s.pos = start
+				return s.unescape()
+			}
+			s.pos += size
+			continue
+		}
+		s.pos++
+	}
+	return nil, s.errorf("unterminated string")
+}
+
+// unescape reads the remainder of a string containing escapes or invalid UTF-8,
+// replacing invalid UTF-8 with U+FFFD as encoding/json does
+func (s *scanner) unescape() ([]byte, error) {
+	start := len(s.scratch)
+	dst := s.scratch
+	defer func() { s.scratch = dst }()
+
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			return dst[start:], nil
+		case c < 0x20:
+			return nil, s.errorf("control character in string")
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(s.data[s.pos:])
+			dst = utf8.AppendRune(dst, r)
+			s.pos += size
+		case c != '\\':
+			dst = append(dst, c)
+			s.pos++
+		default:
+			if s.pos+1 >= len(s.data) {
+				return nil, s.errorf("unterminated escape")
+			}
+			esc := s.data[s.pos+1]
+			s.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				dst = append(dst, esc)
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r, ok := s.readHex4()
+				if !ok {
+					return nil, s.errorf("invalid unicode escape")
+				}
+				if utf16.IsSurrogate(r) {
+					r2, ok := s.readSurrogate()
+					if r = utf16.DecodeRune(r, r2); !ok {
+						r = utf8.RuneError
+					}
+				}
+				dst = utf8.AppendRune(dst, r)
+			default:
+				return nil, s.errorf("invalid escape")
+			}
+		}
+	}
+	return nil, s.errorf("unterminated string")
+}
+
+func (s *scanner) readHex4() (rune, bool) {
+	if s.pos+4 > len(s.data) {
+		return 0, false
+	}
+	var r rune
+	for _, c := range s.data[s.pos : s.pos+4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	s.pos += 4
+	return r, true
+}
+
+// readSurrogate reads the \uXXXX low surrogate following a high surrogate, if present
+func (s *scanner) readSurrogate() (rune, bool) {
+	if s.pos+1 < len(s.data) && s.data[s.pos] == '\\' && s.data[s.pos+1] == 'u' {
+		start := s.pos
+		s.pos += 2
+		if r, ok := s.readHex4(); ok && r >= 0xDC00 && r <= 0xDFFF {
+			return r, true
+		}
+		s.pos = start
+	}
+	return 0, false
+}
+
+// readInt reads a JSON integer
+func (s *scanner) readInt() (int, error) {
+	start := s.pos
+	if s.peek() == '-' {
+		s.pos++
+	}
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	n, ok := parseIntBytes(s.data[start:s.pos])
+	if !ok {
+		s.pos = start
+		return 0, s.errorf("expected integer")
+	}
+	return int(n), nil
+}
+
+// readIdentifier reads the {"t","c"} table and column object
+func (s *scanner) readIdentifier() (table []byte, column []byte, err error) {
+	if !s.consume('{') {
+		return nil, nil, s.errorf("expected '{'")
+	}
+	s.skipWS()
+	if s.consume('}') {
+		return nil, nil, nil
+	}
+	for {
+		key, err := s.readString()
+		if err != nil {
+			return nil, nil, err
+		}
+		s.skipWS()
+		if !s.consume(':') {
+			return nil, nil, s.errorf("expected ':'")
+		}
+		s.skipWS()
+
+		switch string(key) {
+		case "t":
+			table, err = s.readString()
+		case "c":
+			column, err = s.readString()
+		default:
+			err = s.skipValue()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s.skipWS()
+		if s.consume('}') {
+			return table, column, nil
+		}
+		if !s.consume(',') {
+			return nil, nil, s.errorf("expected ',' or '}'")
+		}
+		s.skipWS()
+	}
+}
+
+// skipValue skips over any JSON value. Containers and literals are found by their
+// extent and then checked with json.Valid, so that payloads with malformed unknown
+// fields are rejected as they are by encoding/json.
+func (s *scanner) skipValue() error {
+	start := s.pos
+	switch c := s.peek(); {
+	case c == '"':
+		_, err := s.readString()
+		return err
+	case c == '{' || c == '[':
+		if err := s.skipContainer(); err != nil {
+			return err
+		}
+	default:
+		for s.pos < len(s.data) {
+			c := s.data[s.pos]
+			if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'E' {
+				s.pos++
+				continue
+			}
+			break
+		}
+		if s.pos == start {
+			return s.errorf("expected value")
+		}
+	}
+	if !json.Valid(s.data[start:s.pos]) {
+		s.pos = start
+		return s.errorf("invalid value")
+	}
+	return nil
+}
+
+// skipContainer skips to the end of a JSON object or array by counting brackets,
+// leaving skipValue to check the skipped span is valid
+func (s *scanner) skipContainer() error {
+	depth := 0
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '"':
+			if _, err := s.readString(); err != nil {
+				return err
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				s.pos++
+				return nil
+			}
+		}
+		s.pos++
+	}
+	return s.errorf("unterminated object or array")
+}
+
+// parseIntBytes parses a base 10 integer with the same rules as strconv.Atoi, without allocating
+func parseIntBytes(b []byte) (int64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	neg := false
+	switch b[0] {
+	case '-':
+		neg = true
+		b = b[1:]
+	case '+':
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return 0, false
+	}
+	for len(b) > 1 && b[0] == '0' {
+		b = b[1:]
+	}
+	if len(b) > 19 {
+		return 0, false
+	}
+
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+	}
+
+	const maxInt = uint64(1<<(strconvIntSize-1) - 1)
+	if neg {
+		if n > maxInt+1 {
+			return 0, false
+		}
+		return -int64(n), true
+	}
+	if n > maxInt {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// strconvIntSize is the size of int in bits
+const strconvIntSize = 32 << (^uint(0) >> 63)
@@ -0,0 +1,259 @@
+package goeql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var decodeTestStrings = []string{
+	"Hello, World!",
+	"",
+	`"quoted" \ backslash / slash`,
+	"<script>alert('x')</script> & more",
+	"line\nbreak\ttab\rreturn\bback\fform\x00",
+	"unicode \u4f60\u597d \U0001f510",
+	"separators \u2028 \u2029",
+}
+
+// Test Decoder matches EncryptedText Deserialize
+func TestDecoder_Text(t *testing.T) {
+	var d Decoder
+	for _, s := range decodeTestStrings {
+		data, err := MatchQuery(s, "test_table", "test_column")
+		if err != nil {
+			t.Fatalf("MatchQuery returned error: %v", err)
+		}
+
+		decoded, err := d.Text(data)
+		if err != nil {
+			t.Fatalf("Text returned error for %q: %v", s, err)
+		}
+		if decoded != EncryptedText(s) {
+			t.Errorf("Expected %q, got %q", s, decoded)
+		}
+	}
+
+	if decoded, err := d.Text(nil); err != nil || decoded != "" {
+		t.Errorf("Expected empty payload to decode to the zero value, got %q, %v", decoded, err)
+	}
+}
+
+// Test Decoder handles escapes produced by other encoders
+func TestDecoder_Escapes(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{p: `\u0041\u00e9\u4f60`, expected: "A\u00e9\u4f60"},
+		{p: `\ud83d\udd10`, expected: "\U0001f510"},
+		{p: `\ud83dA`, expected: "\ufffdA"},
+		{p: `\ud83d\u0041`, expected: "\ufffdA"},
+		{p: `\udd10`, expected: "\ufffd"},
+		{p: "invalid \xff utf8", expected: "invalid \ufffd utf8"},
+		{p: `\/\"\\`, expected: `/"\`},
+	}
+
+	var d Decoder
+	for _, tt := range tests {
+		data := []byte(`{"k":"pt","p":"` + tt.p + `","i":{"t":"t","c":"c"},"v":1,"q":null}`)
+
+		var expected map[string]any
+		if err := json.Unmarshal(data, &expected); err != nil {
+			t.Fatalf("Error unmarshaling %s: %v", data, err)
+		}
+		if expected["p"] != tt.expected {
+			t.Fatalf("Test case expectation %q does not match encoding/json %q", tt.expected, expected["p"])
+		}
+
+		payload, err := d.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode returned error for %s: %v", data, err)
+		}
+		if payload.P != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, payload.P)
+		}
+	}
+}
+
+// Test Decoder Decode returns the payload fields
+func TestDecodePayload(t *testing.T) {
+	data := []byte(` { "v" : 1, "q": {"t": "match", "nested": ["a", {"b": "}"}], "o": {"token_filters": [{"kind": "downcase"}]}}, "i": {"c": "test_column", "x": 1, "t": "test_table"}, "k": "pt", "p": "value" } `)
+
+	payload, err := DecodePayload(data)
+	if err != nil {
+		t.Fatalf("DecodePayload returned error: %v", err)
+	}
+
+	expected := Payload{
+		K: "pt", P: "value", I: TableColumn{T: "test_table", C: "test_column"}, V: 1,
+		Q: MatchDescriptor{Options: &MatchOptions{TokenFilters: []TokenFilter{{Kind: "downcase"}}}},
+	}
+	if !reflect.DeepEqual(payload, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, payload)
+	}
+}
+
+// Test Decoder validation errors
+func TestDecodePayload_Error(t *testing.T) {
+	payloads := []string{
+		``,
+		`[]`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1} extra`,
+		`{"p":"x","i":{"t":"t","c":"c"},"v":1}`,
+		`{"k":"ct","p":"x","i":{"t":"t","c":"c"},"v":1}`,
+		`{"k":"pt","p":"x","v":1}`,
+		`{"k":"pt","p":"x","i":{"t":"t"},"v":1}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"}}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":3}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1.5}`,
+		`{"k":"pt","i":{"t":"t","c":"c"},"v":1}`,
+		`{"k":"pt","p":1,"i":{"t":"t","c":"c"},"v":1}`,
+		`{"k":"pt","p":"unterminated,"i":{"t":"t","c":"c"},"v":1}`,
+		`{"k":"pt","p":"bad \q escape","i":{"t":"t","c":"c"},"v":1}`,
+		"{\"k\":\"pt\",\"p\":\"raw \n newline\",\"i\":{\"t\":\"t\",\"c\":\"c\"},\"v\":1}",
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1,"q":[1,2}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1,"q":"unknown"}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1,"junk":abc}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1,"junk":{]}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c","x":tru},"v":1}`,
+		`{"k":"pt","p":"x","i":{"t":"t","c":"c"},"v":1,"q":1.2.3}`,
+	}
+
+	for _, p := range payloads {
+		if _, err := DecodePayload([]byte(p)); err == nil {
+			t.Errorf("Expected error decoding %s, but got none", p)
+		}
+	}
+}
+
+// Test Decoder typed values match the Deserialize methods
+func TestDecoder_Typed(t *testing.T) {
+	var d Decoder
+
+	intData, _ := EncryptedInt(-42).Serialize("test_table", "test_column")
+	i, err := d.Int(intData)
+	if err != nil || i != -42 {
+		t.Errorf("Expected -42, got %d, %v", i, err)
+	}
+
+	boolData, _ := EncryptedBool(true).Serialize("test_table", "test_column")
+	b, err := d.Bool(boolData)
+	if err != nil || !b {
+		t.Errorf("Expected true, got %v, %v", b, err)
+	}
+
+	ej := EncryptedJsonb{"name": "Alice", "age": float64(30), "tags": []any{"a", "b"}}
+	jsonbData, _ := ej.Serialize("test_table", "test_column")
+	decodedJsonb, err := d.Jsonb(jsonbData)
+	if err != nil || !reflect.DeepEqual(decodedJsonb, ej) {
+		t.Errorf("Expected %v, got %v, %v", ej, decodedJsonb, err)
+	}
+
+	arrayData := []byte(`{"k":"pt","p":"[\"a\",1,true]","i":{"t":"t","c":"c"},"v":1,"q":null}`)
+	decodedArray, err := d.JsonbArray(arrayData)
+	if err != nil || !reflect.DeepEqual(decodedArray, EncryptedJsonbArray{"a", float64(1), true}) {
+		t.Errorf("Unexpected jsonb array %v, %v", decodedArray, err)
+	}
+
+	for _, p := range []string{"not_an_integer", "9223372036854775808", "", "1.5"} {
+		data, _ := MatchQuery(p, "t", "c")
+		if _, err := d.Int(data); err == nil {
+			t.Errorf("Expected error decoding int %q, but got none", p)
+		}
+	}
+	for _, p := range []string{"+7", "007", "-9223372036854775808"} {
+		data, _ := MatchQuery(p, "t", "c")
+		var ei EncryptedInt
+		expected, expectedErr := ei.Deserialize(data)
+		got, err := d.Int(data)
+		if got != expected || (err == nil) != (expectedErr == nil) {
+			t.Errorf("Expected %d, %v decoding int %q, got %d, %v", expected, expectedErr, p, got, err)
+		}
+	}
+
+	notBool, _ := MatchQuery("not_a_boolean", "t", "c")
+	if _, err := d.Bool(notBool); err == nil {
+		t.Errorf("Expected error decoding bool, but got none")
+	}
+
+	invalidJSON, _ := MatchQuery("invalid_json", "t", "c")
+	if _, err := d.Jsonb(invalidJSON); err == nil {
+		t.Errorf("Expected error decoding jsonb, but got none")
+	}
+}
+
+// Test Decoder does not allocate decoding ints
+func TestDecoder_Allocs(t *testing.T) {
+	var d Decoder
+	data, _ := EncryptedInt(42).Serialize("test_table", "test_column")
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = d.Int(data)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func benchmarkPayload(b *testing.B, value any) []byte {
+	data, err := MatchQuery(value, "users", "email")
+	if err != nil {
+		b.Fatalf("MatchQuery returned error: %v", err)
+	}
+	return data
+}
+
+func BenchmarkDecoder_Text(b *testing.B) {
+	data := benchmarkPayload(b, "alice@example.com")
+	var d Decoder
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = d.Text(data)
+	}
+}
+
+func BenchmarkEncryptedText_Deserialize(b *testing.B) {
+	data := benchmarkPayload(b, "alice@example.com")
+	var et EncryptedText
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = et.Deserialize(data)
+	}
+}
+
+func BenchmarkDecoder_Int(b *testing.B) {
+	data := benchmarkPayload(b, 1234567)
+	var d Decoder
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = d.Int(data)
+	}
+}
+
+func BenchmarkEncryptedInt_Deserialize(b *testing.B) {
+	data := benchmarkPayload(b, 1234567)
+	var ei EncryptedInt
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = ei.Deserialize(data)
+	}
+}
+
+func BenchmarkDecoder_Jsonb(b *testing.B) {
+	data := benchmarkPayload(b, map[string]any{"name": "Alice", "age": 30, "address": map[string]any{"city": "Sydney"}})
+	var d Decoder
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = d.Jsonb(data)
+	}
+}
+
+func BenchmarkEncryptedJsonb_Deserialize(b *testing.B) {
+	data := benchmarkPayload(b, map[string]any{"name": "Alice", "age": 30, "address": map[string]any{"city": "Sydney"}})
+	var ej EncryptedJsonb
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = ej.Deserialize(data)
+	}
+}
@@ -0,0 +1,450 @@
+package goeql
+
+// A reference implementation of EQL index semantics over plaintexts, for
+// previewing which values a query would match without a database.
+//
+// Match indexes tokenize values with the column's match options, EQL's
+// downcased trigrams by default, and set k bits per token in a bloom filter of
+// m bits, 6 of 2048 by default. A query matches when the value's filter has
+// every bit of the query's, the containment test cs_match_v1 performs. Bits are
+// placed by an unkeyed hash rather than the column's key, so the evaluation has
+// the false positive rate of the configured filters, but not the same false
+// positives as the database. Ore indexes order values by their
+// cast type, unique indexes match equal values, and ste_vec indexes follow the
+// containment rules of the jsonb @> operator. An ejson_path query with an
+// operator compares the value at its selector with the query plaintext.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// QueryOperator compares an encrypted column with a query payload
+type QueryOperator string
+
+const (
+	// OpEq matches values equal to a unique or ore query
+	OpEq QueryOperator = "="
+	// OpNe matches values not equal to a unique or ore query
+	OpNe QueryOperator = "<>"
+	// OpLt, OpLte, OpGt and OpGte compare values with an ore query
+	OpLt  QueryOperator = "<"
+	OpLte QueryOperator = "<="
+	OpGt  QueryOperator = ">"
+	OpGte QueryOperator = ">="
+	// OpContains matches values containing the tokens of a match query or the document of a ste_vec query
+	OpContains QueryOperator = "@>"
+)
+
+// queryOperators lists the operators each query type supports
+var queryOperators = map[string][]QueryOperator{
+	"unique":  {OpEq, OpNe},
+	"ore":     {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte},
+	"match":   {OpContains},
+	"ste_vec": {OpContains},
+}
+
+// Tokens returns the distinct tokens a match index with the options stores for s, in order.
+// Nil options use the EQL defaults, downcased trigrams.
+func (o *MatchOptions) Tokens(s string) []string {
+	set := o.tokens(s)
+	tokens := make([]string, 0, len(set))
+	for token := range set {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func (o *MatchOptions) tokens(s string) map[string]bool {
+	kind, length := "ngram", 3
+	filters := []TokenFilter{{Kind: "downcase"}}
+	includeOriginal := false
+	if o != nil {
+		if o.Tokenizer != nil {
+			kind = o.Tokenizer.Kind
+			if o.Tokenizer.TokenLength > 0 {
+				length = o.Tokenizer.TokenLength
+			}
+		}
+		if o.TokenFilters != nil {
+			filters = o.TokenFilters
+		}
+		includeOriginal = o.IncludeOriginal
+	}
+	for _, f := range filters {
+		if f.Kind == "downcase" {
+			s = strings.ToLower(s)
+		}
+	}
+
+	tokens := make(map[string]bool)
+	if includeOriginal {
+		tokens[s] = true
+	}
+	if kind == "standard" {
+		for _, word := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			tokens[word] = true
+		}
+		return tokens
+	}
+	runes := []rune(s)
+	for i := 0; i+length <= len(runes); i++ {
+		tokens[string(runes[i:i+length])] = true
+	}
+	return tokens
+}
+
+// Matches reports whether a match query for query matches value, i.e. the bloom filter of
+// value has every bit of the filter of query. A query too short to produce a token matches
+// every value, and a value without every query token can still match, like a false positive
+// in the database.
+func (o *MatchOptions) Matches(value string, query string) bool {
+	return hasBits(o.bloom(o.tokens(value)), o.bloom(o.tokens(query)))
+}
+
+// bloom returns the bits set in a bloom filter for tokens, k bits per token out of m.
+// The bits of each token are placed by double hashing its SHA-256 digest.
+func (o *MatchOptions) bloom(tokens map[string]bool) map[uint64]bool {
+	k, m := 6, 2048
+	if o != nil {
+		if o.K > 0 {
+			k = o.K
+		}
+		if o.M > 0 {
+			m = o.M
+		}
+	}
+
+	bits := make(map[uint64]bool, len(tokens)*k)
+	for token := range tokens {
+		sum := sha256.Sum256([]byte(token))
+		h1 := binary.BigEndian.Uint64(sum[0:8])
+		h2 := binary.BigEndian.Uint64(sum[8:16])
+		for i := 0; i < k; i++ {
+			bits[(h1+uint64(i)*h2)%uint64(m)] = true
+		}
+	}
+	return bits
+}
+
+// hasBits reports whether bits has every query bit
+func hasBits(bits map[uint64]bool, query map[uint64]bool) bool {
+	for bit := range query {
+		if !bits[bit] {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareOre compares two plaintexts in the order of an ore index on a column of the cast
+// type, returning -1, 0 or 1. Plaintexts without a cast are compared as integers when both
+// are integers, and as text otherwise.
+func CompareOre(cast CastType, a string, b string) (int, error) {
+	switch cast {
+	case CastInt, CastSmallInt, CastBigInt:
+		return compareInts(a, b)
+	case CastReal, CastDouble:
+		x, errA := strconv.ParseFloat(a, 64)
+		y, errB := strconv.ParseFloat(b, 64)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("not a %s value", cast)
+		}
+		return compareOrdered(x, y), nil
+	case CastBoolean:
+		x, errA := strconv.ParseBool(a)
+		y, errB := strconv.ParseBool(b)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("not a %s value", cast)
+		}
+		switch {
+		case x == y:
+			return 0, nil
+		case !x:
+			return -1, nil
+		}
+		return 1, nil
+	case CastJsonb:
+		return 0, fmt.Errorf("%s values have no ore ordering", cast)
+	case "":
+		if cmp, err := compareInts(a, b); err == nil {
+			return cmp, nil
+		}
+	}
+	return strings.Compare(a, b), nil
+}
+
+func compareInts(a string, b string) (int, error) {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return 0, fmt.Errorf("not an integer")
+	}
+	return compareOrdered(x, y), nil
+}
+
+func compareOrdered[T int64 | float64](x T, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// EqualUnique reports whether two plaintexts have the same unique index term on a column
+// of the cast type, e.g. "07" and "7" are equal ints and jsonb ignores key order
+func EqualUnique(cast CastType, a string, b string) (bool, error) {
+	if cast == CastJsonb {
+		var x, y any
+		if json.Unmarshal([]byte(a), &x) != nil || json.Unmarshal([]byte(b), &y) != nil {
+			return false, fmt.Errorf("not a %s value", cast)
+		}
+		return reflect.DeepEqual(x, y), nil
+	}
+	cmp, err := CompareOre(cast, a, b)
+	return cmp == 0, err
+}
+
+// ContainsJsonb reports whether the jsonb document doc contains sub, as a ste_vec query
+// evaluates @>. Both are decoded JSON values, e.g. from json.Unmarshal into an any.
+func ContainsJsonb(doc any, sub any) bool {
+	if _, isArray := doc.([]any); isArray {
+		// A top level array contains the scalars it has as elements
+		switch sub.(type) {
+		case map[string]any, []any:
+		default:
+			sub = []any{sub}
+		}
+	}
+	return jsonbContains(doc, sub)
+}
+
+func jsonbContains(doc any, sub any) bool {
+	switch s := sub.(type) {
+	case map[string]any:
+		d, ok := doc.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range s {
+			dv, ok := d[k]
+			if !ok || !jsonbContains(dv, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		d, ok := doc.([]any)
+		if !ok {
+			return false
+		}
+		for _, v := range s {
+			found := false
+			for _, dv := range d {
+				if jsonbContains(dv, v) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(doc, sub)
+	}
+}
+
+// Evaluate reports whether a plaintext value stored in the column satisfies "column op query",
+// where query is a payload serialized for the column by MatchQuery, OreQuery, UniqueQuery,
+// JsonbQuery or Query with a descriptor. An ejson_path query is evaluated when it has an
+// operator, which op must repeat. The value may be a plaintext string as carried in payloads,
+// or any value the serializers accept. A nil value is NULL and matches nothing. Keysets are
+// not compared.
+func (c Column) Evaluate(value any, op QueryOperator, query []byte) (bool, error) {
+	var q struct {
+		K string          `json:"k"`
+		P string          `json:"p"`
+		I TableColumn     `json:"i"`
+		Q json.RawMessage `json:"q"`
+	}
+	if err := json.Unmarshal(query, &q); err != nil {
+		return false, fmt.Errorf("invalid query payload: %v", err)
+	}
+	d, err := ParseQueryDescriptor(q.Q)
+	if err != nil {
+		return false, fmt.Errorf("invalid query payload: %v", err)
+	}
+	if q.K != "pt" || d == nil {
+		return false, fmt.Errorf("invalid query payload: not a query")
+	}
+	qt := d.QueryType()
+	if q.I.T != c.Table || q.I.C != c.Name {
+		return false, fmt.Errorf("query for %s.%s cannot be compared with %s.%s", q.I.T, q.I.C, c.Table, c.Name)
+	}
+	if err := c.checkQuery(qt); err != nil {
+		return false, err
+	}
+	ops, ok := queryOperators[qt]
+	if path, isPath := d.(EJsonPathDescriptor); isPath && path.Op != "" {
+		ops, ok = []QueryOperator{path.Op}, true
+	}
+	if !ok {
+		return false, fmt.Errorf("%s queries cannot be evaluated locally", qt)
+	}
+	supported := false
+	for _, o := range ops {
+		supported = supported || o == op
+	}
+	if !supported {
+		return false, fmt.Errorf("operator %s cannot be used with a %s query", op, qt)
+	}
+
+	if value == nil {
+		return false, nil
+	}
+	plaintext, err := plaintextString(value)
+	if err != nil {
+		return false, err
+	}
+
+	switch d := d.(type) {
+	case MatchDescriptor:
+		if d.Options == nil {
+			return c.MatchOptions.Matches(plaintext, q.P), nil
+		}
+		// The query is tokenized with its own options into a filter of the column's size
+		return hasBits(c.MatchOptions.bloom(c.MatchOptions.tokens(plaintext)), c.MatchOptions.bloom(d.Options.tokens(q.P))), nil
+	case SteVecDescriptor:
+		var doc, sub any
+		if err := json.Unmarshal([]byte(plaintext), &doc); err != nil {
+			return false, fmt.Errorf("error decoding %s.%s: %v", c.Table, c.Name, err)
+		}
+		if err := json.Unmarshal([]byte(q.P), &sub); err != nil {
+			return false, fmt.Errorf("invalid ste_vec query for %s.%s: %v", c.Table, c.Name, err)
+		}
+		if d.Selector != "" {
+			path, err := ParseJSONPath(d.Selector)
+			if err != nil {
+				return false, fmt.Errorf("invalid ste_vec query for %s.%s: %v", c.Table, c.Name, err)
+			}
+			if sub, err = path.Contains(sub); err != nil {
+				return false, fmt.Errorf("invalid ste_vec query for %s.%s: %v", c.Table, c.Name, err)
+			}
+		}
+		return ContainsJsonb(doc, sub), nil
+	case EJsonPathDescriptor:
+		return c.evaluatePath(plaintext, d, q.P)
+	}
+
+	switch qt {
+	case "unique":
+		equal, err := EqualUnique(c.Cast, plaintext, q.P)
+		if err != nil {
+			return false, fmt.Errorf("cannot compare %s.%s: %v", c.Table, c.Name, err)
+		}
+		return equal == (op == OpEq), nil
+	}
+
+	cmp, err := CompareOre(c.Cast, plaintext, q.P)
+	if err != nil {
+		return false, fmt.Errorf("cannot compare %s.%s: %v", c.Table, c.Name, err)
+	}
+	return compared(op, cmp), nil
+}
+
+// evaluatePath compares the value at the selector of an ejson_path query with its plaintext.
+// Missing values, objects, arrays and nulls match nothing.
+func (c Column) evaluatePath(plaintext string, d EJsonPathDescriptor, query string) (bool, error) {
+	var doc any
+	if err := json.Unmarshal([]byte(plaintext), &doc); err != nil {
+		return false, fmt.Errorf("error decoding %s.%s: %v", c.Table, c.Name, err)
+	}
+	path, err := ParseJSONPath(d.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid ejson_path query for %s.%s: %v", c.Table, c.Name, err)
+	}
+	selected, ok := path.selectValue(doc)
+	if !ok {
+		return false, nil
+	}
+
+	var cast CastType
+	var text string
+	switch v := selected.(type) {
+	case string:
+		cast, text = CastText, v
+	case float64:
+		cast, text = CastDouble, strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		cast, text = CastBoolean, strconv.FormatBool(v)
+	default:
+		return false, nil
+	}
+	cmp, err := CompareOre(cast, text, query)
+	if err != nil {
+		return false, fmt.Errorf("cannot compare %s.%s at %s: %v", c.Table, c.Name, d.Selector, err)
+	}
+	return compared(d.Op, cmp), nil
+}
+
+// compared reports whether the result of a comparison satisfies op
+func compared(op QueryOperator, cmp int) bool {
+	switch op {
+	case OpEq:
+		return cmp == 0
+	case OpNe:
+		return cmp != 0
+	case OpLt:
+		return cmp < 0
+	case OpLte:
+		return cmp <= 0
+	case OpGt:
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// Preview returns the indexes of the values that "column op query" would match
+func (c Column) Preview(op QueryOperator, query []byte, values ...any) ([]int, error) {
+	var matched []int
+	for i, value := range values {
+		ok, err := c.Evaluate(value, op, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, i)
+		}
+	}
+	return matched, nil
+}
+
+// plaintextString returns the plaintext of a value as carried in payloads, encoding jsonb as JSON
+func plaintextString(value any) (string, error) {
+	if p, ok := value.(plaintexter); ok {
+		value = p.plaintext()
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling JSON: %v", err)
+		}
+		return string(data), nil
+	}
+	return convertToString(value)
+}
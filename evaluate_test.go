@@ -0,0 +1,206 @@
+package goeql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Test CompareOre orders values by cast type
+func TestCompareOre(t *testing.T) {
+	tests := []struct {
+		cast     CastType
+		a, b     string
+		expected int
+	}{
+		{CastInt, "9", "10", -1},
+		{CastText, "9", "10", 1},
+		{"", "9", "10", -1},
+		{"", "b", "a", 1},
+		{CastDouble, "1.5", "1.50", 0},
+		{CastBoolean, "false", "true", -1},
+		{CastDate, "2024-01-31", "2024-02-01", -1},
+	}
+	for _, tt := range tests {
+		got, err := CompareOre(tt.cast, tt.a, tt.b)
+		if err != nil || got != tt.expected {
+			t.Errorf("CompareOre(%q, %q, %q) = %d, %v, expected %d", tt.cast, tt.a, tt.b, got, err, tt.expected)
+		}
+	}
+
+	if _, err := CompareOre(CastInt, "1", "one"); err == nil {
+		t.Errorf("Expected error comparing a non integer")
+	}
+	if _, err := CompareOre(CastJsonb, "{}", "{}"); err == nil {
+		t.Errorf("Expected error ordering jsonb")
+	}
+}
+
+// Test EqualUnique compares values by cast type
+func TestEqualUnique(t *testing.T) {
+	tests := []struct {
+		cast     CastType
+		a, b     string
+		expected bool
+	}{
+		{CastInt, "07", "7", true},
+		{CastText, "07", "7", false},
+		{CastText, "Alice", "alice", false},
+		{CastJsonb, `{"a":1,"b":2}`, `{"b":2,"a":1}`, true},
+		{CastJsonb, `{"a":1}`, `{"a":2}`, false},
+	}
+	for _, tt := range tests {
+		got, err := EqualUnique(tt.cast, tt.a, tt.b)
+		if err != nil || got != tt.expected {
+			t.Errorf("EqualUnique(%q, %q, %q) = %v, %v, expected %v", tt.cast, tt.a, tt.b, got, err, tt.expected)
+		}
+	}
+}
+
+// Test match tokens follow the match options
+func TestMatchOptions_Tokens(t *testing.T) {
+	var defaults *MatchOptions
+	if got := defaults.Tokens("Alice"); !reflect.DeepEqual(got, []string{"ali", "ice", "lic"}) {
+		t.Errorf("Expected downcased trigrams, got %v", got)
+	}
+	if !defaults.Matches("Alice Smith", "SMI") || defaults.Matches("Alice Smith", "smy") {
+		t.Errorf("Expected queries to match by downcased trigrams")
+	}
+	if !defaults.Matches("Alice", "al") {
+		t.Errorf("Expected a query without tokens to match")
+	}
+
+	standard := &MatchOptions{Tokenizer: &Tokenizer{Kind: "standard"}, TokenFilters: []TokenFilter{}}
+	if got := standard.Tokens("Alice Smith-Jones"); !reflect.DeepEqual(got, []string{"Alice", "Jones", "Smith"}) {
+		t.Errorf("Expected words, got %v", got)
+	}
+	if standard.Matches("Alice Smith", "smith") || standard.Matches("Alice Smith", "Smi") {
+		t.Errorf("Expected only whole words to match without the downcase filter")
+	}
+
+	bigrams := &MatchOptions{Tokenizer: &Tokenizer{Kind: "ngram", TokenLength: 2}, IncludeOriginal: true}
+	if got := bigrams.Tokens("Abc"); !reflect.DeepEqual(got, []string{"ab", "abc", "bc"}) {
+		t.Errorf("Expected bigrams and the original, got %v", got)
+	}
+}
+
+// Test match evaluation uses the bloom filter size of the match options
+func TestMatchOptions_Bloom(t *testing.T) {
+	// Every token sets the only bit of a one bit filter, so any query matches
+	saturated := &MatchOptions{K: 1, M: 1}
+	if !saturated.Matches("Alice", "xyz") {
+		t.Errorf("Expected a false positive in a one bit filter")
+	}
+
+	email := NewColumn("users", "email", MatchIndex)
+	email.MatchOptions = saturated
+	query, err := email.Match("bob")
+	if err != nil {
+		t.Fatalf("Match returned error: %v", err)
+	}
+	if ok, err := email.Evaluate("alice@example.com", OpContains, query); err != nil || !ok {
+		t.Errorf("Expected the column's filter size to be evaluated, got %v, %v", ok, err)
+	}
+
+	email.MatchOptions = &MatchOptions{K: 6, M: 2048}
+	if ok, err := email.Evaluate("alice@example.com", OpContains, query); err != nil || ok {
+		t.Errorf("Expected no match in a 2048 bit filter, got %v, %v", ok, err)
+	}
+}
+
+// Test ContainsJsonb follows the rules of the jsonb @> operator
+func TestContainsJsonb(t *testing.T) {
+	tests := []struct {
+		doc, sub string
+		expected bool
+	}{
+		{`{"a":1,"b":{"c":2}}`, `{"b":{"c":2}}`, true},
+		{`{"a":1,"b":{"c":2}}`, `{"b":{"c":3}}`, false},
+		{`{"roles":["admin","billing"]}`, `{"roles":["billing"]}`, true},
+		{`{"roles":["admin"]}`, `{"roles":"admin"}`, false},
+		{`[1,[2,3]]`, `[[3]]`, true},
+		{`[[1]]`, `[1]`, false},
+		{`["admin","billing"]`, `"admin"`, true},
+		{`{"a":null}`, `{"a":null}`, true},
+	}
+	for _, tt := range tests {
+		var doc, sub any
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.sub), &sub); err != nil {
+			t.Fatal(err)
+		}
+		if got := ContainsJsonb(doc, sub); got != tt.expected {
+			t.Errorf("%s @> %s = %v, expected %v", tt.doc, tt.sub, got, tt.expected)
+		}
+	}
+}
+
+// Test Column.Evaluate and Column.Preview evaluate query payloads against plaintexts
+func TestColumn_Preview(t *testing.T) {
+	email := NewColumn("users", "email", MatchIndex, UniqueIndex)
+	age := Column{Table: "users", Name: "age", Cast: CastInt, Indexes: []IndexType{OreIndex}}
+	attrs := Column{Table: "users", Name: "attrs", Cast: CastJsonb, Indexes: []IndexType{SteVecIndex}}
+
+	emails := []any{"alice@example.com", EncryptedText("bob@example.com"), nil, "carol@example.org"}
+	ages := []any{30, EncryptedInt(9), "42"}
+
+	tests := []struct {
+		name     string
+		column   Column
+		op       QueryOperator
+		query    func() ([]byte, error)
+		values   []any
+		expected []int
+	}{
+		{"match", email, OpContains, func() ([]byte, error) { return email.Match("EXAMPLE.com") }, emails, []int{0, 1}},
+		{"unique", email, OpEq, func() ([]byte, error) { return email.Unique("bob@example.com") }, emails, []int{1}},
+		{"unique not equal skips NULL", email, OpNe, func() ([]byte, error) { return email.Unique("bob@example.com") }, emails, []int{0, 3}},
+		{"ore", age, OpGte, func() ([]byte, error) { return age.Ore(30) }, ages, []int{0, 2}},
+		{"ste_vec", attrs, OpContains, func() ([]byte, error) { return attrs.Jsonb(map[string]any{"plan": "pro"}) },
+			[]any{EncryptedJsonb{"plan": "pro", "seats": 5}, map[string]any{"plan": "free"}, `{"plan":"pro"}`}, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := tt.query()
+			if err != nil {
+				t.Fatalf("Query returned error: %v", err)
+			}
+			got, err := tt.column.Preview(tt.op, query, tt.values...)
+			if err != nil {
+				t.Fatalf("Preview returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Test Column.Evaluate rejects queries the column cannot serve
+func TestColumn_EvaluateErrors(t *testing.T) {
+	email := NewColumn("users", "email", UniqueIndex)
+	unique, _ := UniqueQuery("alice", "users", "email")
+	match, _ := MatchQuery("alice", "users", "email")
+	other, _ := UniqueQuery("alice", "users", "name")
+	stored, _ := EncryptedText("alice").Serialize("users", "email")
+	path, _ := EJsonPathQuery("$.a", "users", "email")
+
+	tests := map[string]struct {
+		op    QueryOperator
+		query []byte
+	}{
+		"wrong operator": {OpLt, unique},
+		"missing index":  {OpContains, match},
+		"other column":   {OpEq, other},
+		"not a query":    {OpEq, stored},
+		"ejson path":     {OpEq, path},
+		"invalid JSON":   {OpEq, []byte("{")},
+	}
+	for name, tt := range tests {
+		if _, err := email.Evaluate("alice", tt.op, tt.query); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package goeql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"unicode/utf8"
+)
+
+// Fuzz targets for the codecs. Seeds live in testdata/fuzz, and run as regular
+// tests with go test. Run a target with e.g.
+//
+//	go test -run '^$' -fuzz FuzzEncryptedText
+
+// Test EncryptedText round trips through Serialize, Deserialize and the Decoder
+func FuzzEncryptedText(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		data, err := EncryptedText(s).Serialize("users", "email")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		if s == "" {
+			if data != nil {
+				t.Fatalf("Expected nil payload for the zero value, got %s", data)
+			}
+			return
+		}
+		if !json.Valid(data) {
+			t.Fatalf("Serialize produced invalid JSON: %s", data)
+		}
+
+		// Invalid UTF-8 is replaced, as encoding/json does
+		expected := s
+		if !utf8.ValidString(s) {
+			expected = string([]rune(s))
+		}
+
+		var et EncryptedText
+		got, err := et.Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize returned error: %v", err)
+		}
+		if string(got) != expected {
+			t.Fatalf("Deserialize: expected %q, got %q", expected, string(got))
+		}
+
+		var d Decoder
+		decoded, err := d.Text(data)
+		if err != nil {
+			t.Fatalf("Decoder.Text returned error: %v", err)
+		}
+		if string(decoded) != expected {
+			t.Fatalf("Decoder.Text: expected %q, got %q", expected, string(decoded))
+		}
+	})
+}
+
+// Test EncryptedInt round trips through Serialize, Deserialize and the Decoder
+func FuzzEncryptedInt(f *testing.F) {
+	f.Fuzz(func(t *testing.T, n int64) {
+		if int64(int(n)) != n {
+			t.Skip("value does not fit in an int")
+		}
+		data, err := EncryptedInt(n).Serialize("users", "age")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+
+		var ei EncryptedInt
+		got, err := ei.Deserialize(data)
+		if err != nil || int64(got) != n {
+			t.Fatalf("Deserialize: expected %d, got %d, %v", n, int64(got), err)
+		}
+
+		var d Decoder
+		decoded, err := d.Int(data)
+		if err != nil || int64(decoded) != n {
+			t.Fatalf("Decoder.Int: expected %d, got %d, %v", n, int64(decoded), err)
+		}
+	})
+}
+
+// Test EncryptedBool round trips through Serialize, Deserialize and the Decoder
+func FuzzEncryptedBool(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b bool) {
+		data, err := EncryptedBool(b).Serialize("users", "active")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		if !b {
+			// false is the zero value, serialized as NULL
+			if data != nil {
+				t.Fatalf("Expected nil payload for false, got %s", data)
+			}
+			return
+		}
+
+		var eb EncryptedBool
+		got, err := eb.Deserialize(data)
+		if err != nil || bool(got) != b {
+			t.Fatalf("Deserialize: expected %v, got %v, %v", b, bool(got), err)
+		}
+
+		var d Decoder
+		decoded, err := d.Bool(data)
+		if err != nil || bool(decoded) != b {
+			t.Fatalf("Decoder.Bool: expected %v, got %v, %v", b, bool(decoded), err)
+		}
+	})
+}
+
+// Test JSON objects round trip through EncryptedJsonb
+func FuzzEncryptedJsonb(f *testing.F) {
+	f.Fuzz(func(t *testing.T, doc []byte) {
+		var value map[string]any
+		if err := json.Unmarshal(doc, &value); err != nil || len(value) == 0 {
+			t.Skip("not a non empty JSON object")
+		}
+
+		data, err := EncryptedJsonb(value).Serialize("users", "attrs")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+
+		var ej EncryptedJsonb
+		got, err := ej.Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize returned error: %v", err)
+		}
+		if !reflect.DeepEqual(map[string]any(got), value) {
+			t.Fatalf("Deserialize: expected %v, got %v", value, map[string]any(got))
+		}
+
+		var d Decoder
+		decoded, err := d.Jsonb(data)
+		if err != nil || !reflect.DeepEqual(map[string]any(decoded), value) {
+			t.Fatalf("Decoder.Jsonb: expected %v, got %v, %v", value, map[string]any(decoded), err)
+		}
+	})
+}
+
+// Test JSON arrays round trip through EncryptedJsonbArray
+func FuzzEncryptedJsonbArray(f *testing.F) {
+	f.Fuzz(func(t *testing.T, doc []byte) {
+		var value []any
+		if err := json.Unmarshal(doc, &value); err != nil || len(value) == 0 {
+			t.Skip("not a non empty JSON array")
+		}
+
+		data, err := EncryptedJsonbArray(value).Serialize("users", "tags")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+
+		var eja EncryptedJsonbArray
+		got, err := eja.Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize returned error: %v", err)
+		}
+		if !reflect.DeepEqual([]any(got), value) {
+			t.Fatalf("Deserialize: expected %v, got %v", value, []any(got))
+		}
+
+		var d Decoder
+		decoded, err := d.JsonbArray(data)
+		if err != nil || !reflect.DeepEqual([]any(decoded), value) {
+			t.Fatalf("Decoder.JsonbArray: expected %v, got %v, %v", value, []any(decoded), err)
+		}
+	})
+}
+
+// Test Deserialize and the Decoder never panic on arbitrary bytes, and agree on valid payloads
+func FuzzDeserialize(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var et EncryptedText
+		text, textErr := et.Deserialize(data)
+		var ei EncryptedInt
+		_, _ = ei.Deserialize(data)
+		var eb EncryptedBool
+		_, _ = eb.Deserialize(data)
+		var ej EncryptedJsonb
+		_, _ = ej.Deserialize(data)
+		var eja EncryptedJsonbArray
+		_, _ = eja.Deserialize(data)
+
+		var d Decoder
+		_, _ = d.Int(data)
+		_, _ = d.Bool(data)
+		_, _ = d.Jsonb(data)
+		_, _ = d.JsonbArray(data)
+		_, _ = DecodePayload(data)
+		decoded, decodedErr := d.Text(data)
+
+		// The Decoder validates more strictly, but a payload it accepts must decode the same way
+		if decodedErr == nil && textErr == nil && text != decoded {
+			t.Fatalf("Deserialize and Decoder.Text disagree: %q and %q", string(text), string(decoded))
+		}
+	})
+}
+
+// Test DecodePayload accepts exactly the payloads that encoding/json parses into a valid envelope
+func FuzzDecodePayload(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := DecodePayload(data)
+		expected := validEnvelope(data)
+		if (err == nil) != expected {
+			t.Fatalf("DecodePayload(%q) returned %v, expected encoding/json to agree, which accepts it: %v", data, err, expected)
+		}
+	})
+}
+
+// validEnvelope reports whether data is a valid payload envelope, reading it with encoding/json.
+// Each occurrence of a known field must have the right type, and the last one is kept.
+func validEnvelope(data []byte) bool {
+	var k, table, column string
+	var v int
+	var hasP, hasV bool
+	ok := eachField(data, func(key string, raw json.RawMessage) bool {
+		switch key {
+		case "k":
+			return raw[0] == '"' && json.Unmarshal(raw, &k) == nil
+		case "p":
+			hasP = true
+			return raw[0] == '"'
+		case "v":
+			var err error
+			v, err = strconv.Atoi(string(raw))
+			hasV = true
+			return err == nil
+		case "i":
+			table, column = "", ""
+			return eachField(raw, func(key string, raw json.RawMessage) bool {
+				switch key {
+				case "t":
+					return raw[0] == '"' && json.Unmarshal(raw, &table) == nil
+				case "c":
+					return raw[0] == '"' && json.Unmarshal(raw, &column) == nil
+				}
+				return true
+			})
+		}
+		return true
+	})
+	return ok && k == "pt" && hasP && hasV && table != "" && column != "" && EQLVersion(v).Validate() == nil
+}
+
+// eachField calls fn with each field of the JSON object in data, in order, reporting
+// whether data is a single valid object and fn accepted every field
+func eachField(data []byte, fn func(key string, raw json.RawMessage) bool) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return false
+		}
+		if !fn(tok.(string), raw) {
+			return false
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return false
+	}
+	_, err := dec.Token()
+	return err == io.EOF
+}
+
+// Test convertToString formats scalars like strconv and encodes jsonb arrays as JSON
+func FuzzConvertToString(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string, n int64, x float64, b bool) {
+		for value, expected := range map[any]string{
+			s: s,
+			n: strconv.FormatInt(n, 10),
+			b: strconv.FormatBool(b),
+		} {
+			got, err := convertToString(value)
+			if err != nil || got != expected {
+				t.Fatalf("convertToString(%#v): expected %q, got %q, %v", value, expected, got, err)
+			}
+		}
+
+		got, err := convertToString(x)
+		if err != nil || got != fmt.Sprintf("%f", x) {
+			t.Fatalf("convertToString(%v): got %q, %v", x, got, err)
+		}
+
+		arr := []any{s, n, b}
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			arr = append(arr, x)
+		}
+		got, err = convertToString(arr)
+		if err != nil {
+			t.Fatalf("convertToString(%#v) returned error: %v", arr, err)
+		}
+		var decoded []any
+		if err := json.Unmarshal([]byte(got), &decoded); err != nil || len(decoded) != len(arr) {
+			t.Fatalf("convertToString(%#v) produced invalid JSON %q: %v", arr, got, err)
+		}
+	})
+}
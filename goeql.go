@@ -12,7 +12,9 @@ package goeql
 // More documentation on this format can be found at https://github.com/cipherstash/encrypt-query-language#data-format
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -27,11 +29,12 @@ type TableColumn struct {
 
 // EncryptedColumn represents the plaintext value sent by a database client
 type EncryptedColumn struct {
-	K string      `json:"k"`
-	P string      `json:"p"`
-	I TableColumn `json:"i"`
-	V int         `json:"v"`
-	Q any         `json:"q"`
+	K  string      `json:"k"`
+	P  string      `json:"p"`
+	I  TableColumn `json:"i"`
+	V  int         `json:"v"`
+	Q  any         `json:"q"`
+	KS *Keyset     `json:"ks,omitempty"`
 }
 
 // EncryptedText is a string value to be encrypted
@@ -51,28 +54,44 @@ type EncryptedBool bool
 
 // Serialize turns a EncryptedText value into a jsonb payload for CipherStash Proxy
 func (et EncryptedText) Serialize(table string, column string) ([]byte, error) {
+	return et.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext turns a EncryptedText value into a jsonb payload for CipherStash Proxy,
+// using the keyset from ctx
+func (et EncryptedText) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
 	// Adding a check based on the go zero value for a string https://go.dev/ref/spec#The_zero_value
 	if len(et) == 0 {
 		return nil, nil
 	}
 
-	val, err := ToEncryptedColumn(string(et), table, column, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error serializing: %v", err)
-	}
-	return json.Marshal(val)
+	return encryptValue(ctx, string(et), table, column)
 }
 
 // Deserialize turns a jsonb payload from CipherStash Proxy into an EncryptedText value
 func (et *EncryptedText) Deserialize(data []byte) (EncryptedText, error) {
+	return et.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext turns a jsonb payload from CipherStash Proxy into an EncryptedText value,
+// checking the installed policy for the principal in ctx
+func (et *EncryptedText) DeserializeContext(ctx context.Context, data []byte) (_ EncryptedText, err error) {
 	if len(data) == 0 {
 		var EncryptedText EncryptedText
 		return EncryptedText, nil
 	}
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return "", err
 	}
+	table, column := payloadIdentity(jsonData)
+	call.identify(table, column)
+	if err := checkPolicy(ctx, table, column, ActionDecode); err != nil {
+		return "", err
+	}
 
 	if pValue, ok := jsonData["p"].(string); ok {
 		return EncryptedText(pValue), nil
@@ -83,6 +102,12 @@ func (et *EncryptedText) Deserialize(data []byte) (EncryptedText, error) {
 
 // Serialize turns a EncryptedJsonb value into a jsonb payload for CipherStash Proxy
 func (ej EncryptedJsonb) Serialize(table string, column string) ([]byte, error) {
+	return ej.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext turns a EncryptedJsonb value into a jsonb payload for CipherStash Proxy,
+// using the keyset from ctx
+func (ej EncryptedJsonb) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
 	// When setting a jsonb field in xorm to nil || an empty map || not including the field,
 	// the value that comes through here is map[]/
 	// Adding a check based on the go zero value https://go.dev/ref/spec#The_zero_value
@@ -90,22 +115,32 @@ func (ej EncryptedJsonb) Serialize(table string, column string) ([]byte, error)
 		return nil, nil
 	}
 
-	val, err := ToEncryptedColumn(map[string]any(ej), table, column, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error serializing: %v", err)
-	}
-	return json.Marshal(val)
+	return encryptValue(ctx, map[string]any(ej), table, column)
 }
 
 // Deserialize turns a jsonb payload from CipherStash Proxy into an EncryptedJsonb value
 func (ej *EncryptedJsonb) Deserialize(data []byte) (EncryptedJsonb, error) {
+	return ej.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext turns a jsonb payload from CipherStash Proxy into an EncryptedJsonb value,
+// checking the installed policy for the principal in ctx
+func (ej *EncryptedJsonb) DeserializeContext(ctx context.Context, data []byte) (_ EncryptedJsonb, err error) {
 	if len(data) == 0 {
 		return nil, nil
 	}
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return nil, err
 	}
+	table, column := payloadIdentity(jsonData)
+	call.identify(table, column)
+	if err := checkPolicy(ctx, table, column, ActionDecode); err != nil {
+		return nil, err
+	}
 
 	if pValue, ok := jsonData["p"].(string); ok {
 		var pData map[string]interface{}
@@ -121,6 +156,12 @@ func (ej *EncryptedJsonb) Deserialize(data []byte) (EncryptedJsonb, error) {
 
 // Serialize turns a EncryptedJsonbArray value into a jsonb payload for CipherStash Proxy
 func (eja EncryptedJsonbArray) Serialize(table string, column string) ([]byte, error) {
+	return eja.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext turns a EncryptedJsonbArray value into a jsonb payload for CipherStash Proxy,
+// using the keyset from ctx
+func (eja EncryptedJsonbArray) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
 	// When setting a jsonb field in xorm to nil || an empty map || not including the field,
 	// the value that comes through here is map[]/
 	// Adding a check based on the go zero value https://go.dev/ref/spec#The_zero_value
@@ -128,22 +169,32 @@ func (eja EncryptedJsonbArray) Serialize(table string, column string) ([]byte, e
 		return nil, nil
 	}
 
-	val, err := ToEncryptedColumn([]interface{}(eja), table, column, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error serializing: %v", err)
-	}
-	return json.Marshal(val)
+	return encryptValue(ctx, []interface{}(eja), table, column)
 }
 
 // Deserialize turns a jsonb payload from CipherStash Proxy into an EncryptedJsonbArray value
 func (ej *EncryptedJsonbArray) Deserialize(data []byte) (EncryptedJsonbArray, error) {
+	return ej.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext turns a jsonb payload from CipherStash Proxy into an EncryptedJsonbArray value,
+// checking the installed policy for the principal in ctx
+func (ej *EncryptedJsonbArray) DeserializeContext(ctx context.Context, data []byte) (_ EncryptedJsonbArray, err error) {
 	if len(data) == 0 {
 		return nil, nil
 	}
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return nil, err
 	}
+	table, column := payloadIdentity(jsonData)
+	call.identify(table, column)
+	if err := checkPolicy(ctx, table, column, ActionDecode); err != nil {
+		return nil, err
+	}
 
 	if pValue, ok := jsonData["p"].(string); ok {
 		var pData []interface{}
@@ -159,24 +210,40 @@ func (ej *EncryptedJsonbArray) Deserialize(data []byte) (EncryptedJsonbArray, er
 
 // Serialize turns a EncryptedInt value into a jsonb payload for CipherStash Proxy
 func (ei EncryptedInt) Serialize(table string, column string) ([]byte, error) {
-	val, err := ToEncryptedColumn(int(ei), table, column, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error serializing: %v", err)
-	}
-	return json.Marshal(val)
+	return ei.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext turns a EncryptedInt value into a jsonb payload for CipherStash Proxy,
+// using the keyset from ctx
+func (ei EncryptedInt) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
+	return encryptValue(ctx, int(ei), table, column)
 }
 
 // Deserialize turns a jsonb payload from CipherStash Proxy into an EncryptedInt value
 func (ei *EncryptedInt) Deserialize(data []byte) (EncryptedInt, error) {
+	return ei.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext turns a jsonb payload from CipherStash Proxy into an EncryptedInt value,
+// checking the installed policy for the principal in ctx
+func (ei *EncryptedInt) DeserializeContext(ctx context.Context, data []byte) (_ EncryptedInt, err error) {
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return 0, fmt.Errorf("error unmarshaling 'p' JSON string: %v", err)
 	}
+	table, column := payloadIdentity(jsonData)
+	call.identify(table, column)
+	if err := checkPolicy(ctx, table, column, ActionDecode); err != nil {
+		return 0, err
+	}
 
 	if pValue, ok := jsonData["p"].(string); ok {
 		parsedValue, err := strconv.Atoi(pValue) // Convert string to int
 		if err != nil {
-			return 0, fmt.Errorf("invalid number format in 'p' field: %v", err)
+			return 0, fmt.Errorf("invalid number format in 'p' field: %v", parseErrorReason(err))
 		}
 		return EncryptedInt(parsedValue), nil
 	}
@@ -186,30 +253,46 @@ func (ei *EncryptedInt) Deserialize(data []byte) (EncryptedInt, error) {
 
 // Serialize turns a EncryptedBool value into a jsonb payload for CipherStash Proxy
 func (eb EncryptedBool) Serialize(table string, column string) ([]byte, error) {
+	return eb.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext turns a EncryptedBool value into a jsonb payload for CipherStash Proxy,
+// using the keyset from ctx
+func (eb EncryptedBool) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
 	// https: //go.dev/ref/spec#The_zero_value
 	// The zero value for an boolean is false
 	if !eb {
 		return nil, nil
 	}
-	val, err := ToEncryptedColumn(bool(eb), table, column, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error serializing: %v", err)
-	}
-	return json.Marshal(val)
+	return encryptValue(ctx, bool(eb), table, column)
 }
 
 // Deserialize turns a jsonb payload from CipherStash Proxy into an EncryptedBool value
 func (eb *EncryptedBool) Deserialize(data []byte) (EncryptedBool, error) {
+	return eb.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext turns a jsonb payload from CipherStash Proxy into an EncryptedBool value,
+// checking the installed policy for the principal in ctx
+func (eb *EncryptedBool) DeserializeContext(ctx context.Context, data []byte) (_ EncryptedBool, err error) {
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		// TODO: Check the best return values for these.
 		return false, err
 	}
+	table, column := payloadIdentity(jsonData)
+	call.identify(table, column)
+	if err := checkPolicy(ctx, table, column, ActionDecode); err != nil {
+		return false, err
+	}
 
 	if pValue, ok := jsonData["p"].(string); ok {
 		parsedValue, err := strconv.ParseBool(pValue)
 		if err != nil {
-			return false, fmt.Errorf("invalid boolean format in 'p' field: %v", err)
+			return false, fmt.Errorf("invalid boolean format in 'p' field: %v", parseErrorReason(err))
 		}
 		return EncryptedBool(parsedValue), nil
 	}
@@ -219,32 +302,100 @@ func (eb *EncryptedBool) Deserialize(data []byte) (EncryptedBool, error) {
 
 // MatchQuery serializes a plaintext value used in a match query
 func MatchQuery(value any, table string, column string) ([]byte, error) {
-	return serializeQuery(value, table, column, "match")
+	return MatchQueryContext(context.Background(), value, table, column)
+}
+
+// MatchQueryContext serializes a plaintext value used in a match query, using the keyset from ctx
+func MatchQueryContext(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	return serializeQuery(ctx, value, table, column, MatchDescriptor{})
 }
 
 // OreQuery serializes a plaintext value used in an ore query
 func OreQuery(value any, table string, column string) ([]byte, error) {
-	return serializeQuery(value, table, column, "ore")
+	return OreQueryContext(context.Background(), value, table, column)
+}
+
+// OreQueryContext serializes a plaintext value used in an ore query, using the keyset from ctx
+func OreQueryContext(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	return serializeQuery(ctx, value, table, column, OreDescriptor{})
 }
 
 // UniqueQuery serializes a plaintext value used in a unique query
 func UniqueQuery(value any, table string, column string) ([]byte, error) {
-	return serializeQuery(value, table, column, "unique")
+	return UniqueQueryContext(context.Background(), value, table, column)
+}
+
+// UniqueQueryContext serializes a plaintext value used in a unique query, using the keyset from ctx
+func UniqueQueryContext(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	return serializeQuery(ctx, value, table, column, UniqueDescriptor{})
 }
 
 // JsonbQuery serializes a plaintext value used in a jsonb query
 func JsonbQuery(value any, table string, column string) ([]byte, error) {
-	return serializeQuery(value, table, column, "ste_vec")
+	return JsonbQueryContext(context.Background(), value, table, column)
 }
 
-// EJsonPathQuery serializes an ejson path to be used in an ejson path query
+// JsonbQueryContext serializes a plaintext value used in a jsonb query, using the keyset from ctx
+func JsonbQueryContext(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	return serializeQuery(ctx, value, table, column, SteVecDescriptor{})
+}
+
+// EJsonPathQuery serializes an ejson path to be used in an ejson path query.
+// A JSONPath value is validated before serializing, other values are serialized as given.
 func EJsonPathQuery(value any, table string, column string) ([]byte, error) {
-	return serializeQuery(value, table, column, "ejson_path")
+	return EJsonPathQueryContext(context.Background(), value, table, column)
+}
+
+// EJsonPathQueryContext serializes an ejson path to be used in an ejson path query,
+// using the keyset from ctx
+func EJsonPathQueryContext(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	if path, ok := value.(JSONPath); ok {
+		if err := path.Validate(); err != nil {
+			return nil, err
+		}
+		value = path.String()
+	}
+	return serializeQuery(ctx, value, table, column, EJsonPathDescriptor{})
 }
 
 // serializeQuery produces a jsonb payload used by EQL query functions to perform search operations like equality checks, range queries, and unique constraints.
-func serializeQuery(value any, table string, column string, queryType any) ([]byte, error) {
-	query, err := ToEncryptedColumn(value, table, column, queryType)
+func serializeQuery(ctx context.Context, value any, table string, column string, q QueryDescriptor) ([]byte, error) {
+	call := startHooks(ctx, OperationQuery, table, column, q.QueryType())
+	serializedQuery, err := serializeQueryValue(ctx, value, table, column, q)
+	call.end(len(serializedQuery), err)
+	return serializedQuery, err
+}
+
+func serializeQueryValue(ctx context.Context, value any, table string, column string, q QueryDescriptor) ([]byte, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	qt := q.QueryType()
+	if err := checkQueryPolicy(ctx, table, column, qt); err != nil {
+		return nil, err
+	}
+	if err := checkRegistry(table, column, qt); err != nil {
+		return nil, err
+	}
+
+	// Descriptors without options take the append encoder, avoiding the EncryptedColumn round trip through encoding/json
+	if q.shortcut() {
+		keyset, err := payloadKeyset(ctx, table, column)
+		if err != nil {
+			return nil, err
+		}
+		version, err := payloadVersion(ctx, table, column)
+		if err != nil {
+			return nil, err
+		}
+		serializedQuery, err := appendEncryptedValue(nil, value, table, column, qt, keyset, version)
+		if err != nil {
+			return nil, fmt.Errorf("error converting to EncryptedColumn: %v", err)
+		}
+		return serializedQuery, nil
+	}
+
+	query, err := toEncryptedColumn(ctx, value, table, column, q)
 	if err != nil {
 		return nil, fmt.Errorf("error converting to EncryptedColumn: %v", err)
 	}
@@ -259,6 +410,36 @@ func serializeQuery(value any, table string, column string, queryType any) ([]by
 
 // ToEncryptedColumn converts a plaintext value to a string, and returns the EncryptedColumn struct for inserting into a database.
 func ToEncryptedColumn(value any, table string, column string, queryType any) (EncryptedColumn, error) {
+	return ToEncryptedColumnContext(context.Background(), value, table, column, queryType)
+}
+
+// ToEncryptedColumnContext converts a plaintext value to a string, and returns the EncryptedColumn struct
+// for inserting into a database, with the keyset from ctx.
+func ToEncryptedColumnContext(ctx context.Context, value any, table string, column string, queryType any) (ec EncryptedColumn, err error) {
+	qt, _ := queryTypeOf(queryType)
+	// No payload is marshaled, so events report a zero Size
+	call := startHooks(ctx, encodeOperation(qt), table, column, qt)
+	defer func() { call.end(0, err) }()
+
+	return toEncryptedColumn(ctx, value, table, column, queryType)
+}
+
+// toEncryptedColumn is ToEncryptedColumnContext without calling the installed hooks
+func toEncryptedColumn(ctx context.Context, value any, table string, column string, queryType any) (EncryptedColumn, error) {
+	keyset, err := payloadKeyset(ctx, table, column)
+	if err != nil {
+		return EncryptedColumn{}, err
+	}
+	if keyset != nil {
+		// Copy the keyset so the column does not share it with the context
+		ks := *keyset
+		keyset = &ks
+	}
+	version, err := payloadVersion(ctx, table, column)
+	if err != nil {
+		return EncryptedColumn{}, err
+	}
+
 	if queryType == nil {
 		str, err := convertToString(value)
 
@@ -266,7 +447,7 @@ func ToEncryptedColumn(value any, table string, column string, queryType any) (E
 			return EncryptedColumn{}, fmt.Errorf("error: %v", err)
 		}
 
-		data := EncryptedColumn{K: "pt", P: str, I: TableColumn{T: table, C: column}, V: 1, Q: nil}
+		data := EncryptedColumn{K: "pt", P: str, I: TableColumn{T: table, C: column}, V: int(version), Q: nil, KS: keyset}
 
 		return data, nil
 	}
@@ -276,13 +457,27 @@ func ToEncryptedColumn(value any, table string, column string, queryType any) (E
 		return EncryptedColumn{}, fmt.Errorf("error: %v", err)
 	}
 
-	data := EncryptedColumn{K: "pt", P: str, I: TableColumn{T: table, C: column}, V: 1, Q: queryType}
+	data := EncryptedColumn{K: "pt", P: str, I: TableColumn{T: table, C: column}, V: int(version), Q: queryType, KS: keyset}
 
 	return data, nil
 
 }
 
 func convertToString(value any) (string, error) {
+	// Encrypted* values redact their String method, so convert their plaintext instead
+	if p, ok := value.(plaintexter); ok {
+		return convertToString(p.plaintext())
+	}
+	// []any is how encoding/json represents a JSON array, as map[string]any is a JSON object,
+	// so like maps it is encoded as JSON and decodes back into an EncryptedJsonbArray. Typed
+	// slices keep the "[a, b]" form they have always had, so their payloads do not change.
+	if arr, ok := value.([]any); ok {
+		jsonData, err := json.Marshal(arr)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling JSON: %v", err)
+		}
+		return string(jsonData), nil
+	}
 	// Check for slice types
 	val := reflect.ValueOf(value)
 	// reflect.Slice will return true if it is a slice
@@ -321,3 +516,13 @@ func convertToString(value any) (string, error) {
 		return "", fmt.Errorf("unsupported type: %T", v)
 	}
 }
+
+// parseErrorReason returns the reason a strconv parse failed, without the input,
+// so the plaintext does not end up in errors and hook events
+func parseErrorReason(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+	return err
+}
@@ -442,6 +442,7 @@ func TestConvertToString(t *testing.T) {
 		{value: []float64{1.1, 2.2, 3.3}, expectedStr: "[1.100000, 2.200000, 3.300000]", expectError: false},
 		{value: []string{"hello", "world"}, expectedStr: "[hello, world]", expectError: false},
 		{value: []bool{true, false, true}, expectedStr: "[true, false, true]", expectError: false},
+		{value: []interface{}{"a", 1, true}, expectedStr: `["a",1,true]`, expectError: false},
 	}
 
 	for _, tt := range tests {
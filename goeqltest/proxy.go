@@ -0,0 +1,407 @@
+// Package goeqltest provides an in-process stand-in for CipherStash Proxy, for
+// testing code that stores and queries EQL payloads without a database.
+//
+// A Proxy accepts the plaintext payloads produced by goeql, simulates encryption
+// by sealing them into ciphertext payloads stored per table, evaluates match,
+// ore, unique and ste_vec queries on the decrypted plaintext with
+// goeql.Column.Evaluate, and returns plaintext payloads as the real proxy does:
+//
+//	proxy := goeqltest.NewProxy(nil)
+//
+//	email, _ := goeql.EncryptedText("alice@example.com").Serialize("users", "email")
+//	id, err := proxy.Insert("users", map[string][]byte{"email": email})
+//
+//	query, _ := goeql.UniqueQuery("alice@example.com", "users", "email")
+//	rows, err := proxy.Select("users", goeqltest.Condition{Column: "email", Op: goeqltest.Eq, Query: query})
+//
+//	var got goeql.EncryptedText
+//	got, err = got.Deserialize(rows[0].Values["email"])
+//
+// Values only match queries serialized under the same keyset, as values
+// encrypted under one keyset cannot be compared with terms from another.
+package goeqltest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cipherstash/goeql"
+)
+
+// Operator compares an encrypted column with a query payload
+type Operator = goeql.QueryOperator
+
+const (
+	// Eq matches values equal to a unique or ore query
+	Eq = goeql.OpEq
+	// Ne matches values not equal to a unique or ore query
+	Ne = goeql.OpNe
+	// Lt, Lte, Gt and Gte compare values with an ore query
+	Lt  = goeql.OpLt
+	Lte = goeql.OpLte
+	Gt  = goeql.OpGt
+	Gte = goeql.OpGte
+	// Contains matches values containing the tokens of a match query or the document of a ste_vec query
+	Contains = goeql.OpContains
+)
+
+// ErrNotFound is returned by Get when there is no row with the ID
+var ErrNotFound = errors.New("row not found")
+
+// Condition compares a column with a query payload serialized by the goeql query helpers
+type Condition struct {
+	Column string
+	Op     Operator
+	Query  []byte
+}
+
+// Row is a stored row, with the plaintext payload of each non NULL column
+type Row struct {
+	ID     int64
+	Values map[string][]byte
+}
+
+// Proxy is an in-memory stand-in for CipherStash Proxy and the tables behind it.
+// A Proxy is safe for concurrent use.
+type Proxy struct {
+	registry *goeql.Registry
+	aead     cipher.AEAD
+
+	mu     sync.Mutex
+	tables map[string][]storedRow
+	nextID int64
+}
+
+// storedRow holds the ciphertext payload of each non NULL column
+type storedRow struct {
+	id     int64
+	values map[string][]byte
+}
+
+// NewProxy returns an empty Proxy with a random encryption key. When r is not nil,
+// only the columns it declares can be stored, and queries are checked against
+// their indexes and compared according to their cast types.
+func NewProxy(r *goeql.Registry) *Proxy {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("goeqltest: error generating key: %v", err))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Sprintf("goeqltest: error creating cipher: %v", err))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("goeqltest: error creating cipher: %v", err))
+	}
+	return &Proxy{registry: r, aead: aead, tables: make(map[string][]storedRow)}
+}
+
+// payload holds the fields of a plaintext or ciphertext payload
+type payload struct {
+	K  string             `json:"k"`
+	P  *string            `json:"p,omitempty"`
+	C  *string            `json:"c,omitempty"`
+	I  *goeql.TableColumn `json:"i"`
+	V  int                `json:"v"`
+	Q  json.RawMessage    `json:"q,omitempty"`
+	KS *goeql.Keyset      `json:"ks,omitempty"`
+}
+
+func parsePayload(data []byte, kind string) (payload, error) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid payload: %v", err)
+	}
+	switch {
+	case p.K != kind:
+		return p, fmt.Errorf("invalid payload: expected kind %q, got %q", kind, p.K)
+	case goeql.EQLVersion(p.V).Validate() != nil:
+		return p, fmt.Errorf("invalid payload: unsupported version %d", p.V)
+	case p.I == nil || p.I.T == "" || p.I.C == "":
+		return p, fmt.Errorf("invalid payload: missing table and column identifier")
+	case kind == "pt" && p.P == nil:
+		return p, fmt.Errorf("invalid payload: missing plaintext")
+	case kind == "ct" && p.C == nil:
+		return p, fmt.Errorf("invalid payload: missing ciphertext")
+	}
+	return p, nil
+}
+
+// column returns the declared column, or a column without a cast or indexes when the proxy has no registry
+func (p *Proxy) column(table string, column string) (goeql.Column, error) {
+	if p.registry == nil {
+		return goeql.Column{Table: table, Name: column}, nil
+	}
+	c, ok := p.registry.Lookup(table, column)
+	if !ok {
+		return c, fmt.Errorf("%w: %s.%s", goeql.ErrUnknownColumn, table, column)
+	}
+	return c, nil
+}
+
+// Encrypt converts a plaintext payload into a ciphertext payload, as the proxy does
+// for values written to an encrypted column, keeping the payload's EQL version.
+// Query payloads cannot be stored.
+func (p *Proxy) Encrypt(data []byte) ([]byte, error) {
+	pt, err := parsePayload(data, "pt")
+	if err != nil {
+		return nil, err
+	}
+	q, err := goeql.ParseQueryDescriptor(pt.Q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	if q != nil {
+		return nil, fmt.Errorf("cannot store a %s query payload in %s.%s", q.QueryType(), pt.I.T, pt.I.C)
+	}
+	c, err := p.column(pt.I.T, pt.I.C)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCast(c.Cast, *pt.P); err != nil {
+		return nil, fmt.Errorf("invalid value for %s.%s: %v", pt.I.T, pt.I.C, err)
+	}
+
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := p.aead.Seal(nonce, nonce, []byte(*pt.P), additionalData(pt))
+	ciphertext := base64.StdEncoding.EncodeToString(sealed)
+	return json.Marshal(payload{K: "ct", C: &ciphertext, I: pt.I, V: pt.V, KS: pt.KS})
+}
+
+// Decrypt converts a ciphertext payload returned by Encrypt into the plaintext payload
+// the proxy returns to clients
+func (p *Proxy) Decrypt(data []byte) ([]byte, error) {
+	ct, err := parsePayload(data, "ct")
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := p.open(ct)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(goeql.EncryptedColumn{K: "pt", P: plaintext, I: *ct.I, V: ct.V, KS: ct.KS})
+}
+
+func (p *Proxy) open(ct payload) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(*ct.C)
+	if err != nil || len(sealed) < p.aead.NonceSize() {
+		return "", fmt.Errorf("error decrypting %s.%s: malformed ciphertext", ct.I.T, ct.I.C)
+	}
+	nonce, sealed := sealed[:p.aead.NonceSize()], sealed[p.aead.NonceSize():]
+	plaintext, err := p.aead.Open(nil, nonce, sealed, additionalData(ct))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting %s.%s: %v", ct.I.T, ct.I.C, err)
+	}
+	return string(plaintext), nil
+}
+
+// checkCast checks a plaintext can be decrypted to the cast type
+func checkCast(cast goeql.CastType, plaintext string) error {
+	var err error
+	switch cast {
+	case goeql.CastInt, goeql.CastSmallInt, goeql.CastBigInt:
+		_, err = strconv.ParseInt(plaintext, 10, 64)
+	case goeql.CastReal, goeql.CastDouble:
+		_, err = strconv.ParseFloat(plaintext, 64)
+	case goeql.CastBoolean:
+		_, err = strconv.ParseBool(plaintext)
+	case goeql.CastJsonb:
+		if !json.Valid([]byte(plaintext)) {
+			return fmt.Errorf("not a %s value", cast)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("not a %s value", cast)
+	}
+	return nil
+}
+
+// additionalData binds a ciphertext to its table, column and keyset
+func additionalData(p payload) []byte {
+	ad, _ := json.Marshal([]any{p.I.T, p.I.C, p.KS})
+	return ad
+}
+
+// Insert encrypts and stores a row of plaintext payloads keyed by column, returning its ID.
+// Empty payloads, such as those Serialize returns for zero values, are stored as NULL.
+func (p *Proxy) Insert(table string, values map[string][]byte) (int64, error) {
+	row := storedRow{values: make(map[string][]byte, len(values))}
+	for column, data := range values {
+		if len(data) == 0 {
+			continue
+		}
+		if err := checkIdentity(data, table, column); err != nil {
+			return 0, err
+		}
+		ct, err := p.Encrypt(data)
+		if err != nil {
+			return 0, err
+		}
+		row.values[column] = ct
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextID++
+	row.id = p.nextID
+	p.tables[table] = append(p.tables[table], row)
+	return row.id, nil
+}
+
+// checkIdentity checks a payload belongs to the column it is stored in
+func checkIdentity(data []byte, table string, column string) error {
+	var id struct {
+		I goeql.TableColumn `json:"i"`
+	}
+	if err := json.Unmarshal(data, &id); err != nil {
+		return fmt.Errorf("invalid payload for %s.%s: %v", table, column, err)
+	}
+	if id.I.T != table || id.I.C != column {
+		return fmt.Errorf("payload for %s.%s cannot be stored in %s.%s", id.I.T, id.I.C, table, column)
+	}
+	return nil
+}
+
+// Get returns the row with the ID, decrypted into plaintext payloads
+func (p *Proxy) Get(table string, id int64) (Row, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, row := range p.tables[table] {
+		if row.id == id {
+			return p.decryptRow(row)
+		}
+	}
+	return Row{}, fmt.Errorf("%w: %s %d", ErrNotFound, table, id)
+}
+
+// Select returns the rows matching every condition, ordered by ID and decrypted into plaintext payloads
+func (p *Proxy) Select(table string, where ...Condition) ([]Row, error) {
+	queries := make([]query, len(where))
+	for i, cond := range where {
+		q, err := p.parseQuery(table, cond)
+		if err != nil {
+			return nil, err
+		}
+		queries[i] = q
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var rows []Row
+	for _, row := range p.tables[table] {
+		ok, err := p.matches(row, queries)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		decrypted, err := p.decryptRow(row)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, decrypted)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+// Len returns the number of rows stored in the table
+func (p *Proxy) Len(table string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tables[table])
+}
+
+func (p *Proxy) decryptRow(row storedRow) (Row, error) {
+	decrypted := Row{ID: row.id, Values: make(map[string][]byte, len(row.values))}
+	for column, ct := range row.values {
+		pt, err := p.Decrypt(ct)
+		if err != nil {
+			return Row{}, err
+		}
+		decrypted.Values[column] = pt
+	}
+	return decrypted, nil
+}
+
+// query is a checked condition
+type query struct {
+	column  goeql.Column
+	op      Operator
+	payload []byte
+	keyset  *goeql.Keyset
+}
+
+func (p *Proxy) parseQuery(table string, cond Condition) (query, error) {
+	pt, err := parsePayload(cond.Query, "pt")
+	if err != nil {
+		return query{}, err
+	}
+	q, err := goeql.ParseQueryDescriptor(pt.Q)
+	if err != nil {
+		return query{}, fmt.Errorf("invalid payload: %v", err)
+	}
+	if q == nil {
+		return query{}, fmt.Errorf("payload for %s.%s is not a query, use the goeql query helpers", table, cond.Column)
+	}
+	if p.registry != nil {
+		if err := p.registry.CheckQuery(table, cond.Column, q); err != nil {
+			return query{}, err
+		}
+	}
+	c, err := p.column(table, cond.Column)
+	if err != nil {
+		return query{}, err
+	}
+	// Evaluating the query against no value checks its identity, type and operator
+	if _, err := c.Evaluate(nil, cond.Op, cond.Query); err != nil {
+		return query{}, err
+	}
+	return query{column: c, op: cond.Op, payload: cond.Query, keyset: pt.KS}, nil
+}
+
+func (p *Proxy) matches(row storedRow, queries []query) (bool, error) {
+	for _, q := range queries {
+		ct, ok := row.values[q.column.Name]
+		if !ok {
+			// NULL matches no comparison
+			return false, nil
+		}
+		stored, err := parsePayload(ct, "ct")
+		if err != nil {
+			return false, err
+		}
+		if !sameKeyset(stored.KS, q.keyset) {
+			return false, nil
+		}
+		value, err := p.open(stored)
+		if err != nil {
+			return false, err
+		}
+		ok, err = q.column.Evaluate(value, q.op, q.payload)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func sameKeyset(a *goeql.Keyset, b *goeql.Keyset) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,331 @@
+package goeqltest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cipherstash/goeql"
+)
+
+// insert serializes and stores a user, failing the test on error
+func insert(t *testing.T, proxy *Proxy, email string, age int) int64 {
+	t.Helper()
+	emailPayload, err := goeql.EncryptedText(email).Serialize("users", "email")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	agePayload, err := goeql.EncryptedInt(age).Serialize("users", "age")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	id, err := proxy.Insert("users", map[string][]byte{"email": emailPayload, "age": agePayload})
+	if err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+	return id
+}
+
+// emails deserializes the email of each row
+func emails(t *testing.T, rows []Row) []string {
+	t.Helper()
+	var got []string
+	for _, row := range rows {
+		var email goeql.EncryptedText
+		email, err := email.Deserialize(row.Values["email"])
+		if err != nil {
+			t.Fatalf("Deserialize returned error: %v", err)
+		}
+		got = append(got, email.Reveal())
+	}
+	return got
+}
+
+// selectEmails runs a query and returns the matching emails
+func selectEmails(t *testing.T, proxy *Proxy, where ...Condition) string {
+	t.Helper()
+	rows, err := proxy.Select("users", where...)
+	if err != nil {
+		t.Fatalf("Select returned error: %v", err)
+	}
+	return strings.Join(emails(t, rows), " ")
+}
+
+// mustQuery returns a function failing the test when a query helper returns an error
+func mustQuery(t *testing.T) func(data []byte, err error) []byte {
+	return func(data []byte, err error) []byte {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Query helper returned error: %v", err)
+		}
+		return data
+	}
+}
+
+// Test values round trip through Insert, Get and Deserialize
+func TestProxy_RoundTrip(t *testing.T) {
+	proxy := NewProxy(nil)
+	id := insert(t, proxy, "alice@example.com", 30)
+
+	row, err := proxy.Get("users", id)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	var age goeql.EncryptedInt
+	age, err = age.Deserialize(row.Values["age"])
+	if err != nil || age.Reveal() != 30 {
+		t.Errorf("Expected age 30, got %d, %v", age.Reveal(), err)
+	}
+	if got := emails(t, []Row{row}); len(got) != 1 || got[0] != "alice@example.com" {
+		t.Errorf("Expected alice@example.com, got %v", got)
+	}
+
+	if _, err := proxy.Get("users", id+1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// Test stored values are ciphertext and do not contain the plaintext
+func TestProxy_Encrypt(t *testing.T) {
+	proxy := NewProxy(nil)
+	pt, _ := goeql.EncryptedText("alice@example.com").Serialize("users", "email")
+	ct, err := proxy.Encrypt(pt)
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	if bytes.Contains(ct, []byte("alice")) || !bytes.Contains(ct, []byte(`"k":"ct"`)) {
+		t.Errorf("Expected a ciphertext payload, got %s", ct)
+	}
+
+	decrypted, err := proxy.Decrypt(ct)
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if !bytes.Equal(decrypted, pt) {
+		t.Errorf("Expected %s, got %s", pt, decrypted)
+	}
+
+	// The ciphertext is bound to its column
+	moved := bytes.Replace(ct, []byte(`"c":"email"`), []byte(`"c":"name"`), 1)
+	if _, err := proxy.Decrypt(moved); err == nil {
+		t.Errorf("Expected error decrypting a ciphertext moved to another column")
+	}
+	if _, err := NewProxy(nil).Decrypt(ct); err == nil {
+		t.Errorf("Expected error decrypting with another proxy's key")
+	}
+
+	q, _ := goeql.UniqueQuery("alice@example.com", "users", "email")
+	if _, err := proxy.Encrypt(q); err == nil {
+		t.Errorf("Expected error storing a query payload")
+	}
+}
+
+// Test payloads keep their EQL version through the proxy and v1 and v2 values query alike
+func TestProxy_Versions(t *testing.T) {
+	proxy := NewProxy(nil)
+	insert(t, proxy, "alice@example.com", 30)
+
+	ctx := goeql.WithEQLVersion(context.Background(), goeql.EQLv2)
+	pt, err := goeql.EncryptedText("bob@example.com").SerializeContext(ctx, "users", "email")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+	id, err := proxy.Insert("users", map[string][]byte{"email": pt})
+	if err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+	row, err := proxy.Get("users", id)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if !bytes.Equal(row.Values["email"], pt) {
+		t.Errorf("Expected %s, got %s", pt, row.Values["email"])
+	}
+
+	q := mustQuery(t)
+	where := Condition{Column: "email", Op: Eq, Query: q(goeql.UniqueQueryContext(ctx, "alice@example.com", "users", "email"))}
+	if got := selectEmails(t, proxy, where); got != "alice@example.com" {
+		t.Errorf("Expected a v2 query to match a v1 value, got %q", got)
+	}
+}
+
+// Test Select evaluates unique, ore and match queries
+func TestProxy_Select(t *testing.T) {
+	proxy := NewProxy(nil)
+	must := mustQuery(t)
+	insert(t, proxy, "alice@example.com", 30)
+	insert(t, proxy, "bob@example.com", 9)
+	insert(t, proxy, "carol@example.org", 42)
+
+	tests := []struct {
+		name     string
+		where    []Condition
+		expected string
+	}{
+		{"unique", []Condition{{"email", Eq, must(goeql.UniqueQuery("bob@example.com", "users", "email"))}}, "bob@example.com"},
+		{"unique not equal", []Condition{{"email", Ne, must(goeql.UniqueQuery("bob@example.com", "users", "email"))}}, "alice@example.com carol@example.org"},
+		{"ore compares numbers", []Condition{{"age", Gt, must(goeql.OreQuery(10, "users", "age"))}}, "alice@example.com carol@example.org"},
+		{"ore range", []Condition{
+			{"age", Gte, must(goeql.OreQuery(9, "users", "age"))},
+			{"age", Lt, must(goeql.OreQuery(42, "users", "age"))},
+		}, "alice@example.com bob@example.com"},
+		{"match", []Condition{{"email", Contains, must(goeql.MatchQuery("EXAMPLE.com", "users", "email"))}}, "alice@example.com bob@example.com"},
+		{"no conditions", nil, "alice@example.com bob@example.com carol@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectEmails(t, proxy, tt.where...); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Test Select evaluates ste_vec containment queries on jsonb columns
+func TestProxy_SelectJsonb(t *testing.T) {
+	proxy := NewProxy(nil)
+	for _, attrs := range []goeql.EncryptedJsonb{
+		{"plan": "pro", "roles": []any{"admin", "billing"}},
+		{"plan": "free", "roles": []any{"billing"}},
+	} {
+		data, err := attrs.Serialize("users", "attrs")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		if _, err := proxy.Insert("users", map[string][]byte{"attrs": data}); err != nil {
+			t.Fatalf("Insert returned error: %v", err)
+		}
+	}
+
+	q, err := goeql.RootPath().Field("roles").Index(0).ContainsQuery("admin", "users", "attrs")
+	if err != nil {
+		t.Fatalf("ContainsQuery returned error: %v", err)
+	}
+	rows, err := proxy.Select("users", Condition{"attrs", Contains, q})
+	if err != nil {
+		t.Fatalf("Select returned error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(rows))
+	}
+	var attrs goeql.EncryptedJsonb
+	attrs, err = attrs.Deserialize(rows[0].Values["attrs"])
+	if err != nil || attrs.Reveal()["plan"] != "pro" {
+		t.Errorf("Expected the pro plan, got %v, %v", attrs.Reveal(), err)
+	}
+
+	// Query descriptors scope containment to a selector
+	q, err = goeql.Query([]any{"billing"}, "users", "attrs", goeql.SteVecDescriptor{Selector: "$.roles"})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if rows, err = proxy.Select("users", Condition{"attrs", Contains, q}); err != nil || len(rows) != 2 {
+		t.Errorf("Expected 2 rows, got %d, %v", len(rows), err)
+	}
+}
+
+// Test NULL values are stored for empty payloads and match no comparison
+func TestProxy_Null(t *testing.T) {
+	proxy := NewProxy(nil)
+	must := mustQuery(t)
+	insert(t, proxy, "alice@example.com", 30)
+	empty, _ := goeql.EncryptedText("").Serialize("users", "email")
+	id, err := proxy.Insert("users", map[string][]byte{"email": empty})
+	if err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+
+	row, err := proxy.Get("users", id)
+	if err != nil || len(row.Values) != 0 {
+		t.Errorf("Expected a row of NULLs, got %+v, %v", row, err)
+	}
+	if got := selectEmails(t, proxy, Condition{"email", Ne, must(goeql.UniqueQuery("bob", "users", "email"))}); got != "alice@example.com" {
+		t.Errorf("Expected NULL to match no comparison, got %q", got)
+	}
+}
+
+// Test values only match queries under the same keyset
+func TestProxy_Keysets(t *testing.T) {
+	proxy := NewProxy(nil)
+	must := mustQuery(t)
+	acme := goeql.WithKeyset(context.Background(), goeql.KeysetName("acme"))
+	globex := goeql.WithKeyset(context.Background(), goeql.KeysetName("globex"))
+
+	data, err := goeql.EncryptedText("alice@example.com").SerializeContext(acme, "users", "email")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+	if _, err := proxy.Insert("users", map[string][]byte{"email": data}); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+
+	for name, tt := range map[string]struct {
+		ctx      context.Context
+		expected string
+	}{
+		"same keyset":    {acme, "alice@example.com"},
+		"other keyset":   {globex, ""},
+		"default keyset": {context.Background(), ""},
+	} {
+		q := must(goeql.UniqueQueryContext(tt.ctx, "alice@example.com", "users", "email"))
+		if got := selectEmails(t, proxy, Condition{"email", Eq, q}); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", name, tt.expected, got)
+		}
+	}
+}
+
+// Test the registry limits stored columns, query types and casts
+func TestProxy_Registry(t *testing.T) {
+	r, err := goeql.NewRegistry(
+		goeql.Column{Table: "users", Name: "email", Cast: goeql.CastText, Indexes: []goeql.IndexType{goeql.UniqueIndex}},
+		goeql.Column{Table: "users", Name: "age", Cast: goeql.CastInt, Indexes: []goeql.IndexType{goeql.OreIndex}},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	proxy := NewProxy(r)
+	insert(t, proxy, "alice@example.com", 30)
+
+	name, _ := goeql.EncryptedText("Alice").Serialize("users", "name")
+	if _, err := proxy.Insert("users", map[string][]byte{"name": name}); !errors.Is(err, goeql.ErrUnknownColumn) {
+		t.Errorf("Expected ErrUnknownColumn, got %v", err)
+	}
+	notInt, _ := goeql.EncryptedText("thirty").Serialize("users", "age")
+	if _, err := proxy.Insert("users", map[string][]byte{"age": notInt}); err == nil {
+		t.Errorf("Expected error storing text in an int column")
+	}
+
+	match, _ := goeql.MatchQuery("alice", "users", "email")
+	if _, err := proxy.Select("users", Condition{"email", Contains, match}); !errors.Is(err, goeql.ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery, got %v", err)
+	}
+}
+
+// Test Insert and Select reject payloads for other columns and mismatched operators
+func TestProxy_Errors(t *testing.T) {
+	proxy := NewProxy(nil)
+	must := mustQuery(t)
+	insert(t, proxy, "alice@example.com", 30)
+	email, _ := goeql.EncryptedText("alice@example.com").Serialize("users", "email")
+
+	if _, err := proxy.Insert("users", map[string][]byte{"name": email}); err == nil {
+		t.Errorf("Expected error storing a users.email payload in users.name")
+	}
+
+	unique, _ := goeql.UniqueQuery("alice@example.com", "users", "email")
+	tests := map[string]Condition{
+		"wrong column":      {"age", Eq, unique},
+		"wrong operator":    {"email", Lt, unique},
+		"not a query":       {"email", Eq, email},
+		"ejson_path":        {"email", Eq, must(goeql.EJsonPathQuery("$.a", "users", "email"))},
+		"invalid payload":   {"email", Eq, []byte("{")},
+		"missing plaintext": {"email", Eq, []byte(`{"k":"pt","i":{"t":"users","c":"email"},"v":1,"q":"unique"}`)},
+	}
+	for name, cond := range tests {
+		if _, err := proxy.Select("users", cond); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
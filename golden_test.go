@@ -0,0 +1,346 @@
+package goeql
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Golden payloads guard the wire format. Each directory in testdata/golden holds
+// the payloads for an EQL payload version, with a manifest describing how each
+// payload was produced and what it decodes to. Payloads marked encode must be
+// produced byte for byte when serializing for their version. Every payload in
+// every version, including those from older goeql versions and the proxy, must
+// still decode.
+//
+// manifest.json lists EQL payloads the proxy accepts. goeql-manifest.json lists
+// payloads whose shape goeql defines and EQL does not, such as query descriptors
+// with options in "q", which pin goeql's own encoding but may not be accepted by
+// the proxy.
+//
+// After an intentional wire format change, rewrite the encoded payloads with
+//
+//	go test -run TestGolden_Encode -update
+
+var updateGolden = flag.Bool("update", false, "rewrite the encoded golden payloads")
+
+// goldenCase is an entry in a golden manifest
+type goldenCase struct {
+	Name string `json:"name"`
+	// Description notes where a decode only payload comes from
+	Description string `json:"description,omitempty"`
+	// Type is text, int, bool, jsonb or jsonb_array, or strings for the []string queries
+	// that keep the bracketed plaintext of typed slices
+	Type   string          `json:"type"`
+	Value  json.RawMessage `json:"value"`
+	Table  string          `json:"table"`
+	Column string          `json:"column"`
+	Query  string          `json:"query,omitempty"`
+	// Descriptor is the query descriptor of queries with options, instead of Query.
+	// It is only allowed in goeql-manifest.json.
+	Descriptor json.RawMessage `json:"descriptor,omitempty"`
+	Keyset     *Keyset         `json:"keyset,omitempty"`
+	// Encode is set for payloads the current version produces
+	Encode bool `json:"encode,omitempty"`
+}
+
+func (c goldenCase) path(version string) string {
+	return filepath.Join("testdata", "golden", version, c.Name+".json")
+}
+
+// plaintext returns the case's value as the Encrypted* type for its type
+func (c goldenCase) plaintext() (any, error) {
+	var err error
+	switch c.Type {
+	case "text":
+		var v string
+		err = json.Unmarshal(c.Value, &v)
+		return EncryptedText(v), err
+	case "int":
+		var v int
+		err = json.Unmarshal(c.Value, &v)
+		return EncryptedInt(v), err
+	case "bool":
+		var v bool
+		err = json.Unmarshal(c.Value, &v)
+		return EncryptedBool(v), err
+	case "jsonb":
+		var v map[string]any
+		err = json.Unmarshal(c.Value, &v)
+		return EncryptedJsonb(v), err
+	case "jsonb_array":
+		var v []any
+		err = json.Unmarshal(c.Value, &v)
+		return EncryptedJsonbArray(v), err
+	case "strings":
+		var v []string
+		err = json.Unmarshal(c.Value, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unknown golden type %q", c.Type)
+}
+
+// encode produces the case's payload for the EQL version
+func (c goldenCase) encode(version EQLVersion) ([]byte, error) {
+	value, err := c.plaintext()
+	if err != nil {
+		return nil, err
+	}
+	ctx := WithEQLVersion(context.Background(), version)
+	if c.Keyset != nil {
+		ctx = WithKeyset(ctx, *c.Keyset)
+	}
+	if c.Descriptor != nil {
+		q, err := ParseQueryDescriptor(c.Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		return QueryContext(ctx, value, c.Table, c.Column, q)
+	}
+
+	switch c.Query {
+	case "":
+		return value.(interface {
+			SerializeContext(context.Context, string, string) ([]byte, error)
+		}).SerializeContext(ctx, c.Table, c.Column)
+	case "match":
+		return MatchQueryContext(ctx, value, c.Table, c.Column)
+	case "ore":
+		return OreQueryContext(ctx, value, c.Table, c.Column)
+	case "unique":
+		return UniqueQueryContext(ctx, value, c.Table, c.Column)
+	case "ste_vec":
+		return JsonbQueryContext(ctx, value, c.Table, c.Column)
+	case "ejson_path":
+		return EJsonPathQueryContext(ctx, string(value.(EncryptedText)), c.Table, c.Column)
+	}
+	return nil, fmt.Errorf("unknown golden query type %q", c.Query)
+}
+
+// decode decodes a stored value payload with Deserialize for the case's type
+func (c goldenCase) decode(data []byte) (any, error) {
+	switch c.Type {
+	case "text":
+		var v EncryptedText
+		return v.Deserialize(data)
+	case "int":
+		var v EncryptedInt
+		return v.Deserialize(data)
+	case "bool":
+		var v EncryptedBool
+		return v.Deserialize(data)
+	case "jsonb":
+		var v EncryptedJsonb
+		return v.Deserialize(data)
+	case "jsonb_array":
+		var v EncryptedJsonbArray
+		return v.Deserialize(data)
+	}
+	return nil, fmt.Errorf("unknown golden type %q", c.Type)
+}
+
+// decodeWith decodes a stored value payload with a Decoder for the case's type
+func (c goldenCase) decodeWith(d *Decoder, data []byte) (any, error) {
+	switch c.Type {
+	case "text":
+		return d.Text(data)
+	case "int":
+		return d.Int(data)
+	case "bool":
+		return d.Bool(data)
+	case "jsonb":
+		return d.Jsonb(data)
+	case "jsonb_array":
+		return d.JsonbArray(data)
+	}
+	return nil, fmt.Errorf("unknown golden type %q", c.Type)
+}
+
+// goldenVersion returns the EQL version of a golden directory, e.g. 2 for v2
+func goldenVersion(t *testing.T, dir string) EQLVersion {
+	t.Helper()
+	n, err := strconv.Atoi(strings.TrimPrefix(dir, "v"))
+	if err != nil {
+		t.Fatalf("Invalid golden version directory %s", dir)
+	}
+	return EQLVersion(n)
+}
+
+// goldenVersions returns the payload versions in testdata/golden
+func goldenVersions(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join("testdata", "golden"))
+	if err != nil {
+		t.Fatalf("Error reading golden versions: %v", err)
+	}
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() {
+			versions = append(versions, e.Name())
+		}
+	}
+	return versions
+}
+
+// goldenManifests are the manifests of each version, the EQL payloads and goeql's own payloads
+var goldenManifests = []string{"manifest.json", "goeql-manifest.json"}
+
+func loadGoldenManifest(t *testing.T, version string, manifest string) []goldenCase {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "golden", version, manifest))
+	if err != nil {
+		t.Fatalf("Error reading golden manifest: %v", err)
+	}
+	var cases []goldenCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("Error parsing golden manifest %s: %v", manifest, err)
+	}
+	return cases
+}
+
+// loadGoldenCases returns the cases of every manifest of the version
+func loadGoldenCases(t *testing.T, version string) []goldenCase {
+	t.Helper()
+	var cases []goldenCase
+	for _, manifest := range goldenManifests {
+		cases = append(cases, loadGoldenManifest(t, version, manifest)...)
+	}
+	return cases
+}
+
+// Test every version encodes its golden payloads byte for byte
+func TestGolden_Encode(t *testing.T) {
+	for _, version := range goldenVersions(t) {
+		v := goldenVersion(t, version)
+		for _, c := range loadGoldenCases(t, version) {
+			if !c.Encode {
+				continue
+			}
+			t.Run(version+"/"+c.Name, func(t *testing.T) {
+				got, err := c.encode(v)
+				if err != nil {
+					t.Fatalf("Encoding returned error: %v", err)
+				}
+				if *updateGolden {
+					if err := os.WriteFile(c.path(version), got, 0o644); err != nil {
+						t.Fatalf("Error writing golden payload: %v", err)
+					}
+					return
+				}
+				expected, err := os.ReadFile(c.path(version))
+				if err != nil {
+					t.Fatalf("Error reading golden payload: %v", err)
+				}
+				if string(got) != string(expected) {
+					t.Errorf("Wire format changed for %s:\nexpected %s\ngot      %s", c.Name, expected, got)
+				}
+			})
+		}
+	}
+}
+
+// Test every golden payload of every version still decodes to its value
+func TestGolden_Decode(t *testing.T) {
+	for _, version := range goldenVersions(t) {
+		for _, c := range loadGoldenCases(t, version) {
+			t.Run(version+"/"+c.Name, func(t *testing.T) {
+				data, err := os.ReadFile(c.path(version))
+				if err != nil {
+					t.Fatalf("Error reading golden payload: %v", err)
+				}
+
+				payload, err := DecodePayload(data)
+				if err != nil {
+					t.Fatalf("DecodePayload returned error: %v", err)
+				}
+				if EQLVersion(payload.V) != goldenVersion(t, version) {
+					t.Errorf("Expected version %s, got %d", version, payload.V)
+				}
+				if payload.I != (TableColumn{T: c.Table, C: c.Column}) {
+					t.Errorf("Expected identifier %s.%s, got %+v", c.Table, c.Column, payload.I)
+				}
+
+				var fields struct {
+					Q  any     `json:"q"`
+					KS *Keyset `json:"ks"`
+				}
+				if err := json.Unmarshal(data, &fields); err != nil {
+					t.Fatalf("Error unmarshaling payload: %v", err)
+				}
+				if c.Descriptor != nil {
+					expected, err := ParseQueryDescriptor(c.Descriptor)
+					if err != nil || !reflect.DeepEqual(payload.Q, expected) {
+						t.Errorf("Expected query descriptor %s, got %#v, %v", c.Descriptor, payload.Q, err)
+					}
+				} else if q, _ := fields.Q.(string); q != c.Query {
+					t.Errorf("Expected query type %q, got %v", c.Query, fields.Q)
+				}
+				if c.Keyset != nil && !reflect.DeepEqual(fields.KS, c.Keyset) {
+					t.Errorf("Expected keyset %+v, got %+v", c.Keyset, fields.KS)
+				}
+				if c.Query != "" || c.Descriptor != nil {
+					return
+				}
+
+				expected, err := c.plaintext()
+				if err != nil {
+					t.Fatalf("Error reading golden value: %v", err)
+				}
+				got, err := c.decode(data)
+				if err != nil || !reflect.DeepEqual(got, expected) {
+					t.Errorf("Deserialize: expected %#v, got %#v, %v", expected, got, err)
+				}
+				var d Decoder
+				got, err = c.decodeWith(&d, data)
+				if err != nil || !reflect.DeepEqual(got, expected) {
+					t.Errorf("Decoder: expected %#v, got %#v, %v", expected, got, err)
+				}
+			})
+		}
+	}
+}
+
+// Test every golden payload file is listed in one of its version's manifests, and only
+// goeql's own manifest lists query descriptors
+func TestGolden_Manifest(t *testing.T) {
+	for _, version := range goldenVersions(t) {
+		listed := map[string]bool{}
+		for _, manifest := range goldenManifests {
+			listed[manifest] = true
+		}
+		for _, c := range loadGoldenCases(t, version) {
+			if listed[c.Name+".json"] {
+				t.Errorf("%s: duplicate golden case %s", version, c.Name)
+			}
+			listed[c.Name+".json"] = true
+		}
+		for _, c := range loadGoldenManifest(t, version, "manifest.json") {
+			if c.Descriptor != nil {
+				t.Errorf("%s: query descriptor case %s belongs in goeql-manifest.json", version, c.Name)
+			}
+		}
+
+		entries, err := os.ReadDir(filepath.Join("testdata", "golden", version))
+		if err != nil {
+			t.Fatalf("Error reading golden payloads: %v", err)
+		}
+		var unlisted []string
+		for _, e := range entries {
+			if !listed[e.Name()] {
+				unlisted = append(unlisted, e.Name())
+			}
+		}
+		sort.Strings(unlisted)
+		if len(unlisted) > 0 {
+			t.Errorf("%s: payloads missing from the manifest: %s", version, strings.Join(unlisted, ", "))
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package goeql
+
+// Hooks observe the values and queries being encrypted and the payloads being
+// decrypted, for metrics and tracing.
+//
+// Hooks installed with UseHooks are called around every Serialize, query
+// function and Deserialize call, each value encoded by ToEncryptedColumn,
+// EncodeBatch, CopySource, ValuesStatement and AppendEncrypted, and each
+// payload decoded by a Decoder. Events carry the column identity, query type,
+// payload size, duration and error, and never the plaintext value.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Operation is the kind of call a hook event describes
+type Operation string
+
+const (
+	// OperationEncrypt serializes a value to be encrypted
+	OperationEncrypt Operation = "encrypt"
+	// OperationQuery serializes a query value
+	OperationQuery Operation = "query"
+	// OperationDecrypt deserializes a decrypted payload
+	OperationDecrypt Operation = "decrypt"
+)
+
+// Event describes a serialize or deserialize call. Table and Column are empty in
+// Before for decrypt events, as they are read from the payload.
+type Event struct {
+	Operation Operation
+	Table     string
+	Column    string
+	// QueryType is set for query events
+	QueryType string
+	// Size is the payload size in bytes, set in After
+	Size int
+	// Duration is the time taken by the call, set in After
+	Duration time.Duration
+	// Err is the error returned by the call, set in After
+	Err error
+}
+
+// Hook is called around serialize and deserialize calls. Before returns the context
+// passed to After, e.g. carrying a tracing span. Hooks must be safe for concurrent use.
+type Hook interface {
+	Before(ctx context.Context, event Event) context.Context
+	After(ctx context.Context, event Event)
+}
+
+var activeHooks atomic.Pointer[[]Hook]
+
+// UseHooks installs the hooks called around serialize and deserialize calls,
+// replacing any installed hooks. Calling UseHooks with no hooks removes them.
+func UseHooks(hooks ...Hook) {
+	if len(hooks) == 0 {
+		activeHooks.Store(nil)
+		return
+	}
+	hooks = append([]Hook(nil), hooks...)
+	activeHooks.Store(&hooks)
+}
+
+// ErrorType classifies err without its message, which can quote the plaintext, for
+// hooks that record failed calls. goeql's sentinel errors and context errors are
+// named, other errors report their Go type.
+func ErrorType(err error) string {
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, ErrUnknownColumn):
+		return "unknown_column"
+	case errors.Is(err, ErrUnsupportedQuery):
+		return "unsupported_query"
+	case errors.Is(err, ErrUnsupportedVersion):
+		return "unsupported_version"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// hookCall tracks a call for the installed hooks. A nil *hookCall is a no-op,
+// so calls made without hooks installed do not allocate or read the clock.
+type hookCall struct {
+	hooks []Hook
+	ctxs  []context.Context
+	event Event
+	start time.Time
+}
+
+// startHooks calls Before on the installed hooks, returning nil if there are none
+func startHooks(ctx context.Context, operation Operation, table string, column string, queryType string) *hookCall {
+	hooks := activeHooks.Load()
+	if hooks == nil {
+		return nil
+	}
+
+	c := &hookCall{
+		hooks: *hooks,
+		ctxs:  make([]context.Context, len(*hooks)),
+		event: Event{Operation: operation, Table: table, Column: column, QueryType: queryType},
+	}
+	for i, h := range c.hooks {
+		c.ctxs[i] = h.Before(ctx, c.event)
+	}
+	c.start = time.Now()
+	return c
+}
+
+// identify sets the column identity read from a decrypted payload
+func (c *hookCall) identify(table string, column string) {
+	if c == nil {
+		return
+	}
+	c.event.Table = table
+	c.event.Column = column
+}
+
+// end calls After on the installed hooks in reverse order
+func (c *hookCall) end(size int, err error) {
+	if c == nil {
+		return
+	}
+	c.event.Duration = time.Since(c.start)
+	c.event.Size = size
+	c.event.Err = err
+	for i := len(c.hooks) - 1; i >= 0; i-- {
+		c.hooks[i].After(c.ctxs[i], c.event)
+	}
+}
+
+// encodeOperation is the operation of encoding a payload with queryType, which is empty for stored values
+func encodeOperation(queryType string) Operation {
+	if queryType == "" {
+		return OperationEncrypt
+	}
+	return OperationQuery
+}
+
+// encryptValue serializes value into a payload with toEncryptedColumn, calling the installed hooks
+func encryptValue(ctx context.Context, value any, table string, column string) ([]byte, error) {
+	call := startHooks(ctx, OperationEncrypt, table, column, "")
+
+	val, err := toEncryptedColumn(ctx, value, table, column, nil)
+	if err != nil {
+		err = fmt.Errorf("error serializing: %v", err)
+		call.end(0, err)
+		return nil, err
+	}
+	data, err := json.Marshal(val)
+	call.end(len(data), err)
+	return data, err
+}
+
+// payloadIdentity reads the column identity from an unmarshaled payload
+func payloadIdentity(jsonData map[string]interface{}) (string, string) {
+	i, _ := jsonData["i"].(map[string]interface{})
+	table, _ := i["t"].(string)
+	column, _ := i["c"].(string)
+	return table, column
+}
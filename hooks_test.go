@@ -0,0 +1,254 @@
+package goeql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type hookContextKey struct{}
+
+// recordingHook records the events passed to After, and checks Before's context reaches After
+type recordingHook struct {
+	mu     sync.Mutex
+	events []Event
+	lost   int
+}
+
+func (h *recordingHook) Before(ctx context.Context, event Event) context.Context {
+	return context.WithValue(ctx, hookContextKey{}, event.Operation)
+}
+
+func (h *recordingHook) After(ctx context.Context, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Value(hookContextKey{}) != event.Operation {
+		h.lost++
+	}
+	h.events = append(h.events, event)
+}
+
+func useRecordingHook(t *testing.T) *recordingHook {
+	h := &recordingHook{}
+	UseHooks(h)
+	t.Cleanup(func() { UseHooks() })
+	return h
+}
+
+// Test hooks observe serialize, query and deserialize calls
+func TestHooks_Events(t *testing.T) {
+	h := useRecordingHook(t)
+
+	data, err := EncryptedText("alice@example.com").Serialize("users", "email")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	query, err := MatchQuery("alice", "users", "email")
+	if err != nil {
+		t.Fatalf("MatchQuery returned error: %v", err)
+	}
+	var et EncryptedText
+	if _, err := et.Deserialize(data); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+
+	expected := []Event{
+		{Operation: OperationEncrypt, Table: "users", Column: "email", Size: len(data)},
+		{Operation: OperationQuery, Table: "users", Column: "email", QueryType: "match", Size: len(query)},
+		{Operation: OperationDecrypt, Table: "users", Column: "email", Size: len(data)},
+	}
+	if len(h.events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(h.events), h.events)
+	}
+	for i, event := range h.events {
+		event.Duration = 0
+		if event != expected[i] {
+			t.Errorf("Expected event %+v, got %+v", expected[i], event)
+		}
+	}
+	if h.lost != 0 {
+		t.Errorf("Expected the Before context to reach After, lost %d", h.lost)
+	}
+}
+
+// Test hooks observe errors
+func TestHooks_Errors(t *testing.T) {
+	h := useRecordingHook(t)
+
+	if _, err := EJsonPathQuery(RootPath().Index(-1), "users", "data"); err == nil {
+		t.Fatalf("Expected error for invalid path")
+	}
+	var ei EncryptedInt
+	if _, err := ei.Deserialize([]byte(`{"k":"pt","p":"abc","i":{"t":"users","c":"age"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected error for invalid number")
+	}
+
+	if len(h.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d: %+v", len(h.events), h.events)
+	}
+	event := h.events[0]
+	if event.Operation != OperationDecrypt || event.Table != "users" || event.Column != "age" || event.Err == nil {
+		t.Errorf("Expected failed decrypt event for users.age, got %+v", event)
+	}
+}
+
+// Test hooks never see the plaintext
+func TestHooks_NoPlaintext(t *testing.T) {
+	h := useRecordingHook(t)
+
+	var ei EncryptedInt
+	_, _ = ei.Deserialize([]byte(`{"k":"pt","p":"secret-value","i":{"t":"users","c":"age"},"v":1,"q":null}`))
+	_, _ = ToEncryptedColumnContext(context.Background(), "secret-value", "users", "age", nil)
+	_, _ = NewColumn("users", "age").Encrypt("secret-value")
+
+	for _, event := range h.events {
+		if event.Err != nil && strings.Contains(event.Err.Error(), "secret-value") {
+			t.Errorf("Expected the error to omit the plaintext, got %v", event.Err)
+		}
+	}
+	if len(h.events) != 3 {
+		t.Errorf("Expected 3 events, got %d: %+v", len(h.events), h.events)
+	}
+}
+
+// Test hooks observe every encode and decode entry point
+func TestHooks_EntryPoints(t *testing.T) {
+	ctx := context.Background()
+	payload := func(p string) []byte {
+		return []byte(`{"k":"pt","p":` + p + `,"i":{"t":"users","c":"data"},"v":1,"q":null}`)
+	}
+	rows := [][]any{{1, "alice"}}
+	encrypted := map[int]TableColumn{1: {T: "users", C: "data"}}
+	var d Decoder
+
+	tests := []struct {
+		name      string
+		fn        func() error
+		operation Operation
+		queryType string
+		events    int
+	}{
+		{"ToEncryptedColumn", func() error { _, err := ToEncryptedColumn("alice", "users", "data", nil); return err }, OperationEncrypt, "", 1},
+		{"ToEncryptedColumn query", func() error { _, err := ToEncryptedColumn("alice", "users", "data", "match"); return err }, OperationQuery, "match", 1},
+		{"EncodeBatch", func() error { _, err := EncodeBatch("users", "data", []any{"alice", nil, "bob"}); return err }, OperationEncrypt, "", 2},
+		{"NewCopySource", func() error {
+			source, err := NewCopySource(rows, encrypted)
+			if err != nil {
+				return err
+			}
+			for source.Next() {
+			}
+			return source.Err()
+		}, OperationEncrypt, "", 1},
+		{"ValuesStatement", func() error {
+			_, _, err := ValuesStatement("users", []string{"id", "data"}, rows, encrypted)
+			return err
+		}, OperationEncrypt, "", 1},
+		{"AppendEncrypted", func() error { AppendEncrypted(nil, "alice", "users", "data", "unique"); return nil }, OperationQuery, "unique", 1},
+		{"AppendEncryptedValue", func() error { _, err := AppendEncryptedValue(nil, 42, "users", "data", ""); return err }, OperationEncrypt, "", 1},
+		{"AppendEncryptedContext", func() error { _, err := AppendEncryptedContext(ctx, nil, "alice", "users", "data", ""); return err }, OperationEncrypt, "", 1},
+		{"AppendEncryptedValueContext", func() error { _, err := AppendEncryptedValueContext(ctx, nil, 42, "users", "data", "ore"); return err }, OperationQuery, "ore", 1},
+		{"Decoder.Decode", func() error { _, err := d.Decode(payload(`"alice"`)); return err }, OperationDecrypt, "", 1},
+		{"Decoder.Text", func() error { _, err := d.Text(payload(`"alice"`)); return err }, OperationDecrypt, "", 1},
+		{"Decoder.Int", func() error { _, err := d.Int(payload(`"42"`)); return err }, OperationDecrypt, "", 1},
+		{"Decoder.Bool", func() error { _, err := d.Bool(payload(`"true"`)); return err }, OperationDecrypt, "", 1},
+		{"Decoder.Jsonb", func() error { _, err := d.Jsonb(payload(`"{\"a\":1}"`)); return err }, OperationDecrypt, "", 1},
+		{"Decoder.JsonbArray", func() error { _, err := d.JsonbArray(payload(`"[1,2]"`)); return err }, OperationDecrypt, "", 1},
+	}
+
+	for _, tt := range tests {
+		h := useRecordingHook(t)
+		if err := tt.fn(); err != nil {
+			t.Fatalf("%s returned error: %v", tt.name, err)
+		}
+		if len(h.events) != tt.events {
+			t.Fatalf("%s: expected %d events, got %d: %+v", tt.name, tt.events, len(h.events), h.events)
+		}
+		for _, event := range h.events {
+			if event.Operation != tt.operation || event.Table != "users" || event.Column != "data" || event.QueryType != tt.queryType {
+				t.Errorf("%s: unexpected event %+v", tt.name, event)
+			}
+			// ToEncryptedColumn returns the payload unmarshaled and reports no size
+			if (event.Size == 0) != strings.HasPrefix(tt.name, "ToEncryptedColumn") {
+				t.Errorf("%s: unexpected payload size %d", tt.name, event.Size)
+			}
+		}
+	}
+}
+
+// Test a Decoder reports the column of a payload it is not allowed to decode
+func TestHooks_DecoderPolicy(t *testing.T) {
+	h := useRecordingHook(t)
+	UsePolicy(&Policy{})
+	t.Cleanup(func() { UsePolicy(nil) })
+
+	var d Decoder
+	if _, err := d.Text([]byte(`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected the policy to deny decoding")
+	}
+	if len(h.events) != 1 || h.events[0].Table != "users" || h.events[0].Column != "email" || h.events[0].Err == nil {
+		t.Errorf("Expected a failed decrypt event for users.email, got %+v", h.events)
+	}
+}
+
+// Test removing hooks stops events
+func TestUseHooks_Remove(t *testing.T) {
+	h := useRecordingHook(t)
+	UseHooks()
+
+	if _, err := EncryptedInt(42).Serialize("users", "age"); err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	if len(h.events) != 0 {
+		t.Errorf("Expected no events after removing hooks, got %+v", h.events)
+	}
+}
+
+// Test hooks run Before in order and After in reverse order
+func TestHooks_Order(t *testing.T) {
+	var calls []string
+	first := orderHook{name: "first", calls: &calls}
+	second := orderHook{name: "second", calls: &calls}
+	UseHooks(first, second)
+	t.Cleanup(func() { UseHooks() })
+
+	if _, err := UniqueQuery("alice", "users", "email"); err != nil {
+		t.Fatalf("UniqueQuery returned error: %v", err)
+	}
+	expected := "before first, before second, after second, after first"
+	if got := strings.Join(calls, ", "); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+type orderHook struct {
+	name  string
+	calls *[]string
+}
+
+func (h orderHook) Before(ctx context.Context, event Event) context.Context {
+	*h.calls = append(*h.calls, "before "+h.name)
+	return ctx
+}
+
+func (h orderHook) After(ctx context.Context, event Event) {
+	*h.calls = append(*h.calls, "after "+h.name)
+}
+
+// Test ErrorType names goeql's sentinel errors
+func TestErrorType(t *testing.T) {
+	tests := map[error]string{
+		&PermissionError{Principal: "alice", Table: "users", Column: "email", Action: ActionDecode}: "permission_denied",
+		fmt.Errorf("users.email: %w", ErrUnsupportedQuery):                                          "unsupported_query",
+		context.DeadlineExceeded:     "deadline_exceeded",
+		errors.New("invalid format"): "*errors.errorString",
+	}
+	for err, expected := range tests {
+		if got := ErrorType(err); got != expected {
+			t.Errorf("ErrorType(%v) = %q, expected %q", err, got, expected)
+		}
+	}
+}
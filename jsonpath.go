@@ -0,0 +1,316 @@
+package goeql
+
+// JSON path and containment helpers for ste_vec and ejson_path queries.
+//
+// EQL supports two kinds of query against encrypted jsonb columns:
+//
+//   - ejson_path: a JSON path selector such as $.user.address.city, used with
+//     cs_ste_vec_value_v1 and cs_ste_vec_terms_v1 to extract encrypted values.
+//   - ste_vec: a containment document such as {"user":{"active":true}}, used
+//     with the @> operator on cs_ste_vec_v1.
+//
+// EQL v2 names these functions differently, and the SQL helpers render the
+// names of the version set with UseEQLVersion.
+//
+// JSONPath builds and validates selectors locally so that malformed paths are
+// rejected before a payload is sent to CipherStash Proxy.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathSegmentKind int
+
+const (
+	fieldSegment pathSegmentKind = iota
+	indexSegment
+	wildcardSegment
+)
+
+type pathSegment struct {
+	kind  pathSegmentKind
+	field string
+	index int
+}
+
+// JSONPath is a JSON path selector rooted at $
+//
+// JSONPath values are immutable, each builder method returns a new path.
+type JSONPath struct {
+	segments []pathSegment
+	err      error
+}
+
+// RootPath returns the root JSON path, $
+func RootPath() JSONPath {
+	return JSONPath{}
+}
+
+// ParseJSONPath parses and validates a JSON path selector such as $.user.address.city,
+// $.items[0].name, $.items[*] or $["field with spaces"]
+func ParseJSONPath(path string) (JSONPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return JSONPath{}, fmt.Errorf("invalid json path %q: must start with '$'", path)
+	}
+
+	p := JSONPath{}
+	i := 1
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+			if i < len(path) && path[i] == '*' {
+				p = p.Wildcard()
+				i++
+				continue
+			}
+			start := i
+			for i < len(path) && isPathIdentChar(path[i]) {
+				i++
+			}
+			if start == i {
+				return JSONPath{}, fmt.Errorf("invalid json path %q: expected field name at offset %d", path, start)
+			}
+			p = p.Field(path[start:i])
+		case '[':
+			seg, next, err := parseBracketSegment(path, i)
+			if err != nil {
+				return JSONPath{}, err
+			}
+			p = p.with(seg)
+			i = next
+		default:
+			return JSONPath{}, fmt.Errorf("invalid json path %q: unexpected character %q at offset %d", path, path[i], i)
+		}
+	}
+
+	return p, nil
+}
+
+// parseBracketSegment parses a [n], [*] or ["name"] segment starting at the '[' at offset start
+func parseBracketSegment(path string, start int) (pathSegment, int, error) {
+	i := start + 1
+	if i >= len(path) {
+		return pathSegment{}, 0, fmt.Errorf("invalid json path %q: unterminated '[' at offset %d", path, start)
+	}
+
+	var seg pathSegment
+	switch c := path[i]; {
+	case c == '*':
+		seg = pathSegment{kind: wildcardSegment}
+		i++
+	case c >= '0' && c <= '9':
+		digits := i
+		for i < len(path) && path[i] >= '0' && path[i] <= '9' {
+			i++
+		}
+		n, err := strconv.Atoi(path[digits:i])
+		if err != nil {
+			return pathSegment{}, 0, fmt.Errorf("invalid json path %q: invalid array index at offset %d: %v", path, digits, err)
+		}
+		seg = pathSegment{kind: indexSegment, index: n}
+	case c == '"' || c == '\'':
+		quote := c
+		i++
+		var name strings.Builder
+		for {
+			if i >= len(path) {
+				return pathSegment{}, 0, fmt.Errorf("invalid json path %q: unterminated quoted field at offset %d", path, start)
+			}
+			if path[i] == '\\' && i+1 < len(path) {
+				name.WriteByte(path[i+1])
+				i += 2
+				continue
+			}
+			if path[i] == quote {
+				i++
+				break
+			}
+			name.WriteByte(path[i])
+			i++
+		}
+		if name.Len() == 0 {
+			return pathSegment{}, 0, fmt.Errorf("invalid json path %q: empty field name at offset %d", path, start)
+		}
+		seg = pathSegment{kind: fieldSegment, field: name.String()}
+	default:
+		return pathSegment{}, 0, fmt.Errorf("invalid json path %q: unexpected character %q at offset %d", path, c, i)
+	}
+
+	if i >= len(path) || path[i] != ']' {
+		return pathSegment{}, 0, fmt.Errorf("invalid json path %q: expected ']' at offset %d", path, i)
+	}
+
+	return seg, i + 1, nil
+}
+
+func isPathIdentChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p JSONPath) with(seg pathSegment) JSONPath {
+	segments := make([]pathSegment, len(p.segments), len(p.segments)+1)
+	copy(segments, p.segments)
+	return JSONPath{segments: append(segments, seg), err: p.err}
+}
+
+// Field returns a new path selecting the named field of the current object
+func (p JSONPath) Field(name string) JSONPath {
+	next := p.with(pathSegment{kind: fieldSegment, field: name})
+	if name == "" && next.err == nil {
+		next.err = fmt.Errorf("invalid json path: empty field name after %s", p)
+	}
+	return next
+}
+
+// Index returns a new path selecting element i of the current array
+func (p JSONPath) Index(i int) JSONPath {
+	next := p.with(pathSegment{kind: indexSegment, index: i})
+	if i < 0 && next.err == nil {
+		next.err = fmt.Errorf("invalid json path: negative array index %d after %s", i, p)
+	}
+	return next
+}
+
+// Wildcard returns a new path selecting every element of the current array or object
+func (p JSONPath) Wildcard() JSONPath {
+	return p.with(pathSegment{kind: wildcardSegment})
+}
+
+// Validate returns the first error recorded while building the path
+func (p JSONPath) Validate() error {
+	return p.err
+}
+
+// String renders the path in JSON path syntax, e.g. $.user.addresses[0].city
+func (p JSONPath) String() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, seg := range p.segments {
+		switch seg.kind {
+		case fieldSegment:
+			if isPathIdent(seg.field) {
+				b.WriteByte('.')
+				b.WriteString(seg.field)
+			} else {
+				b.WriteString(`["`)
+				b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(seg.field))
+				b.WriteString(`"]`)
+			}
+		case indexSegment:
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(seg.index))
+			b.WriteByte(']')
+		case wildcardSegment:
+			b.WriteString("[*]")
+		}
+	}
+	return b.String()
+}
+
+func isPathIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isPathIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Query serializes the path into an ejson_path query payload
+func (p JSONPath) Query(table string, column string) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return EJsonPathQuery(p.String(), table, column)
+}
+
+// selectValue returns the value at the path in a decoded JSON document, reporting
+// whether it exists. Paths with wildcards select nothing.
+func (p JSONPath) selectValue(doc any) (any, bool) {
+	for _, seg := range p.segments {
+		switch seg.kind {
+		case fieldSegment:
+			obj, ok := doc.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[seg.field]; !ok {
+				return nil, false
+			}
+		case indexSegment:
+			arr, ok := doc.([]any)
+			if !ok || seg.index >= len(arr) {
+				return nil, false
+			}
+			doc = arr[seg.index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// Contains builds a containment document that matches value at the path.
+// Index segments become single element arrays, as jsonb containment ignores array position.
+//
+// For example, RootPath().Field("user").Field("roles").Index(0).Contains("admin")
+// produces {"user":{"roles":["admin"]}}
+func (p JSONPath) Contains(value any) (map[string]any, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	doc := value
+	if ej, ok := doc.(EncryptedJsonb); ok {
+		doc = map[string]any(ej)
+	}
+	for i := len(p.segments) - 1; i >= 0; i-- {
+		switch seg := p.segments[i]; seg.kind {
+		case fieldSegment:
+			doc = map[string]any{seg.field: doc}
+		case indexSegment:
+			doc = []any{doc}
+		case wildcardSegment:
+			return nil, fmt.Errorf("invalid containment path %s: wildcards are not supported", p)
+		}
+	}
+
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid containment path %s: containment document must be a JSON object, got %T", p, doc)
+	}
+	return root, nil
+}
+
+// ContainsQuery serializes a containment document for value at the path into a ste_vec query payload
+func (p JSONPath) ContainsQuery(value any, table string, column string) ([]byte, error) {
+	doc, err := p.Contains(value)
+	if err != nil {
+		return nil, err
+	}
+	return JsonbQuery(doc, table, column)
+}
+
+// SteVecContainsSQL renders the EQL containment operator for a ste_vec query,
+// e.g. cs_ste_vec_v1(attrs) @> cs_ste_vec_v1($1)
+func SteVecContainsSQL(column string, param string) string {
+	return DefaultEQLVersion().SteVecContainsSQL(column, param)
+}
+
+// SteVecValueSQL renders the EQL function extracting the value at an ejson_path,
+// e.g. cs_ste_vec_value_v1(attrs, $1)
+func SteVecValueSQL(column string, pathParam string) string {
+	return DefaultEQLVersion().SteVecValueSQL(column, pathParam)
+}
+
+// SteVecTermsSQL renders the EQL function extracting the terms of an array at an ejson_path,
+// e.g. cs_ste_vec_terms_v1(attrs, $1)
+func SteVecTermsSQL(column string, pathParam string) string {
+	return DefaultEQLVersion().SteVecTermsSQL(column, pathParam)
+}
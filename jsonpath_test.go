@@ -0,0 +1,208 @@
+package goeql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Test JSONPath builder rendering
+func TestJSONPath_String(t *testing.T) {
+	tests := []struct {
+		path     JSONPath
+		expected string
+	}{
+		{path: RootPath(), expected: "$"},
+		{path: RootPath().Field("user").Field("address").Field("city"), expected: "$.user.address.city"},
+		{path: RootPath().Field("items").Index(0).Field("name"), expected: "$.items[0].name"},
+		{path: RootPath().Field("items").Wildcard(), expected: "$.items[*]"},
+		{path: RootPath().Field("first name"), expected: `$["first name"]`},
+		{path: RootPath().Field(`say "hi"`), expected: `$["say \"hi\""]`},
+	}
+
+	for _, tt := range tests {
+		if err := tt.path.Validate(); err != nil {
+			t.Fatalf("Validate returned error for %s: %v", tt.expected, err)
+		}
+		if tt.path.String() != tt.expected {
+			t.Errorf("Expected path to be '%s', got '%s'", tt.expected, tt.path.String())
+		}
+	}
+}
+
+// Test JSONPath builder errors
+func TestJSONPath_Validate_Error(t *testing.T) {
+	paths := []JSONPath{
+		RootPath().Field(""),
+		RootPath().Field("items").Index(-1),
+		RootPath().Field("").Field("name"),
+	}
+
+	for _, p := range paths {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected error for path %s, but got none", p)
+		}
+	}
+}
+
+// Test ParseJSONPath
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path        string
+		expected    string
+		expectError bool
+	}{
+		{path: "$", expected: "$"},
+		{path: "$.top", expected: "$.top"},
+		{path: "$.user.address.city", expected: "$.user.address.city"},
+		{path: "$.items[12].name", expected: "$.items[12].name"},
+		{path: "$.items[*]", expected: "$.items[*]"},
+		{path: "$.items.*", expected: "$.items[*]"},
+		{path: `$["first name"]`, expected: `$["first name"]`},
+		{path: `$['first name']`, expected: `$["first name"]`},
+		{path: `$["say \"hi\""]`, expected: `$["say \"hi\""]`},
+		{path: "", expectError: true},
+		{path: "top", expectError: true},
+		{path: "$.", expectError: true},
+		{path: "$..top", expectError: true},
+		{path: "$.items[", expectError: true},
+		{path: "$.items[1", expectError: true},
+		{path: "$.items[a]", expectError: true},
+		{path: `$["unterminated]`, expectError: true},
+		{path: `$[""]`, expectError: true},
+		{path: "$ top", expectError: true},
+	}
+
+	for _, tt := range tests {
+		p, err := ParseJSONPath(tt.path)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected error for path: %q, but got none", tt.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for path: %q, error: %v", tt.path, err)
+		} else if p.String() != tt.expected {
+			t.Errorf("Expected '%s', got '%s' for path: %q", tt.expected, p.String(), tt.path)
+		}
+	}
+}
+
+// Test JSONPath ejson_path query serialization
+func TestJSONPath_Query(t *testing.T) {
+	path := RootPath().Field("user").Field("email")
+
+	serializedData, err := path.Query("table1", "column1")
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+
+	var ec EncryptedColumn
+	if err := json.Unmarshal(serializedData, &ec); err != nil {
+		t.Fatalf("Error unmarshaling serialized data: %v", err)
+	}
+
+	if ec.P != "$.user.email" {
+		t.Errorf("Expected P to be '$.user.email', got '%s'", ec.P)
+	}
+	if ec.Q != "ejson_path" {
+		t.Errorf("Expected Q to be 'ejson_path', got '%s'", ec.Q)
+	}
+
+	if _, err := RootPath().Field("").Query("table1", "column1"); err == nil {
+		t.Errorf("Expected error serializing invalid path, but got none")
+	}
+}
+
+// Test EJsonPathQuery rejects invalid JSONPath values and passes strings through unchanged
+func TestEJsonPathQuery_InvalidPath(t *testing.T) {
+	if _, err := EJsonPathQuery(RootPath().Index(-1), "table1", "column1"); err == nil {
+		t.Errorf("Expected error for invalid path, but got none")
+	}
+
+	data, err := EJsonPathQuery("top", "table1", "column1")
+	if err != nil {
+		t.Fatalf("Expected a string path to serialize unchanged, got %v", err)
+	}
+	expected := `{"k":"pt","p":"top","i":{"t":"table1","c":"column1"},"v":1,"q":"ejson_path"}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+// Test JSONPath containment documents
+func TestJSONPath_Contains(t *testing.T) {
+	tests := []struct {
+		path     JSONPath
+		value    any
+		expected map[string]any
+	}{
+		{
+			path:     RootPath().Field("user").Field("active"),
+			value:    true,
+			expected: map[string]any{"user": map[string]any{"active": true}},
+		},
+		{
+			path:     RootPath().Field("user").Field("roles").Index(0),
+			value:    "admin",
+			expected: map[string]any{"user": map[string]any{"roles": []any{"admin"}}},
+		},
+		{
+			path:     RootPath(),
+			value:    map[string]any{"key": "value"},
+			expected: map[string]any{"key": "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		doc, err := tt.path.Contains(tt.value)
+		if err != nil {
+			t.Fatalf("Contains returned error for %s: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(doc, tt.expected) {
+			t.Errorf("Expected containment document %v, got %v", tt.expected, doc)
+		}
+	}
+
+	if _, err := RootPath().Field("items").Wildcard().Contains("x"); err == nil {
+		t.Errorf("Expected error for wildcard containment path, but got none")
+	}
+	if _, err := RootPath().Contains("x"); err == nil {
+		t.Errorf("Expected error for non object containment document, but got none")
+	}
+}
+
+// Test JSONPath ste_vec containment query serialization
+func TestJSONPath_ContainsQuery(t *testing.T) {
+	serializedData, err := RootPath().Field("user").Field("name").ContainsQuery("Alice", "table1", "column1")
+	if err != nil {
+		t.Fatalf("ContainsQuery returned error: %v", err)
+	}
+
+	var ec EncryptedColumn
+	if err := json.Unmarshal(serializedData, &ec); err != nil {
+		t.Fatalf("Error unmarshaling serialized data: %v", err)
+	}
+
+	expectedP := `{"user":{"name":"Alice"}}`
+	if ec.P != expectedP {
+		t.Errorf("Expected P to be '%s', got '%s'", expectedP, ec.P)
+	}
+	if ec.Q != "ste_vec" {
+		t.Errorf("Expected Q to be 'ste_vec', got '%s'", ec.Q)
+	}
+}
+
+// Test ste_vec SQL operator rendering
+func TestSteVecSQL(t *testing.T) {
+	if sql := SteVecContainsSQL("attrs", "$1"); sql != "cs_ste_vec_v1(attrs) @> cs_ste_vec_v1($1)" {
+		t.Errorf("Unexpected containment SQL: %s", sql)
+	}
+	if sql := SteVecValueSQL("attrs", "$2"); sql != "cs_ste_vec_value_v1(attrs, $2)" {
+		t.Errorf("Unexpected value SQL: %s", sql)
+	}
+	if sql := SteVecTermsSQL("attrs", "$3"); sql != "cs_ste_vec_terms_v1(attrs, $3)" {
+		t.Errorf("Unexpected terms SQL: %s", sql)
+	}
+}
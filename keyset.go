@@ -0,0 +1,106 @@
+package goeql
+
+// Keyset identifiers for multi-tenant encryption.
+//
+// A Keyset names the keyset a payload is encrypted under, by ID or by name, and
+// is carried in the "ks" field of the payload. WithKeyset stores the current
+// tenant's keyset in a context, and every *Context serializer picks it up, so
+// the keyset does not need to be threaded through each call. A column registered
+// with a Keyset is serialized under it in place of the context's keyset, by the
+// free functions and Column methods alike. Payloads without a keyset omit the
+// field and use the default keyset.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Keyset identifies the keyset a value is encrypted under, by exactly one of ID or Name
+type Keyset struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// KeysetID returns a Keyset identified by id
+func KeysetID(id string) Keyset {
+	return Keyset{ID: id}
+}
+
+// KeysetName returns a Keyset identified by name
+func KeysetName(name string) Keyset {
+	return Keyset{Name: name}
+}
+
+// Validate checks that exactly one of ID or Name is set
+func (k Keyset) Validate() error {
+	if k.ID == "" && k.Name == "" {
+		return errors.New("keyset must have an id or a name")
+	}
+	if k.ID != "" && k.Name != "" {
+		return fmt.Errorf("keyset must have either an id or a name, not both: id %q, name %q", k.ID, k.Name)
+	}
+	return nil
+}
+
+// appendJSON appends the keyset as a JSON object, matching encoding/json
+func (k Keyset) appendJSON(dst []byte) []byte {
+	if k.ID != "" {
+		dst = append(dst, `{"id":`...)
+		dst = appendJSONString(dst, k.ID)
+	} else {
+		dst = append(dst, `{"name":`...)
+		dst = appendJSONString(dst, k.Name)
+	}
+	return append(dst, '}')
+}
+
+type keysetContextKey struct{}
+
+// WithKeyset returns a copy of ctx carrying keyset, which is included in every payload
+// serialized with the context
+func WithKeyset(ctx context.Context, keyset Keyset) context.Context {
+	return context.WithValue(ctx, keysetContextKey{}, &keyset)
+}
+
+// KeysetFromContext returns the keyset carried by ctx, if any
+func KeysetFromContext(ctx context.Context) (Keyset, bool) {
+	keyset, ok := ctx.Value(keysetContextKey{}).(*Keyset)
+	if !ok {
+		return Keyset{}, false
+	}
+	return *keyset, true
+}
+
+// payloadKeyset returns the keyset of payloads serialized for the column with ctx: the
+// column's keyset in the installed registry, else the keyset carried by ctx, else nil
+func payloadKeyset(ctx context.Context, table string, column string) (*Keyset, error) {
+	if keyset := columnKeyset(table, column); keyset != nil {
+		return keyset, nil
+	}
+	return keysetFromContext(ctx)
+}
+
+// columnKeyset returns the keyset of the column in the installed registry, if any.
+// Register validates the keyset and copies it, so it is never modified.
+func columnKeyset(table string, column string) *Keyset {
+	if r := activeRegistry.Load(); r != nil {
+		if c, ok := r.Lookup(table, column); ok {
+			return c.Keyset
+		}
+	}
+	return nil
+}
+
+// keysetFromContext returns the validated keyset carried by ctx, or nil if there is none.
+// The context holds a pointer so payloads can share it without allocating.
+func keysetFromContext(ctx context.Context) (*Keyset, error) {
+	keyset, ok := ctx.Value(keysetContextKey{}).(*Keyset)
+	if !ok {
+		return nil, nil
+	}
+	if err := keyset.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid keyset: %v", err)
+	}
+	return keyset, nil
+}
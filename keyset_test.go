@@ -0,0 +1,270 @@
+package goeql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// Test Keyset validation
+func TestKeyset_Validate(t *testing.T) {
+	tests := []struct {
+		keyset  Keyset
+		wantErr bool
+	}{
+		{KeysetID("2d7c4a8e-0f5b-4c57-9a41-6d1b5e3f9c20"), false},
+		{KeysetName("tenant-acme"), false},
+		{Keyset{}, true},
+		{Keyset{ID: "id", Name: "name"}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.keyset.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) returned error %v, wantErr %v", tt.keyset, err, tt.wantErr)
+		}
+	}
+}
+
+// Test the keyset round trips through a context
+func TestKeysetFromContext(t *testing.T) {
+	if _, ok := KeysetFromContext(context.Background()); ok {
+		t.Errorf("Expected no keyset in background context")
+	}
+
+	ctx := WithKeyset(context.Background(), KeysetName("tenant-acme"))
+	keyset, ok := KeysetFromContext(ctx)
+	if !ok || keyset != KeysetName("tenant-acme") {
+		t.Errorf("Expected keyset tenant-acme, got %+v, %v", keyset, ok)
+	}
+}
+
+// Test Serialize includes the keyset from the context
+func TestSerializeContext_Keyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), KeysetID("2d7c4a8e"))
+
+	serialized, err := EncryptedText("alice@example.com").SerializeContext(ctx, "users", "email")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+	expected := `{"k":"pt","p":"alice@example.com","i":{"t":"users","c":"email"},"v":1,"q":null,"ks":{"id":"2d7c4a8e"}}`
+	if string(serialized) != expected {
+		t.Errorf("Expected %s, got %s", expected, serialized)
+	}
+
+	var ec EncryptedColumn
+	if err := json.Unmarshal(serialized, &ec); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if ec.KS == nil || *ec.KS != KeysetID("2d7c4a8e") {
+		t.Errorf("Expected keyset 2d7c4a8e, got %+v", ec.KS)
+	}
+
+	// Deserialize ignores the keyset
+	var et EncryptedText
+	text, err := et.Deserialize(serialized)
+	if err != nil || text.Reveal() != "alice@example.com" {
+		t.Errorf("Expected alice@example.com, got %q, %v", text.Reveal(), err)
+	}
+}
+
+// Test payloads without a keyset omit the field
+func TestSerialize_NoKeyset(t *testing.T) {
+	serialized, err := EncryptedInt(42).Serialize("users", "age")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	if bytes.Contains(serialized, []byte(`"ks"`)) {
+		t.Errorf("Expected no keyset, got %s", serialized)
+	}
+}
+
+// Test every query function includes the keyset and matches json.Marshal
+func TestQueryContext_Keyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), KeysetName(`tenant "<acme>"`))
+
+	tests := []struct {
+		name      string
+		fn        func(context.Context, any, string, string) ([]byte, error)
+		value     any
+		queryType string
+	}{
+		{"MatchQueryContext", MatchQueryContext, "alice", "match"},
+		{"OreQueryContext", OreQueryContext, 42, "ore"},
+		{"UniqueQueryContext", UniqueQueryContext, true, "unique"},
+		{"JsonbQueryContext", JsonbQueryContext, map[string]any{"top": "level"}, "ste_vec"},
+		{"EJsonPathQueryContext", EJsonPathQueryContext, "$.top", "ejson_path"},
+	}
+
+	for _, tt := range tests {
+		serialized, err := tt.fn(ctx, tt.value, "users", "data")
+		if err != nil {
+			t.Fatalf("%s returned error: %v", tt.name, err)
+		}
+
+		ec, err := ToEncryptedColumnContext(ctx, tt.value, "users", "data", tt.queryType)
+		if err != nil {
+			t.Fatalf("ToEncryptedColumnContext returned error: %v", err)
+		}
+		expected, err := json.Marshal(ec)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+		if !bytes.Equal(serialized, expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, expected, serialized)
+		}
+	}
+}
+
+// Test an invalid keyset in the context is an error
+func TestSerializeContext_InvalidKeyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), Keyset{})
+
+	if _, err := EncryptedText("alice").SerializeContext(ctx, "users", "email"); err == nil {
+		t.Errorf("Expected error for invalid keyset")
+	}
+	if _, err := MatchQueryContext(ctx, "alice", "users", "email"); err == nil {
+		t.Errorf("Expected error for invalid keyset")
+	}
+}
+
+// Test batch and secret encoders include the keyset
+func TestEncoders_Keyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), KeysetName("tenant-acme"))
+
+	expected, err := EncryptedText("alice").SerializeContext(ctx, "users", "email")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+
+	payloads, err := EncodeBatchContext(ctx, "users", "email", []string{"alice"})
+	if err != nil {
+		t.Fatalf("EncodeBatchContext returned error: %v", err)
+	}
+	if !bytes.Equal(payloads[0], expected) {
+		t.Errorf("Expected %s, got %s", expected, payloads[0])
+	}
+
+	secret, err := NewSecretText([]byte("alice")).SerializeContext(ctx, "users", "email")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+	if !bytes.Equal(secret, expected) {
+		t.Errorf("Expected %s, got %s", expected, secret)
+	}
+
+	appended, err := AppendEncryptedContext(ctx, nil, "alice", "users", "email", "")
+	if err != nil {
+		t.Fatalf("AppendEncryptedContext returned error: %v", err)
+	}
+	if !bytes.Equal(appended, expected) {
+		t.Errorf("Expected %s, got %s", expected, appended)
+	}
+
+	rows := [][]any{{1, "alice"}}
+	encrypted := map[int]TableColumn{1: {T: "users", C: "email"}}
+	source, err := NewCopySourceContext(ctx, rows, encrypted)
+	if err != nil {
+		t.Fatalf("NewCopySourceContext returned error: %v", err)
+	}
+	if !source.Next() {
+		t.Fatalf("Expected a row, got error %v", source.Err())
+	}
+	if values, _ := source.Values(); values[1] != string(expected) {
+		t.Errorf("Expected %s, got %v", expected, values[1])
+	}
+
+	_, args, err := ValuesStatementContext(ctx, "users", []string{"id", "email"}, rows, encrypted)
+	if err != nil {
+		t.Fatalf("ValuesStatementContext returned error: %v", err)
+	}
+	if args[1] != string(expected) {
+		t.Errorf("Expected %s, got %v", expected, args[1])
+	}
+
+	invalid := WithKeyset(context.Background(), Keyset{})
+	if _, err := NewCopySourceContext(invalid, rows, encrypted); err == nil {
+		t.Errorf("Expected error for invalid keyset")
+	}
+	if dst, err := AppendEncryptedContext(invalid, []byte("prefix:"), "alice", "users", "email", ""); err == nil || string(dst) != "prefix:" {
+		t.Errorf("Expected error and unchanged buffer for invalid keyset, got %s, %v", dst, err)
+	}
+}
+
+// Test the column keyset is not shared with the context
+func TestToEncryptedColumnContext_CopiesKeyset(t *testing.T) {
+	ctx := WithKeyset(context.Background(), KeysetName("tenant-acme"))
+
+	ec, err := ToEncryptedColumnContext(ctx, "alice", "users", "email", nil)
+	if err != nil {
+		t.Fatalf("ToEncryptedColumnContext returned error: %v", err)
+	}
+	ec.KS.Name = "tenant-other"
+
+	if keyset, _ := KeysetFromContext(ctx); keyset.Name != "tenant-acme" {
+		t.Errorf("Expected context keyset tenant-acme, got %+v", keyset)
+	}
+}
+
+// Test the free functions and encoders serialize a registered column under its keyset
+func TestRegistry_Keyset(t *testing.T) {
+	r, err := NewRegistry(
+		Column{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{MatchIndex}, Keyset: &Keyset{Name: "tenant-acme"}},
+		Column{Table: "users", Name: "name", Cast: CastText},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	UseRegistry(r)
+	defer UseRegistry(nil)
+
+	ctx := WithKeyset(context.Background(), KeysetName("tenant-other"))
+	encoders := map[string]func() ([]byte, error){
+		"Serialize":  func() ([]byte, error) { return EncryptedText("alice").Serialize("users", "email") },
+		"MatchQuery": func() ([]byte, error) { return MatchQuery("alice", "users", "email") },
+		"MatchQueryContext": func() ([]byte, error) {
+			return MatchQueryContext(ctx, "alice", "users", "email")
+		},
+		"SecretText": func() ([]byte, error) { return NewSecretText([]byte("alice")).Serialize("users", "email") },
+		"AppendEncrypted": func() ([]byte, error) {
+			return AppendEncrypted(nil, "alice", "users", "email", ""), nil
+		},
+		"EncodeBatch": func() ([]byte, error) {
+			payloads, err := EncodeBatch("users", "email", []string{"alice"})
+			if err != nil {
+				return nil, err
+			}
+			return payloads[0], nil
+		},
+		"CopySource": func() ([]byte, error) {
+			source, err := NewCopySource([][]any{{"alice"}}, map[int]TableColumn{0: {T: "users", C: "email"}})
+			if err != nil || !source.Next() {
+				return nil, err
+			}
+			values, err := source.Values()
+			if err != nil {
+				return nil, err
+			}
+			return []byte(values[0].(string)), nil
+		},
+	}
+	for name, encode := range encoders {
+		data, err := encode()
+		if err != nil {
+			t.Fatalf("%s returned error: %v", name, err)
+		}
+		if !bytes.Contains(data, []byte(`"ks":{"name":"tenant-acme"}`)) {
+			t.Errorf("%s: expected the registered keyset, got %s", name, data)
+		}
+	}
+
+	// Columns registered without a keyset use the context's keyset
+	data, err := EncryptedText("alice").SerializeContext(ctx, "users", "name")
+	if err != nil {
+		t.Fatalf("SerializeContext returned error: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"ks":{"name":"tenant-other"}`)) {
+		t.Errorf("Expected the context keyset, got %s", data)
+	}
+}
@@ -0,0 +1,334 @@
+package goeql
+
+// Migrates an existing plaintext column to an EQL column by reading rows in
+// keyset batches, converting each value with ToEncryptedColumnContext and writing the
+// payload back to the destination column through CipherStash Proxy.
+//
+// Progress is checkpointed after every batch so an interrupted migration can be
+// resumed, and rows that cannot be encoded are reported without stopping the run.
+// The checkpoint never moves past the first row that failed, so resuming or
+// running the migration again retries it, along with every row after it.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultMigrationBatchSize is the batch size used when Migrator.BatchSize is not set
+const DefaultMigrationBatchSize = 1000
+
+// MigrationRow is a source row read by a MigrationStore
+type MigrationRow struct {
+	Key   any
+	Value any
+}
+
+// EncryptedRow is an EQL payload to be written to the destination column
+type EncryptedRow struct {
+	Key     any
+	Payload []byte
+}
+
+// MigrationStore reads source rows and writes encrypted payloads for a Migrator
+type MigrationStore interface {
+	// ReadBatch returns up to limit rows with a primary key greater than after,
+	// ordered by primary key. after is nil for the first batch.
+	ReadBatch(ctx context.Context, after any, limit int) ([]MigrationRow, error)
+	// WriteBatch writes the payloads to the destination column
+	WriteBatch(ctx context.Context, rows []EncryptedRow) error
+}
+
+// Checkpointer persists the last migrated primary key so a migration can be resumed
+type Checkpointer interface {
+	Load(ctx context.Context) (key any, ok bool, err error)
+	Save(ctx context.Context, key any) error
+}
+
+// MigrationProgress reports the progress of a migration
+type MigrationProgress struct {
+	Batches  int
+	Migrated int
+	Skipped  int
+	Failed   int
+	LastKey  any
+}
+
+// RowError is a row that could not be encoded
+type RowError struct {
+	Key any
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %v: %v", e.Key, e.Err)
+}
+
+// MigrationResult is the outcome of a migration run
+type MigrationResult struct {
+	MigrationProgress
+	Failures []RowError
+}
+
+// Migrator encrypts a plaintext column into an EQL column in batches
+type Migrator struct {
+	Store MigrationStore
+	// Table and Column identify the destination EQL column in each payload
+	Table  string
+	Column string
+	// BatchSize defaults to DefaultMigrationBatchSize
+	BatchSize int
+	// Checkpoint is optional, and resumes the migration from the last saved key. It is
+	// not advanced past the first row that fails to encode, so a later run retries it.
+	Checkpoint Checkpointer
+	// Transform is optional, and converts each source value before it is encoded
+	Transform func(value any) (any, error)
+	// Progress is optional, and is called after every batch
+	Progress func(MigrationProgress)
+}
+
+// Run migrates every remaining row, returning the per-row failures in the result.
+// An error is returned if reading, writing or checkpointing a batch fails, in which
+// case the migration can be resumed from the last checkpoint. Rows that fail to
+// encode do not stop the run, but hold the checkpoint before the first of them, so
+// running the migration again once they are fixed retries them.
+func (m *Migrator) Run(ctx context.Context) (MigrationResult, error) {
+	var result MigrationResult
+
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultMigrationBatchSize
+	}
+
+	var after any
+	if m.Checkpoint != nil {
+		key, ok, err := m.Checkpoint.Load(ctx)
+		if err != nil {
+			return result, fmt.Errorf("error loading migration checkpoint: %v", err)
+		}
+		if ok {
+			after = key
+		}
+	}
+	result.LastKey = after
+	// failed holds the checkpoint once a row has failed, so it is not advanced past it
+	failed := false
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		rows, err := m.Store.ReadBatch(ctx, after, batchSize)
+		if err != nil {
+			return result, fmt.Errorf("error reading batch after %v: %v", after, err)
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		var checkpoint any
+		if !failed {
+			checkpoint = rows[len(rows)-1].Key
+		}
+		encrypted := make([]EncryptedRow, 0, len(rows))
+		for i, row := range rows {
+			payload, err := m.encode(ctx, row.Value)
+			switch {
+			case err != nil:
+				if !failed {
+					failed = true
+					if i > 0 {
+						checkpoint = rows[i-1].Key
+					} else {
+						checkpoint = nil
+					}
+				}
+				result.Failed++
+				result.Failures = append(result.Failures, RowError{Key: row.Key, Err: err})
+			case payload == nil:
+				result.Skipped++
+			default:
+				encrypted = append(encrypted, EncryptedRow{Key: row.Key, Payload: payload})
+			}
+		}
+
+		if len(encrypted) > 0 {
+			if err := m.Store.WriteBatch(ctx, encrypted); err != nil {
+				return result, fmt.Errorf("error writing batch after %v: %v", after, err)
+			}
+		}
+
+		after = rows[len(rows)-1].Key
+		if m.Checkpoint != nil && checkpoint != nil {
+			if err := m.Checkpoint.Save(ctx, checkpoint); err != nil {
+				return result, fmt.Errorf("error saving migration checkpoint: %v", err)
+			}
+		}
+
+		result.Batches++
+		result.Migrated += len(encrypted)
+		result.LastKey = after
+		if m.Progress != nil {
+			m.Progress(result.MigrationProgress)
+		}
+
+		if len(rows) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+// encode converts a source value to an EQL payload, returning nil for NULL values
+func (m *Migrator) encode(ctx context.Context, value any) ([]byte, error) {
+	if m.Transform != nil {
+		transformed, err := m.Transform(value)
+		if err != nil {
+			return nil, err
+		}
+		value = transformed
+	}
+	if value == nil {
+		return nil, nil
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+
+	return encryptValue(ctx, value, m.Table, m.Column)
+}
+
+// SQLDB is the subset of *sql.DB used by SQLMigrationStore
+type SQLDB interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// SQLMigrationStore is a MigrationStore for a PostgreSQL table, connected through CipherStash Proxy
+type SQLMigrationStore struct {
+	DB          SQLDB
+	Table       string
+	PrimaryKey  string
+	Source      string
+	Destination string
+}
+
+// NewSQLMigrator returns a Migrator that encrypts the source column of table into the destination EQL column
+func NewSQLMigrator(db SQLDB, table string, primaryKey string, source string, destination string) *Migrator {
+	return &Migrator{
+		Store:  &SQLMigrationStore{DB: db, Table: table, PrimaryKey: primaryKey, Source: source, Destination: destination},
+		Table:  table,
+		Column: destination,
+	}
+}
+
+// ReadBatch reads the next batch of source values ordered by primary key
+func (s *SQLMigrationStore) ReadBatch(ctx context.Context, after any, limit int) ([]MigrationRow, error) {
+	pk := quoteIdent(s.PrimaryKey)
+	query := fmt.Sprintf("SELECT %s, %s FROM %s", pk, quoteIdent(s.Source), quoteIdent(s.Table))
+	args := []any{limit}
+	if after != nil {
+		query += fmt.Sprintf(" WHERE %s > $2", pk)
+		args = append(args, after)
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $1", pk)
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var batch []MigrationRow
+	for rows.Next() {
+		var row MigrationRow
+		if err := rows.Scan(&row.Key, &row.Value); err != nil {
+			return nil, err
+		}
+		if b, ok := row.Key.([]byte); ok {
+			row.Key = string(b)
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// WriteBatch writes the payloads to the destination column in a single transaction
+func (s *SQLMigrationStore) WriteBatch(ctx context.Context, rows []EncryptedRow) (err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	query := fmt.Sprintf("UPDATE %s SET %s = $1::jsonb WHERE %s = $2", quoteIdent(s.Table), quoteIdent(s.Destination), quoteIdent(s.PrimaryKey))
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, string(row.Payload), row.Key); err != nil {
+			return fmt.Errorf("error updating row %v: %v", row.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// quoteIdent quotes s as a SQL identifier
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// FileCheckpoint is a Checkpointer that stores the last migrated key as JSON in a file
+type FileCheckpoint struct {
+	Path string
+}
+
+// Load reads the checkpoint, returning ok false if the file does not exist
+func (f FileCheckpoint) Load(ctx context.Context) (any, bool, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var key any
+	if err := decoder.Decode(&key); err != nil {
+		return nil, false, fmt.Errorf("error unmarshaling checkpoint: %v", err)
+	}
+	// Integer keys are restored as int64 so they compare correctly against the primary key
+	if n, ok := key.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true, nil
+		}
+		return n.String(), true, nil
+	}
+	return key, true, nil
+}
+
+// Save writes the checkpoint, replacing the file atomically
+func (f FileCheckpoint) Save(ctx context.Context, key any) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("error marshaling checkpoint: %v", err)
+	}
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.Path)
+}
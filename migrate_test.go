@@ -0,0 +1,243 @@
+package goeql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// memoryStore is an in-memory MigrationStore keyed by int primary keys
+type memoryStore struct {
+	source      map[int]any
+	destination map[int][]byte
+}
+
+func newMemoryStore(n int) *memoryStore {
+	s := &memoryStore{source: map[int]any{}, destination: map[int][]byte{}}
+	for i := 1; i <= n; i++ {
+		s.source[i] = i * 10
+	}
+	return s
+}
+
+func (s *memoryStore) ReadBatch(ctx context.Context, after any, limit int) ([]MigrationRow, error) {
+	var keys []int
+	for k := range s.source {
+		if after == nil || int64(k) > toInt64(after) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	rows := make([]MigrationRow, len(keys))
+	for i, k := range keys {
+		rows[i] = MigrationRow{Key: k, Value: s.source[k]}
+	}
+	return rows, nil
+}
+
+func (s *memoryStore) WriteBatch(ctx context.Context, rows []EncryptedRow) error {
+	for _, row := range rows {
+		s.destination[row.Key.(int)] = row.Payload
+	}
+	return nil
+}
+
+func toInt64(key any) int64 {
+	switch k := key.(type) {
+	case int:
+		return int64(k)
+	case int64:
+		return k
+	default:
+		return 0
+	}
+}
+
+// memoryCheckpoint is an in-memory Checkpointer
+type memoryCheckpoint struct {
+	key any
+}
+
+func (c *memoryCheckpoint) Load(ctx context.Context) (any, bool, error) {
+	return c.key, c.key != nil, nil
+}
+
+func (c *memoryCheckpoint) Save(ctx context.Context, key any) error {
+	c.key = key
+	return nil
+}
+
+// Test Migrator encrypts every row in batches
+func TestMigrator_Run(t *testing.T) {
+	store := newMemoryStore(25)
+	store.source[7] = nil
+	store.source[9] = struct{}{}
+
+	var progress []MigrationProgress
+	m := &Migrator{
+		Store:     store,
+		Table:     "users",
+		Column:    "age_encrypted",
+		BatchSize: 10,
+		Progress:  func(p MigrationProgress) { progress = append(progress, p) },
+	}
+
+	result, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if result.Batches != 3 || result.Migrated != 23 || result.Skipped != 1 || result.Failed != 1 {
+		t.Errorf("Unexpected migration result: %+v", result.MigrationProgress)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Key != 9 {
+		t.Errorf("Expected row 9 to fail, got %+v", result.Failures)
+	}
+	if len(progress) != 3 || progress[2].LastKey != 25 {
+		t.Errorf("Unexpected progress reports: %+v", progress)
+	}
+
+	var ec EncryptedColumn
+	if err := json.Unmarshal(store.destination[3], &ec); err != nil {
+		t.Fatalf("Error unmarshaling migrated payload: %v", err)
+	}
+	if ec.P != "30" || ec.I.T != "users" || ec.I.C != "age_encrypted" {
+		t.Errorf("Unexpected migrated payload: %+v", ec)
+	}
+}
+
+// Test Migrator resumes from its checkpoint after a failed batch
+func TestMigrator_Resume(t *testing.T) {
+	store := newMemoryStore(25)
+	checkpoint := &memoryCheckpoint{}
+	m := &Migrator{Store: store, Table: "users", Column: "age_encrypted", BatchSize: 10, Checkpoint: checkpoint}
+
+	// The first batch succeeds, the second fails to write
+	m.Store = &failAfterStore{MigrationStore: store, writes: 1}
+
+	if _, err := m.Run(context.Background()); err == nil {
+		t.Fatalf("Expected error writing second batch, but got none")
+	}
+	if checkpoint.key != 10 {
+		t.Fatalf("Expected checkpoint at key 10, got %v", checkpoint.key)
+	}
+
+	m.Store = store
+	result, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if result.Migrated != 15 || len(store.destination) != 25 {
+		t.Errorf("Expected remaining 15 rows to be migrated, got %+v with %d rows written", result.MigrationProgress, len(store.destination))
+	}
+}
+
+// Test Migrator holds the checkpoint before the first row that fails, so running it again retries the row
+func TestMigrator_RetryFailures(t *testing.T) {
+	store := newMemoryStore(25)
+	store.source[13] = struct{}{}
+	store.source[17] = struct{}{}
+	checkpoint := &memoryCheckpoint{}
+	m := &Migrator{Store: store, Table: "users", Column: "age_encrypted", BatchSize: 10, Checkpoint: checkpoint}
+
+	result, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if result.Failed != 2 || result.LastKey != 25 {
+		t.Errorf("Unexpected migration result: %+v", result.MigrationProgress)
+	}
+	if checkpoint.key != 12 {
+		t.Fatalf("Expected checkpoint before the first failed row at key 12, got %v", checkpoint.key)
+	}
+
+	store.source[13] = 13
+	store.source[17] = 17
+	result, err = m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if result.Failed != 0 || result.Migrated != 13 || checkpoint.key != 25 {
+		t.Errorf("Expected rows after the checkpoint to be migrated again, got %+v with checkpoint %v", result.MigrationProgress, checkpoint.key)
+	}
+	if _, ok := store.destination[13]; !ok {
+		t.Errorf("Expected the failed row to be retried")
+	}
+}
+
+// failAfterStore fails every write after the first n
+type failAfterStore struct {
+	MigrationStore
+	writes int
+}
+
+func (s *failAfterStore) WriteBatch(ctx context.Context, rows []EncryptedRow) error {
+	if s.writes == 0 {
+		return errors.New("connection reset")
+	}
+	s.writes--
+	return s.MigrationStore.WriteBatch(ctx, rows)
+}
+
+// Test Migrator Transform
+func TestMigrator_Transform(t *testing.T) {
+	store := newMemoryStore(2)
+	m := &Migrator{
+		Store:  store,
+		Table:  "users",
+		Column: "age_encrypted",
+		Transform: func(value any) (any, error) {
+			return value.(int) + 1, nil
+		},
+	}
+
+	if _, err := m.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	var ec EncryptedColumn
+	if err := json.Unmarshal(store.destination[1], &ec); err != nil {
+		t.Fatalf("Error unmarshaling migrated payload: %v", err)
+	}
+	if ec.P != "11" {
+		t.Errorf("Expected transformed P to be '11', got '%s'", ec.P)
+	}
+}
+
+// Test FileCheckpoint
+func TestFileCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	checkpoint := FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+
+	if _, ok, err := checkpoint.Load(ctx); err != nil || ok {
+		t.Fatalf("Expected no checkpoint, got ok %v error %v", ok, err)
+	}
+
+	tests := []struct {
+		key      any
+		expected any
+	}{
+		{key: 42, expected: int64(42)},
+		{key: int64(9223372036854775807), expected: int64(9223372036854775807)},
+		{key: "b6c1a2e4", expected: "b6c1a2e4"},
+	}
+
+	for _, tt := range tests {
+		if err := checkpoint.Save(ctx, tt.key); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+		key, ok, err := checkpoint.Load(ctx)
+		if err != nil || !ok {
+			t.Fatalf("Load returned ok %v error %v", ok, err)
+		}
+		if key != tt.expected {
+			t.Errorf("Expected checkpoint %v (%T), got %v (%T)", tt.expected, tt.expected, key, key)
+		}
+	}
+}
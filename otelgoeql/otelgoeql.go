@@ -0,0 +1,87 @@
+// Package otelgoeql traces goeql serialize and deserialize calls with OpenTelemetry.
+//
+// Install the hook with goeql.UseHooks:
+//
+//	goeql.UseHooks(otelgoeql.NewHook())
+//
+// Each call is recorded as a span carrying the column identity, query type and
+// payload size. Plaintext values are never recorded. Error messages can quote
+// the plaintext, so failed calls record the class of the error instead.
+package otelgoeql
+
+import (
+	"context"
+
+	"github.com/cipherstash/goeql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer
+const ScopeName = "github.com/cipherstash/goeql/otelgoeql"
+
+// Span attribute keys
+const (
+	OperationKey = attribute.Key("goeql.operation")
+	TableKey     = attribute.Key("goeql.table")
+	ColumnKey    = attribute.Key("goeql.column")
+	QueryTypeKey = attribute.Key("goeql.query_type")
+	SizeKey      = attribute.Key("goeql.payload_size")
+	ErrorTypeKey = attribute.Key("error.type")
+)
+
+// Hook is a goeql.Hook that records a span for each call
+type Hook struct {
+	tracer trace.Tracer
+}
+
+// Option configures a Hook
+type Option func(*config)
+
+type config struct {
+	provider trace.TracerProvider
+}
+
+// WithTracerProvider sets the tracer provider, which defaults to the global provider
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// NewHook returns a Hook recording spans with the configured tracer provider
+func NewHook(opts ...Option) *Hook {
+	c := config{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Hook{tracer: c.provider.Tracer(ScopeName)}
+}
+
+// Before starts a span named after the operation, e.g. goeql.encrypt
+func (h *Hook) Before(ctx context.Context, event goeql.Event) context.Context {
+	attrs := []attribute.KeyValue{OperationKey.String(string(event.Operation))}
+	if event.QueryType != "" {
+		attrs = append(attrs, QueryTypeKey.String(event.QueryType))
+	}
+	ctx, _ = h.tracer.Start(ctx, "goeql."+string(event.Operation), trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+// After records the column identity, payload size and error, and ends the span
+func (h *Hook) After(ctx context.Context, event goeql.Event) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		TableKey.String(event.Table),
+		ColumnKey.String(event.Column),
+		SizeKey.Int(event.Size),
+	)
+	if event.Err != nil {
+		errType := goeql.ErrorType(event.Err)
+		span.SetAttributes(ErrorTypeKey.String(errType))
+		span.SetStatus(codes.Error, "goeql."+string(event.Operation)+": "+errType)
+	}
+	span.End()
+}
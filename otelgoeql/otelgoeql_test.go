@@ -0,0 +1,102 @@
+package otelgoeql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cipherstash/goeql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// Test spans are recorded for serialize, query and deserialize calls
+func TestHook_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	goeql.UseHooks(NewHook(WithTracerProvider(provider)))
+	defer goeql.UseHooks()
+
+	data, err := goeql.EncryptedText("alice@example.com").Serialize("users", "email")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	if _, err := goeql.MatchQuery("alice", "users", "email"); err != nil {
+		t.Fatalf("MatchQuery returned error: %v", err)
+	}
+	var et goeql.EncryptedText
+	if _, err := et.Deserialize(data); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	names := []string{"goeql.encrypt", "goeql.query", "goeql.decrypt"}
+	if len(spans) != len(names) {
+		t.Fatalf("Expected %d spans, got %d", len(names), len(spans))
+	}
+	for i, span := range spans {
+		if span.Name() != names[i] {
+			t.Errorf("Expected span %s, got %s", names[i], span.Name())
+		}
+		attrs := attributes(span)
+		if attrs[TableKey].AsString() != "users" || attrs[ColumnKey].AsString() != "email" {
+			t.Errorf("Expected users.email attributes, got %v", span.Attributes())
+		}
+		for _, kv := range span.Attributes() {
+			if kv.Value.Emit() == "alice@example.com" || kv.Value.Emit() == "alice" {
+				t.Errorf("Expected no plaintext attributes, got %v", kv)
+			}
+		}
+	}
+
+	if got := attributes(spans[1])[QueryTypeKey].AsString(); got != "match" {
+		t.Errorf("Expected query type match, got %q", got)
+	}
+	if got := attributes(spans[0])[SizeKey].AsInt64(); got != int64(len(data)) {
+		t.Errorf("Expected payload size %d, got %d", len(data), got)
+	}
+}
+
+// Test errors are recorded on the span
+func TestHook_Error(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	goeql.UseHooks(NewHook(WithTracerProvider(provider)))
+	defer goeql.UseHooks()
+
+	var eb goeql.EncryptedBool
+	if _, err := eb.Deserialize([]byte(`{"k":"pt","p":"maybe","i":{"t":"users","c":"active"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected error for invalid boolean")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", spans[0].Status())
+	}
+	if got := spans[0].Status().Description; got != "goeql.decrypt: *errors.errorString" {
+		t.Errorf("Expected the operation and error type in the status, got %q", got)
+	}
+	if got := attributes(spans[0])[ErrorTypeKey].AsString(); got != "*errors.errorString" {
+		t.Errorf("Expected the error type attribute, got %q", got)
+	}
+	if len(spans[0].Events()) != 0 {
+		t.Errorf("Expected the error message not to be recorded, got %v", spans[0].Events())
+	}
+	for _, kv := range spans[0].Attributes() {
+		if strings.Contains(kv.Value.Emit(), "maybe") {
+			t.Errorf("Expected no plaintext attributes, got %v", kv)
+		}
+	}
+}
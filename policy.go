@@ -0,0 +1,280 @@
+package goeql
+
+// Column level access policies.
+//
+// When a policy is installed with UsePolicy, Deserialize, the Decoder and
+// Column.Decode check it before returning plaintext, and the query helpers
+// check it before serializing a query. Each check allows or denies an action
+// on a table and column for the principal carried by the context, or the
+// policy's own principal for calls without a context such as Deserialize and
+// the Decoder. DeserializeContext and Column.DecodeContext carry a principal
+// per request.
+//
+// Deny rules take precedence over allow rules, and actions no rule matches
+// fall back to the policy default, which is to deny. Rules name their table
+// and column, or PolicyWildcard, so that a rule missing either, e.g. from a
+// misspelled key, is rejected rather than matching every column.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyAction is an operation on an encrypted column governed by a policy
+type PolicyAction string
+
+const (
+	// ActionDecode reads the plaintext of a decrypted payload
+	ActionDecode PolicyAction = "decode"
+	// ActionMatch serializes a match query
+	ActionMatch PolicyAction = "match"
+	// ActionOre serializes an ore query
+	ActionOre PolicyAction = "ore"
+	// ActionUnique serializes a unique query
+	ActionUnique PolicyAction = "unique"
+	// ActionSteVec serializes a jsonb or ejson path query
+	ActionSteVec PolicyAction = "ste_vec"
+)
+
+// PolicyEffect is the outcome of a policy check
+type PolicyEffect string
+
+const (
+	Allow PolicyEffect = "allow"
+	Deny  PolicyEffect = "deny"
+)
+
+// PolicyWildcard matches any principal, table or column in a rule
+const PolicyWildcard = "*"
+
+// ErrPermissionDenied is matched by every PermissionError
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionError is returned when the policy denies an action
+type PermissionError struct {
+	Principal string
+	Table     string
+	Column    string
+	Action    PolicyAction
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%v: principal %q may not %s %s.%s", ErrPermissionDenied, e.Principal, e.Action, e.Table, e.Column)
+}
+
+// Unwrap returns ErrPermissionDenied
+func (e *PermissionError) Unwrap() error {
+	return ErrPermissionDenied
+}
+
+// PolicyRule allows or denies actions on a table and column for a set of principals.
+// Table and Column are required, and PolicyWildcard matches any table or column.
+// Principals match any principal when empty.
+type PolicyRule struct {
+	Principals []string       `json:"principals,omitempty" yaml:"principals,omitempty"`
+	Table      string         `json:"table,omitempty" yaml:"table,omitempty"`
+	Column     string         `json:"column,omitempty" yaml:"column,omitempty"`
+	Effect     PolicyEffect   `json:"effect" yaml:"effect"`
+	Actions    []PolicyAction `json:"actions" yaml:"actions"`
+}
+
+// Policy is a set of rules governing access to encrypted columns
+type Policy struct {
+	// Principal is checked for calls whose context does not carry a principal
+	Principal string `json:"principal,omitempty" yaml:"principal,omitempty"`
+	// Default is the effect for actions no rule matches, and defaults to Deny
+	Default PolicyEffect `json:"default,omitempty" yaml:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// Validate checks the policy uses known effects and actions
+func (p *Policy) Validate() error {
+	switch p.Default {
+	case "", Allow, Deny:
+	default:
+		return fmt.Errorf("invalid policy: unknown default effect %q", p.Default)
+	}
+	for i, rule := range p.Rules {
+		switch rule.Effect {
+		case Allow, Deny:
+		default:
+			return fmt.Errorf("invalid policy rule %d: unknown effect %q", i, rule.Effect)
+		}
+		if rule.Table == "" {
+			return fmt.Errorf("invalid policy rule %d: no table, use %q to match every table", i, PolicyWildcard)
+		}
+		if rule.Column == "" {
+			return fmt.Errorf("invalid policy rule %d: no column, use %q to match every column", i, PolicyWildcard)
+		}
+		if len(rule.Actions) == 0 {
+			return fmt.Errorf("invalid policy rule %d: no actions", i)
+		}
+		for _, action := range rule.Actions {
+			switch action {
+			case ActionDecode, ActionMatch, ActionOre, ActionUnique, ActionSteVec, PolicyWildcard:
+			default:
+				return fmt.Errorf("invalid policy rule %d: unknown action %q", i, action)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns a PermissionError unless the policy allows principal to perform action on table.column
+func (p *Policy) Check(principal string, table string, column string, action PolicyAction) error {
+	effect := p.Default
+	if effect == "" {
+		effect = Deny
+	}
+
+	allowed := false
+	for _, rule := range p.Rules {
+		if !rule.matches(principal, table, column, action) {
+			continue
+		}
+		if rule.Effect == Deny {
+			return &PermissionError{Principal: principal, Table: table, Column: column, Action: action}
+		}
+		allowed = true
+	}
+
+	if allowed || effect == Allow {
+		return nil
+	}
+	return &PermissionError{Principal: principal, Table: table, Column: column, Action: action}
+}
+
+func (r PolicyRule) matches(principal string, table string, column string, action PolicyAction) bool {
+	if !matchesPattern(r.Table, table) || !matchesPattern(r.Column, column) {
+		return false
+	}
+	if len(r.Principals) > 0 && !containsPattern(r.Principals, principal) {
+		return false
+	}
+	for _, a := range r.Actions {
+		if a == action || a == PolicyWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPattern(pattern string, value string) bool {
+	return pattern == PolicyWildcard || pattern == value
+}
+
+func containsPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePolicyJSON parses and validates a JSON policy, rejecting unknown keys
+func ParsePolicyJSON(data []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("error unmarshaling policy: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("error unmarshaling policy: unexpected data after the policy")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParsePolicyYAML parses and validates a YAML policy, rejecting unknown keys
+func ParsePolicyYAML(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error unmarshaling policy: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadPolicy reads a policy file, parsing files with a .yaml or .yml extension as YAML and others as JSON
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParsePolicyYAML(data)
+	default:
+		return ParsePolicyJSON(data)
+	}
+}
+
+var activePolicy atomic.Pointer[Policy]
+
+// UsePolicy installs p as the policy checked before decoding payloads and serializing queries.
+// Passing nil removes the policy, allowing every operation.
+func UsePolicy(p *Policy) {
+	activePolicy.Store(p)
+}
+
+// queryActions maps each query type to the action it performs
+var queryActions = map[string]PolicyAction{
+	"match":      ActionMatch,
+	"ore":        ActionOre,
+	"unique":     ActionUnique,
+	"ste_vec":    ActionSteVec,
+	"ejson_path": ActionSteVec,
+}
+
+// checkPolicy checks the action against the installed policy, if any
+func checkPolicy(ctx context.Context, table string, column string, action PolicyAction) error {
+	p := activePolicy.Load()
+	if p == nil {
+		return nil
+	}
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		principal = p.Principal
+	}
+	return p.Check(principal, table, column, action)
+}
+
+// checkDecodePolicy checks decoding against the installed policy, without converting
+// the identity to strings when no policy is installed
+func checkDecodePolicy(ctx context.Context, table []byte, column []byte) error {
+	if activePolicy.Load() == nil {
+		return nil
+	}
+	return checkPolicy(ctx, string(table), string(column), ActionDecode)
+}
+
+// checkQueryPolicy checks a query against the installed policy, if any
+func checkQueryPolicy(ctx context.Context, table string, column string, queryType any) error {
+	if activePolicy.Load() == nil {
+		return nil
+	}
+	qt, _ := queryTypeOf(queryType)
+	action, ok := queryActions[qt]
+	if !ok {
+		action = PolicyAction(qt)
+	}
+	return checkPolicy(ctx, table, column, action)
+}
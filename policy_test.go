@@ -0,0 +1,237 @@
+package goeql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func usePolicyFile(t *testing.T, path string) *Policy {
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy returned error: %v", err)
+	}
+	UsePolicy(p)
+	t.Cleanup(func() { UsePolicy(nil) })
+	return p
+}
+
+// Test YAML and JSON policy files load the same policy
+func TestLoadPolicy(t *testing.T) {
+	yamlPolicy, err := LoadPolicy("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("LoadPolicy returned error: %v", err)
+	}
+	jsonPolicy, err := LoadPolicy("testdata/policy.json")
+	if err != nil {
+		t.Fatalf("LoadPolicy returned error: %v", err)
+	}
+	if !reflect.DeepEqual(yamlPolicy, jsonPolicy) {
+		t.Errorf("Expected YAML and JSON policies to match, got %+v and %+v", yamlPolicy, jsonPolicy)
+	}
+	if len(yamlPolicy.Rules) != 3 || yamlPolicy.Principal != "reporting-service" {
+		t.Errorf("Unexpected policy %+v", yamlPolicy)
+	}
+}
+
+// Test invalid policies are rejected
+func TestParsePolicy_Invalid(t *testing.T) {
+	tests := []string{
+		`{"default": "maybe", "rules": []}`,
+		`{"rules": [{"effect": "allow", "actions": ["read"]}]}`,
+		`{"rules": [{"effect": "permit", "actions": ["decode"]}]}`,
+		`{"rules": [{"effect": "allow"}]}`,
+		`{"rules": [{"column": "email", "effect": "allow", "actions": ["decode"]}]}`,
+		`{"rules": [{"table": "users", "effect": "allow", "actions": ["decode"]}]}`,
+		`{"rules": []} {}`,
+		`not json`,
+	}
+	for _, data := range tests {
+		p, err := ParsePolicyJSON([]byte(data))
+		if err == nil {
+			t.Errorf("Expected error parsing %s", data)
+		}
+		if p != nil {
+			t.Errorf("Expected no policy parsing %s, got %+v", data, p)
+		}
+	}
+}
+
+// Test a misspelled key is rejected instead of widening the rule to every column
+func TestParsePolicy_UnknownKey(t *testing.T) {
+	yamlPolicy := `
+default: deny
+rules:
+  - table: users
+    colum: ssn
+    effect: allow
+    actions: [decode]
+`
+	if p, err := ParsePolicyYAML([]byte(yamlPolicy)); err == nil || p != nil {
+		t.Errorf("Expected error for misspelled YAML key, got %+v, %v", p, err)
+	}
+
+	jsonPolicy := `{"rules": [{"table": "users", "colum": "ssn", "effect": "allow", "actions": ["decode"]}]}`
+	if p, err := ParsePolicyJSON([]byte(jsonPolicy)); err == nil || p != nil {
+		t.Errorf("Expected error for misspelled JSON key, got %+v, %v", p, err)
+	}
+
+	// a rule without a column matches no column
+	p := &Policy{Rules: []PolicyRule{{Table: "users", Effect: Allow, Actions: []PolicyAction{ActionDecode}}}}
+	if err := p.Check("anyone", "users", "password_hash", ActionDecode); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected a rule without a column to match nothing, got %v", err)
+	}
+}
+
+// Test policy rule evaluation
+func TestPolicy_Check(t *testing.T) {
+	p, err := LoadPolicy("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("LoadPolicy returned error: %v", err)
+	}
+
+	tests := []struct {
+		principal string
+		column    string
+		action    PolicyAction
+		allowed   bool
+	}{
+		{"reporting-service", "email", ActionMatch, true},
+		{"reporting-service", "email", ActionUnique, true},
+		{"reporting-service", "email", ActionOre, false},
+		{"reporting-service", "email", ActionDecode, false},
+		{"billing-service", "email", ActionDecode, true},
+		{"billing-service", "ssn", ActionOre, true},
+		{"billing-service", "ssn", ActionDecode, false},
+	}
+
+	for _, tt := range tests {
+		err := p.Check(tt.principal, "users", tt.column, tt.action)
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %s to %s users.%s, got %v", tt.principal, tt.action, tt.column, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected %s to be denied %s users.%s, got %v", tt.principal, tt.action, tt.column, err)
+		}
+	}
+
+	if err := (&Policy{Default: Allow}).Check("anyone", "users", "email", ActionDecode); err != nil {
+		t.Errorf("Expected default allow, got %v", err)
+	}
+}
+
+// Test the query helpers and Deserialize consult the installed policy
+func TestUsePolicy(t *testing.T) {
+	data, err := EncryptedText("alice@example.com").Serialize("users", "email")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	usePolicyFile(t, "testdata/policy.yaml")
+
+	// reporting-service, the policy principal, can query but not decode
+	if _, err := MatchQuery("alice", "users", "email"); err != nil {
+		t.Errorf("MatchQuery returned error: %v", err)
+	}
+	_, err = OreQuery("alice", "users", "email")
+	var permErr *PermissionError
+	if !errors.As(err, &permErr) || permErr.Action != ActionOre || permErr.Principal != "reporting-service" {
+		t.Errorf("Expected ore PermissionError for reporting-service, got %v", err)
+	}
+
+	var et EncryptedText
+	if _, err := et.Deserialize(data); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected Deserialize to be denied, got %v", err)
+	}
+	var d Decoder
+	if _, err := d.Text(data); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected Decoder.Text to be denied, got %v", err)
+	}
+	if err := NewSecretText(nil).Deserialize(data); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected SecretText.Deserialize to be denied, got %v", err)
+	}
+
+	// billing-service, from the context, can decode
+	ctx := WithPrincipal(context.Background(), "billing-service")
+	value, err := NewColumn("users", "email").DecodeContext(ctx, data)
+	if err != nil || value.(EncryptedText).Reveal() != "alice@example.com" {
+		t.Errorf("Expected billing-service to decode, got %v", err)
+	}
+	if _, err := NewColumn("users", "email").Decode(data); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected Column.Decode to be denied, got %v", err)
+	}
+}
+
+// Test DeserializeContext checks the policy for the principal in the context
+func TestUsePolicy_DeserializeContext(t *testing.T) {
+	payloads := map[string][]byte{}
+	for name, value := range map[string]interface {
+		Serialize(string, string) ([]byte, error)
+	}{
+		"text":  EncryptedText("alice@example.com"),
+		"jsonb": EncryptedJsonb{"plan": "pro"},
+		"array": EncryptedJsonbArray{"a", "b"},
+		"int":   EncryptedInt(42),
+		"bool":  EncryptedBool(true),
+	} {
+		data, err := value.Serialize("users", "email")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		payloads[name] = data
+	}
+	usePolicyFile(t, "testdata/policy.yaml")
+
+	deserializers := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"EncryptedText", func(ctx context.Context) error {
+			_, err := new(EncryptedText).DeserializeContext(ctx, payloads["text"])
+			return err
+		}},
+		{"EncryptedJsonb", func(ctx context.Context) error {
+			_, err := new(EncryptedJsonb).DeserializeContext(ctx, payloads["jsonb"])
+			return err
+		}},
+		{"EncryptedJsonbArray", func(ctx context.Context) error {
+			_, err := new(EncryptedJsonbArray).DeserializeContext(ctx, payloads["array"])
+			return err
+		}},
+		{"EncryptedInt", func(ctx context.Context) error {
+			_, err := new(EncryptedInt).DeserializeContext(ctx, payloads["int"])
+			return err
+		}},
+		{"EncryptedBool", func(ctx context.Context) error {
+			_, err := new(EncryptedBool).DeserializeContext(ctx, payloads["bool"])
+			return err
+		}},
+		{"SecretText", func(ctx context.Context) error { return new(SecretText).DeserializeContext(ctx, payloads["text"]) }},
+		{"SecretInt", func(ctx context.Context) error { return new(SecretInt).DeserializeContext(ctx, payloads["int"]) }},
+		{"SecretBool", func(ctx context.Context) error { return new(SecretBool).DeserializeContext(ctx, payloads["bool"]) }},
+		{"SecretJsonb", func(ctx context.Context) error { return new(SecretJsonb).DeserializeContext(ctx, payloads["jsonb"]) }},
+	}
+
+	billing := WithPrincipal(context.Background(), "billing-service")
+	for _, tt := range deserializers {
+		if err := tt.fn(billing); err != nil {
+			t.Errorf("Expected billing-service to deserialize %s, got %v", tt.name, err)
+		}
+		if err := tt.fn(context.Background()); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected %s to be denied for the policy principal, got %v", tt.name, err)
+		}
+	}
+}
+
+// Test denied calls are reported to hooks
+func TestUsePolicy_Hooks(t *testing.T) {
+	usePolicyFile(t, "testdata/policy.yaml")
+	h := useRecordingHook(t)
+
+	if _, err := JsonbQuery(map[string]any{"a": 1}, "users", "email"); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Expected JsonbQuery to be denied, got %v", err)
+	}
+	if len(h.events) != 1 || !errors.Is(h.events[0].Err, ErrPermissionDenied) {
+		t.Errorf("Expected a denied query event, got %+v", h.events)
+	}
+}
@@ -0,0 +1,79 @@
+// Package promgoeql counts goeql serialize and deserialize calls with Prometheus metrics.
+//
+// Register the hook and install it with goeql.UseHooks:
+//
+//	hook, err := promgoeql.NewHook(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	goeql.UseHooks(hook)
+//
+// Metrics are labelled by operation, table, column and query type.
+package promgoeql
+
+import (
+	"context"
+
+	"github.com/cipherstash/goeql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var labels = []string{"operation", "table", "column", "query_type"}
+
+// Hook is a goeql.Hook that updates Prometheus metrics for each call
+type Hook struct {
+	calls    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewHook returns a Hook with its metrics registered with reg
+func NewHook(reg prometheus.Registerer) (*Hook, error) {
+	h := &Hook{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "goeql",
+			Name:      "operations_total",
+			Help:      "Number of goeql serialize and deserialize calls.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "goeql",
+			Name:      "operation_errors_total",
+			Help:      "Number of goeql serialize and deserialize calls that returned an error.",
+		}, labels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "goeql",
+			Name:      "payload_bytes_total",
+			Help:      "Total size of the payloads serialized and deserialized by goeql.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "goeql",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of goeql serialize and deserialize calls.",
+			Buckets:   []float64{.000001, .000005, .00001, .00005, .0001, .0005, .001, .005, .01},
+		}, labels),
+	}
+
+	for _, c := range []prometheus.Collector{h.calls, h.errors, h.bytes, h.duration} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Before returns ctx unchanged
+func (h *Hook) Before(ctx context.Context, event goeql.Event) context.Context {
+	return ctx
+}
+
+// After updates the metrics for the call
+func (h *Hook) After(ctx context.Context, event goeql.Event) {
+	values := []string{string(event.Operation), event.Table, event.Column, event.QueryType}
+	h.calls.WithLabelValues(values...).Inc()
+	h.bytes.WithLabelValues(values...).Add(float64(event.Size))
+	h.duration.WithLabelValues(values...).Observe(event.Duration.Seconds())
+	if event.Err != nil {
+		h.errors.WithLabelValues(values...).Inc()
+	}
+}
@@ -0,0 +1,62 @@
+package promgoeql
+
+import (
+	"testing"
+
+	"github.com/cipherstash/goeql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Test metrics are updated for serialize, query and deserialize calls
+func TestHook_Metrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	hook, err := NewHook(reg)
+	if err != nil {
+		t.Fatalf("NewHook returned error: %v", err)
+	}
+	goeql.UseHooks(hook)
+	defer goeql.UseHooks()
+
+	data, err := goeql.EncryptedInt(42).Serialize("users", "age")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+	if _, err := goeql.OreQuery(40, "users", "age"); err != nil {
+		t.Fatalf("OreQuery returned error: %v", err)
+	}
+	if _, err := goeql.OreQuery(50, "users", "age"); err != nil {
+		t.Fatalf("OreQuery returned error: %v", err)
+	}
+	var ei goeql.EncryptedInt
+	if _, err := ei.Deserialize([]byte(`{"k":"pt","p":"x","i":{"t":"users","c":"age"},"v":1,"q":null}`)); err == nil {
+		t.Fatalf("Expected error for invalid number")
+	}
+
+	if got := testutil.ToFloat64(hook.calls.WithLabelValues("encrypt", "users", "age", "")); got != 1 {
+		t.Errorf("Expected 1 encrypt call, got %v", got)
+	}
+	if got := testutil.ToFloat64(hook.calls.WithLabelValues("query", "users", "age", "ore")); got != 2 {
+		t.Errorf("Expected 2 ore queries, got %v", got)
+	}
+	if got := testutil.ToFloat64(hook.errors.WithLabelValues("decrypt", "users", "age", "")); got != 1 {
+		t.Errorf("Expected 1 decrypt error, got %v", got)
+	}
+	if got := testutil.ToFloat64(hook.bytes.WithLabelValues("encrypt", "users", "age", "")); got != float64(len(data)) {
+		t.Errorf("Expected %d encrypted bytes, got %v", len(data), got)
+	}
+	if got := testutil.CollectAndCount(hook.duration); got != 3 {
+		t.Errorf("Expected 3 duration series, got %d", got)
+	}
+}
+
+// Test registering the metrics twice is an error
+func TestNewHook_Duplicate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := NewHook(reg); err != nil {
+		t.Fatalf("NewHook returned error: %v", err)
+	}
+	if _, err := NewHook(reg); err == nil {
+		t.Errorf("Expected error registering metrics twice")
+	}
+}
@@ -0,0 +1,282 @@
+package goeql
+
+// Typed query descriptors for the "q" field of query payloads.
+//
+// The query helpers send the query type as a bare string, e.g. "q":"match".
+// Queries with options are described by a QueryDescriptor, which encodes as an
+// object naming the query type in "t" alongside its options:
+//
+//	{"t":"match","o":{"tokenizer":{"kind":"standard"}}}
+//	{"t":"ste_vec","s":"$.user"}
+//	{"t":"ejson_path","s":"$.user.age","op":">"}
+//
+// A descriptor without options encodes as the bare string, so MatchDescriptor{}
+// produces the same payload as MatchQuery. ParseQueryDescriptor reads both forms.
+//
+// The object form is goeql's own encoding. EQL defines "q" only as the query type
+// string, so CipherStash Proxy may not accept payloads with descriptor options.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// QueryDescriptor describes an EQL query, and is encoded into the "q" field of its payload.
+// Descriptors with options encode as an object that EQL does not define, which
+// CipherStash Proxy may not accept.
+type QueryDescriptor interface {
+	// QueryType returns the EQL query type, e.g. "match"
+	QueryType() string
+	// shortcut reports whether the descriptor has no options and encodes as its query type
+	shortcut() bool
+	validate() error
+}
+
+// MatchDescriptor describes a match query
+type MatchDescriptor struct {
+	// Options tokenize the query instead of the column's match index options
+	Options *MatchOptions `json:"o,omitempty"`
+}
+
+// OreDescriptor describes an ore query
+type OreDescriptor struct{}
+
+// UniqueDescriptor describes a unique query
+type UniqueDescriptor struct{}
+
+// SteVecDescriptor describes a ste_vec containment query
+type SteVecDescriptor struct {
+	// Selector is a JSON path the query document is contained at, e.g. $.user
+	Selector string `json:"s,omitempty"`
+}
+
+// EJsonPathDescriptor describes an ejson_path query. Without an operator the query
+// plaintext is the path to extract. With an operator the query compares the value at
+// Selector with the plaintext, e.g. $.user.age > 30.
+type EJsonPathDescriptor struct {
+	Selector string        `json:"s,omitempty"`
+	Op       QueryOperator `json:"op,omitempty"`
+}
+
+// QueryType returns the EQL query type of each descriptor
+func (MatchDescriptor) QueryType() string     { return "match" }
+func (OreDescriptor) QueryType() string       { return "ore" }
+func (UniqueDescriptor) QueryType() string    { return "unique" }
+func (SteVecDescriptor) QueryType() string    { return "ste_vec" }
+func (EJsonPathDescriptor) QueryType() string { return "ejson_path" }
+
+func (d MatchDescriptor) shortcut() bool     { return d.Options == nil }
+func (OreDescriptor) shortcut() bool         { return true }
+func (UniqueDescriptor) shortcut() bool      { return true }
+func (d SteVecDescriptor) shortcut() bool    { return d.Selector == "" }
+func (d EJsonPathDescriptor) shortcut() bool { return d.Selector == "" && d.Op == "" }
+
+func (d MatchDescriptor) validate() error {
+	if d.Options != nil && d.Options.Tokenizer != nil {
+		switch d.Options.Tokenizer.Kind {
+		case "ngram", "standard":
+		default:
+			return fmt.Errorf("invalid match query: unknown tokenizer %q", d.Options.Tokenizer.Kind)
+		}
+	}
+	return nil
+}
+
+func (OreDescriptor) validate() error    { return nil }
+func (UniqueDescriptor) validate() error { return nil }
+
+func (d SteVecDescriptor) validate() error {
+	if d.Selector == "" {
+		return nil
+	}
+	path, err := ParseJSONPath(d.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid ste_vec query: %v", err)
+	}
+	if _, err := path.Contains(map[string]any{}); err != nil {
+		return fmt.Errorf("invalid ste_vec query: %v", err)
+	}
+	return nil
+}
+
+func (d EJsonPathDescriptor) validate() error {
+	if d.shortcut() {
+		return nil
+	}
+	if d.Selector == "" || d.Op == "" {
+		return fmt.Errorf("invalid ejson_path query: a selector and an operator are required together")
+	}
+	if _, err := ParseJSONPath(d.Selector); err != nil {
+		return fmt.Errorf("invalid ejson_path query: %v", err)
+	}
+	for _, op := range queryOperators["ore"] {
+		if op == d.Op {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid ejson_path query: unsupported operator %s", d.Op)
+}
+
+// MarshalJSON encodes the descriptor as "match", or as an object with its options
+func (d MatchDescriptor) MarshalJSON() ([]byte, error) {
+	type fields MatchDescriptor
+	return marshalDescriptor(d, fields(d))
+}
+
+// MarshalJSON encodes the descriptor as "ore"
+func (d OreDescriptor) MarshalJSON() ([]byte, error) {
+	return marshalDescriptor(d, d)
+}
+
+// MarshalJSON encodes the descriptor as "unique"
+func (d UniqueDescriptor) MarshalJSON() ([]byte, error) {
+	return marshalDescriptor(d, d)
+}
+
+// MarshalJSON encodes the descriptor as "ste_vec", or as an object with its options
+func (d SteVecDescriptor) MarshalJSON() ([]byte, error) {
+	type fields SteVecDescriptor
+	return marshalDescriptor(d, fields(d))
+}
+
+// MarshalJSON encodes the descriptor as "ejson_path", or as an object with its options
+func (d EJsonPathDescriptor) MarshalJSON() ([]byte, error) {
+	type fields EJsonPathDescriptor
+	return marshalDescriptor(d, fields(d))
+}
+
+// marshalDescriptor encodes d as its query type, or as an object adding "t" to the
+// options in fields, a copy of d without its MarshalJSON method
+func marshalDescriptor(d QueryDescriptor, fields any) ([]byte, error) {
+	if d.shortcut() {
+		return json.Marshal(d.QueryType())
+	}
+	options, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	t, err := json.Marshal(d.QueryType())
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(`{"t":`), t...)
+	if len(options) > 2 {
+		out = append(append(out, ','), options[1:]...)
+		return out, nil
+	}
+	return append(out, '}'), nil
+}
+
+// newDescriptor returns the descriptor without options for a query type
+func newDescriptor(queryType string) (QueryDescriptor, bool) {
+	switch queryType {
+	case "match":
+		return MatchDescriptor{}, true
+	case "ore":
+		return OreDescriptor{}, true
+	case "unique":
+		return UniqueDescriptor{}, true
+	case "ste_vec":
+		return SteVecDescriptor{}, true
+	case "ejson_path":
+		return EJsonPathDescriptor{}, true
+	}
+	return nil, false
+}
+
+// ParseQueryDescriptor decodes the "q" field of a payload, either a query type string or a
+// descriptor object. A missing or null field, as in payloads of stored values, decodes to nil.
+func ParseQueryDescriptor(data []byte) (QueryDescriptor, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var queryType string
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &queryType); err != nil {
+			return nil, fmt.Errorf("invalid query descriptor: %v", err)
+		}
+	} else {
+		var header struct {
+			T string `json:"t"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("invalid query descriptor: %v", err)
+		}
+		if header.T == "" {
+			return nil, fmt.Errorf("invalid query descriptor: missing 't' field")
+		}
+		queryType = header.T
+	}
+	d, ok := newDescriptor(queryType)
+	if !ok {
+		return nil, fmt.Errorf("invalid query descriptor: unknown query type %q", queryType)
+	}
+	if data[0] == '"' {
+		return d, nil
+	}
+
+	var err error
+	switch d.(type) {
+	case MatchDescriptor:
+		type fields MatchDescriptor
+		var f fields
+		err = unmarshalDescriptor(data, &f)
+		d = MatchDescriptor(f)
+	case SteVecDescriptor:
+		type fields SteVecDescriptor
+		var f fields
+		err = unmarshalDescriptor(data, &f)
+		d = SteVecDescriptor(f)
+	case EJsonPathDescriptor:
+		type fields EJsonPathDescriptor
+		var f fields
+		err = unmarshalDescriptor(data, &f)
+		d = EJsonPathDescriptor(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func unmarshalDescriptor(data []byte, fields any) error {
+	if err := json.Unmarshal(data, fields); err != nil {
+		return fmt.Errorf("invalid query descriptor: %v", err)
+	}
+	return nil
+}
+
+// queryTypeOf returns the query type of a query type string or QueryDescriptor
+func queryTypeOf(queryType any) (string, bool) {
+	switch q := queryType.(type) {
+	case string:
+		return q, true
+	case QueryDescriptor:
+		return q.QueryType(), true
+	}
+	return "", false
+}
+
+// Query serializes a plaintext value used in the query described by q
+func Query(value any, table string, column string, q QueryDescriptor) ([]byte, error) {
+	return QueryContext(context.Background(), value, table, column, q)
+}
+
+// QueryContext serializes a plaintext value used in the query described by q, using the keyset from ctx.
+// The plaintext of an ejson_path query without an operator is serialized as by EJsonPathQuery.
+func QueryContext(ctx context.Context, value any, table string, column string, q QueryDescriptor) ([]byte, error) {
+	if q == nil {
+		return nil, fmt.Errorf("invalid query descriptor: nil")
+	}
+	if d, ok := q.(EJsonPathDescriptor); ok && d.shortcut() {
+		return EJsonPathQueryContext(ctx, value, table, column)
+	}
+	return serializeQuery(ctx, value, table, column, q)
+}
@@ -0,0 +1,186 @@
+package goeql
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Test descriptors encode as their query type without options and as objects with them
+func TestQueryDescriptor_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		descriptor QueryDescriptor
+		expected   string
+	}{
+		{descriptor: MatchDescriptor{}, expected: `"match"`},
+		{descriptor: OreDescriptor{}, expected: `"ore"`},
+		{descriptor: UniqueDescriptor{}, expected: `"unique"`},
+		{descriptor: SteVecDescriptor{}, expected: `"ste_vec"`},
+		{descriptor: EJsonPathDescriptor{}, expected: `"ejson_path"`},
+		{
+			descriptor: MatchDescriptor{Options: &MatchOptions{Tokenizer: &Tokenizer{Kind: "standard"}}},
+			expected:   `{"t":"match","o":{"tokenizer":{"kind":"standard"}}}`,
+		},
+		{descriptor: SteVecDescriptor{Selector: "$.user"}, expected: `{"t":"ste_vec","s":"$.user"}`},
+		{descriptor: EJsonPathDescriptor{Selector: "$.age", Op: OpGt}, expected: `{"t":"ejson_path","s":"$.age","op":"\u003e"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.descriptor)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+		if string(data) != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, data)
+		}
+
+		parsed, err := ParseQueryDescriptor(data)
+		if err != nil {
+			t.Fatalf("ParseQueryDescriptor returned error for %s: %v", data, err)
+		}
+		if !reflect.DeepEqual(parsed, tt.descriptor) {
+			t.Errorf("Expected %#v, got %#v", tt.descriptor, parsed)
+		}
+	}
+}
+
+// Test ParseQueryDescriptor treats a missing or null q as a stored value and rejects invalid descriptors
+func TestParseQueryDescriptor(t *testing.T) {
+	for _, data := range []string{``, `null`, ` null `} {
+		if q, err := ParseQueryDescriptor([]byte(data)); q != nil || err != nil {
+			t.Errorf("Expected nil for %q, got %#v, %v", data, q, err)
+		}
+	}
+
+	invalid := []string{
+		`"unknown"`,
+		`{"t":"unknown"}`,
+		`{"s":"$.user"}`,
+		`{"t":"match","o":{"tokenizer":{"kind":"bigram"}}}`,
+		`{"t":"ste_vec","s":"$.items[*]"}`,
+		`{"t":"ejson_path","s":"$.age"}`,
+		`{"t":"ejson_path","s":"$.age","op":"@>"}`,
+		`{"t":"ejson_path","s":"age","op":"="}`,
+		`{"t":"match","o":[]}`,
+		`42`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseQueryDescriptor([]byte(data)); err == nil {
+			t.Errorf("Expected error parsing %s", data)
+		}
+	}
+}
+
+// Test Query produces the same payload as the string helpers for descriptors without options
+func TestQuery_Shortcut(t *testing.T) {
+	expected, err := MatchQuery("alice", "users", "email")
+	if err != nil {
+		t.Fatalf("MatchQuery returned error: %v", err)
+	}
+	got, err := Query("alice", "users", "email", MatchDescriptor{})
+	if err != nil || string(got) != string(expected) {
+		t.Errorf("Expected %s, got %s, %v", expected, got, err)
+	}
+
+	if _, err := Query(RootPath().Index(-1), "users", "attrs", EJsonPathDescriptor{}); err == nil {
+		t.Errorf("Expected a JSONPath plaintext of an ejson_path query without an operator to be validated")
+	}
+	if _, err := Query("alice", "users", "email", nil); err == nil {
+		t.Errorf("Expected error for a nil descriptor")
+	}
+}
+
+// Test Query encodes descriptors with options and DecodePayload reads them back
+func TestQuery_Descriptor(t *testing.T) {
+	q := EJsonPathDescriptor{Selector: "$.user.age", Op: OpGte}
+	data, err := Query(30, "users", "attrs", q)
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	expected := `{"k":"pt","p":"30","i":{"t":"users","c":"attrs"},"v":1,"q":{"t":"ejson_path","s":"$.user.age","op":"\u003e="}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	payload, err := DecodePayload(data)
+	if err != nil {
+		t.Fatalf("DecodePayload returned error: %v", err)
+	}
+	if payload.Q != q || payload.P != "30" {
+		t.Errorf("Expected %#v, got %#v", q, payload.Q)
+	}
+
+	if _, err := Query(30, "users", "attrs", EJsonPathDescriptor{Selector: "$.user.age"}); err == nil {
+		t.Errorf("Expected error for a selector without an operator")
+	}
+}
+
+// Test descriptors are checked against the registry and the column's indexes by their query type
+func TestQuery_Registry(t *testing.T) {
+	UseRegistry(testRegistry(t))
+	defer UseRegistry(nil)
+
+	if _, err := Query("alice", "users", "email", SteVecDescriptor{Selector: "$.user"}); !errors.Is(err, ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery, got %v", err)
+	}
+
+	column := Column{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{MatchIndex}}
+	options := &MatchOptions{Tokenizer: &Tokenizer{Kind: "standard"}}
+	if _, err := column.Query("alice", MatchDescriptor{Options: options}); err != nil {
+		t.Errorf("Column.Query returned error: %v", err)
+	}
+	if _, err := column.Query("alice", UniqueDescriptor{}); !errors.Is(err, ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery from Column.Query, got %v", err)
+	}
+}
+
+// Test Evaluate applies the options of query descriptors
+func TestEvaluate_Descriptors(t *testing.T) {
+	attrs := Column{Table: "users", Name: "attrs", Cast: CastJsonb}
+	doc := `{"user":{"age":30,"name":"alice","roles":["admin"]}}`
+
+	tests := []struct {
+		q        QueryDescriptor
+		op       QueryOperator
+		value    any
+		expected bool
+	}{
+		{q: SteVecDescriptor{Selector: "$.user"}, op: OpContains, value: map[string]any{"name": "alice"}, expected: true},
+		{q: SteVecDescriptor{Selector: "$.user"}, op: OpContains, value: map[string]any{"name": "bob"}, expected: false},
+		{q: SteVecDescriptor{Selector: "$.user.roles"}, op: OpContains, value: []any{"admin"}, expected: true},
+		{q: EJsonPathDescriptor{Selector: "$.user.age", Op: OpGt}, op: OpGt, value: 29, expected: true},
+		{q: EJsonPathDescriptor{Selector: "$.user.age", Op: OpGt}, op: OpGt, value: 30, expected: false},
+		{q: EJsonPathDescriptor{Selector: "$.user.name", Op: OpEq}, op: OpEq, value: "alice", expected: true},
+		{q: EJsonPathDescriptor{Selector: "$.user.missing", Op: OpEq}, op: OpEq, value: "alice", expected: false},
+		{q: EJsonPathDescriptor{Selector: "$.user.roles[0]", Op: OpNe}, op: OpNe, value: "admin", expected: false},
+	}
+	for _, tt := range tests {
+		query, err := attrs.Query(tt.value, tt.q)
+		if err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		got, err := attrs.Evaluate(doc, tt.op, query)
+		if err != nil {
+			t.Fatalf("Evaluate returned error for %s: %v", query, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Expected %v for %s, got %v", tt.expected, query, got)
+		}
+	}
+
+	query, _ := attrs.Query(29, EJsonPathDescriptor{Selector: "$.user.age", Op: OpGt})
+	if _, err := attrs.Evaluate(doc, OpLt, query); err == nil {
+		t.Errorf("Expected error evaluating an ejson_path query with another operator")
+	}
+
+	email := Column{Table: "users", Name: "email", Cast: CastText}
+	query, _ = email.Query("Alice Smith", MatchDescriptor{Options: &MatchOptions{Tokenizer: &Tokenizer{Kind: "standard"}}})
+	if ok, err := email.Evaluate("alice smith", OpContains, query); err != nil || ok {
+		t.Errorf("Expected standard tokens without downcasing to miss trigrams, got %v, %v", ok, err)
+	}
+	query, _ = email.Query("smi", MatchDescriptor{Options: &MatchOptions{TokenFilters: []TokenFilter{{Kind: "downcase"}}}})
+	if ok, err := email.Evaluate("alice smith", OpContains, query); err != nil || !ok {
+		t.Errorf("Expected a trigram query to match, got %v, %v", ok, err)
+	}
+}
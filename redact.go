@@ -0,0 +1,170 @@
+package goeql
+
+// Redaction of plaintext values in fmt verbs and log/slog.
+//
+// Every Encrypted* type, and EncryptedColumn whose P field holds plaintext,
+// prints as [REDACTED] for all fmt verbs and logs as [REDACTED] through
+// log/slog. Reveal returns the plaintext for deliberate access.
+//
+// Redaction relies on fmt and slog calling these methods, which they do for
+// Encrypted* values passed directly or held in exported struct fields. fmt
+// does not call methods on unexported struct fields or for the %p verb on
+// non pointer values, and encoding/json based handlers marshal nested structs
+// without consulting LogValue.
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Redacted is printed in place of plaintext values
+const Redacted = "[REDACTED]"
+
+// plaintexter is implemented by the Encrypted* types to expose their plaintext to
+// the encoders, which must not go through the redacting String methods
+type plaintexter interface {
+	plaintext() any
+}
+
+// formatRedacted writes Redacted for every verb, or the GoString form for %#v
+func formatRedacted(f fmt.State, verb rune, goString string) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, goString)
+		return
+	}
+	_, _ = io.WriteString(f, Redacted)
+}
+
+// Reveal returns the plaintext value
+func (et EncryptedText) Reveal() string { return string(et) }
+
+func (et EncryptedText) plaintext() any { return string(et) }
+
+// String redacts the plaintext value
+func (et EncryptedText) String() string { return Redacted }
+
+// GoString redacts the plaintext value
+func (et EncryptedText) GoString() string { return "goeql.EncryptedText(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (et EncryptedText) Format(f fmt.State, verb rune) { formatRedacted(f, verb, et.GoString()) }
+
+// LogValue redacts the plaintext value in log/slog
+func (et EncryptedText) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// Reveal returns the plaintext value
+func (ej EncryptedJsonb) Reveal() map[string]any { return map[string]any(ej) }
+
+func (ej EncryptedJsonb) plaintext() any { return map[string]any(ej) }
+
+// String redacts the plaintext value
+func (ej EncryptedJsonb) String() string { return Redacted }
+
+// GoString redacts the plaintext value
+func (ej EncryptedJsonb) GoString() string { return "goeql.EncryptedJsonb(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (ej EncryptedJsonb) Format(f fmt.State, verb rune) { formatRedacted(f, verb, ej.GoString()) }
+
+// LogValue redacts the plaintext value in log/slog
+func (ej EncryptedJsonb) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// Reveal returns the plaintext value
+func (eja EncryptedJsonbArray) Reveal() []interface{} { return []interface{}(eja) }
+
+func (eja EncryptedJsonbArray) plaintext() any { return []interface{}(eja) }
+
+// String redacts the plaintext value
+func (eja EncryptedJsonbArray) String() string { return Redacted }
+
+// GoString redacts the plaintext value
+func (eja EncryptedJsonbArray) GoString() string {
+	return "goeql.EncryptedJsonbArray(" + Redacted + ")"
+}
+
+// Format redacts the plaintext value for every fmt verb
+func (eja EncryptedJsonbArray) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, eja.GoString())
+}
+
+// LogValue redacts the plaintext value in log/slog
+func (eja EncryptedJsonbArray) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// Reveal returns the plaintext value
+func (ei EncryptedInt) Reveal() int { return int(ei) }
+
+func (ei EncryptedInt) plaintext() any { return int(ei) }
+
+// String redacts the plaintext value
+func (ei EncryptedInt) String() string { return Redacted }
+
+// GoString redacts the plaintext value
+func (ei EncryptedInt) GoString() string { return "goeql.EncryptedInt(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (ei EncryptedInt) Format(f fmt.State, verb rune) { formatRedacted(f, verb, ei.GoString()) }
+
+// LogValue redacts the plaintext value in log/slog
+func (ei EncryptedInt) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// Reveal returns the plaintext value
+func (eb EncryptedBool) Reveal() bool { return bool(eb) }
+
+func (eb EncryptedBool) plaintext() any { return bool(eb) }
+
+// String redacts the plaintext value
+func (eb EncryptedBool) String() string { return Redacted }
+
+// GoString redacts the plaintext value
+func (eb EncryptedBool) GoString() string { return "goeql.EncryptedBool(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (eb EncryptedBool) Format(f fmt.State, verb rune) { formatRedacted(f, verb, eb.GoString()) }
+
+// LogValue redacts the plaintext value in log/slog
+func (eb EncryptedBool) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// Reveal returns the plaintext value held in P
+func (ec EncryptedColumn) Reveal() string { return ec.P }
+
+// String prints the payload with the plaintext value redacted
+func (ec EncryptedColumn) String() string {
+	if ec.KS != nil {
+		return fmt.Sprintf("{K:%s P:%s I:{T:%s C:%s} V:%d Q:%v KS:%+v}", ec.K, Redacted, ec.I.T, ec.I.C, ec.V, ec.Q, *ec.KS)
+	}
+	return fmt.Sprintf("{K:%s P:%s I:{T:%s C:%s} V:%d Q:%v}", ec.K, Redacted, ec.I.T, ec.I.C, ec.V, ec.Q)
+}
+
+// GoString prints the payload with the plaintext value redacted
+func (ec EncryptedColumn) GoString() string {
+	if ec.KS != nil {
+		return fmt.Sprintf("goeql.EncryptedColumn{K:%q, P:%q, I:goeql.TableColumn{T:%q, C:%q}, V:%d, Q:%#v, KS:&%#v}", ec.K, Redacted, ec.I.T, ec.I.C, ec.V, ec.Q, *ec.KS)
+	}
+	return fmt.Sprintf("goeql.EncryptedColumn{K:%q, P:%q, I:goeql.TableColumn{T:%q, C:%q}, V:%d, Q:%#v}", ec.K, Redacted, ec.I.T, ec.I.C, ec.V, ec.Q)
+}
+
+// Format prints the payload with the plaintext value redacted for every fmt verb
+func (ec EncryptedColumn) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, ec.GoString())
+		return
+	}
+	_, _ = io.WriteString(f, ec.String())
+}
+
+// LogValue logs the payload with the plaintext value redacted
+func (ec EncryptedColumn) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("k", ec.K),
+		slog.String("p", Redacted),
+		slog.String("t", ec.I.T),
+		slog.String("c", ec.I.C),
+		slog.Int("v", ec.V),
+		slog.Any("q", ec.Q),
+	}
+	if ec.KS != nil {
+		attrs = append(attrs, slog.String("ks_id", ec.KS.ID), slog.String("ks_name", ec.KS.Name))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,140 @@
+package goeql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// %p is excluded as fmt reports it as a bad verb for non pointer values, printing the
+// value without calling Format
+var redactVerbs = []string{"%v", "%+v", "%#v", "%s", "%q", "%d", "%x", "%X", "%t", "%f", "%e", "%c", "%U", "%10s", "%-10v", "%08.3f", "%T"}
+
+// Test Encrypted* values never print their plaintext with fmt
+func TestRedact_Format(t *testing.T) {
+	values := []struct {
+		value  any
+		secret string
+	}{
+		{value: EncryptedText("s3cr3t-text"), secret: "s3cr3t"},
+		{value: EncryptedJsonb{"card": "s3cr3t-card"}, secret: "s3cr3t"},
+		{value: EncryptedJsonbArray{"s3cr3t-item"}, secret: "s3cr3t"},
+		{value: EncryptedInt(4111111), secret: "4111111"},
+		{value: EncryptedInt(4111111), secret: "3eb7e7"},
+		{value: EncryptedBool(true), secret: "true"},
+		{value: EncryptedColumn{K: "pt", P: "s3cr3t-column", I: TableColumn{T: "users", C: "email"}, V: 1}, secret: "s3cr3t"},
+	}
+
+	for _, tt := range values {
+		for _, verb := range redactVerbs {
+			out := fmt.Sprintf(verb, tt.value)
+			if strings.Contains(out, tt.secret) {
+				t.Errorf("Format %s leaked plaintext for %T: %s", verb, tt.value, out)
+			}
+
+			wrapped := fmt.Sprintf(verb, struct{ Value any }{tt.value})
+			if strings.Contains(wrapped, tt.secret) {
+				t.Errorf("Format %s leaked plaintext for struct field %T: %s", verb, tt.value, wrapped)
+			}
+
+			ptr := fmt.Sprintf(verb, []any{tt.value})
+			if strings.Contains(ptr, tt.secret) {
+				t.Errorf("Format %s leaked plaintext for slice element %T: %s", verb, tt.value, ptr)
+			}
+		}
+
+		for _, out := range []string{fmt.Sprint(tt.value), fmt.Sprintln(tt.value), tt.value.(fmt.Stringer).String(), tt.value.(fmt.GoStringer).GoString()} {
+			if strings.Contains(out, tt.secret) {
+				t.Errorf("Leaked plaintext for %T: %s", tt.value, out)
+			}
+		}
+	}
+}
+
+// Test EncryptedColumn keeps the non plaintext fields readable
+func TestRedact_EncryptedColumn(t *testing.T) {
+	ec := EncryptedColumn{K: "pt", P: "secret", I: TableColumn{T: "users", C: "email"}, V: 1, Q: "match"}
+
+	expected := "{K:pt P:[REDACTED] I:{T:users C:email} V:1 Q:match}"
+	if out := fmt.Sprintf("%v", ec); out != expected {
+		t.Errorf("Expected %s, got %s", expected, out)
+	}
+	expectedGo := `goeql.EncryptedColumn{K:"pt", P:"[REDACTED]", I:goeql.TableColumn{T:"users", C:"email"}, V:1, Q:"match"}`
+	if out := fmt.Sprintf("%#v", ec); out != expectedGo {
+		t.Errorf("Expected %s, got %s", expectedGo, out)
+	}
+}
+
+// Test Encrypted* values are redacted in log/slog
+func TestRedact_Slog(t *testing.T) {
+	var buf bytes.Buffer
+	for _, handler := range []slog.Handler{slog.NewTextHandler(&buf, nil), slog.NewJSONHandler(&buf, nil)} {
+		logger := slog.New(handler)
+		logger.Info("values",
+			"text", EncryptedText("s3cr3t-text"),
+			"jsonb", EncryptedJsonb{"card": "s3cr3t-card"},
+			"array", EncryptedJsonbArray{"s3cr3t-item"},
+			"int", EncryptedInt(4111111),
+			"bool", EncryptedBool(true),
+			"column", EncryptedColumn{K: "pt", P: "s3cr3t-column", I: TableColumn{T: "users", C: "email"}, V: 1},
+			slog.Any("any", EncryptedText("s3cr3t-any")),
+			slog.Group("group", "text", EncryptedText("s3cr3t-group")),
+		)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"s3cr3t", "4111111", "true"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("slog leaked plaintext %s: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, Redacted) || !strings.Contains(out, "users") {
+		t.Errorf("Expected redacted output with column identity, got %s", out)
+	}
+}
+
+// Test Reveal returns the plaintext
+func TestReveal(t *testing.T) {
+	if EncryptedText("secret").Reveal() != "secret" {
+		t.Errorf("Unexpected EncryptedText Reveal")
+	}
+	if (EncryptedJsonb{"a": "b"}).Reveal()["a"] != "b" {
+		t.Errorf("Unexpected EncryptedJsonb Reveal")
+	}
+	if (EncryptedJsonbArray{"a"}).Reveal()[0] != "a" {
+		t.Errorf("Unexpected EncryptedJsonbArray Reveal")
+	}
+	if EncryptedInt(42).Reveal() != 42 {
+		t.Errorf("Unexpected EncryptedInt Reveal")
+	}
+	if !EncryptedBool(true).Reveal() {
+		t.Errorf("Unexpected EncryptedBool Reveal")
+	}
+	if (EncryptedColumn{P: "secret"}).Reveal() != "secret" {
+		t.Errorf("Unexpected EncryptedColumn Reveal")
+	}
+}
+
+// Test redaction does not change serialized payloads
+func TestRedact_Serialize(t *testing.T) {
+	data, err := MatchQuery(EncryptedText("secret"), "users", "email")
+	if err != nil {
+		t.Fatalf("MatchQuery returned error: %v", err)
+	}
+
+	var ec map[string]any
+	if err := json.Unmarshal(data, &ec); err != nil {
+		t.Fatalf("Error unmarshaling serialized data: %v", err)
+	}
+	if ec["p"] != "secret" {
+		t.Errorf("Expected P to be 'secret', got '%v'", ec["p"])
+	}
+
+	str, err := convertToString(EncryptedInt(42))
+	if err != nil || str != "42" {
+		t.Errorf("Expected '42', got '%s', %v", str, err)
+	}
+}
@@ -0,0 +1,238 @@
+package goeql
+
+// A Registry declares the encrypted columns an application uses, along with
+// the cast type and indexes configured for each column in EQL.
+//
+// When a registry is installed with UseRegistry, the query helpers (MatchQuery,
+// OreQuery, UniqueQuery, JsonbQuery and EJsonPathQuery) check it and reject
+// queries that the column's configured indexes cannot serve, instead of
+// surfacing the problem as a CipherStash Proxy error.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// IndexType is an EQL index that can be configured on an encrypted column
+type IndexType string
+
+const (
+	// MatchIndex enables full text match queries
+	MatchIndex IndexType = "match"
+	// OreIndex enables range and ordering queries
+	OreIndex IndexType = "ore"
+	// UniqueIndex enables equality queries and unique constraints
+	UniqueIndex IndexType = "unique"
+	// SteVecIndex enables containment and path queries on jsonb
+	SteVecIndex IndexType = "ste_vec"
+)
+
+// CastType is the plaintext type an encrypted column is decrypted to
+type CastType string
+
+const (
+	CastText     CastType = "text"
+	CastInt      CastType = "int"
+	CastSmallInt CastType = "small_int"
+	CastBigInt   CastType = "big_int"
+	CastBoolean  CastType = "boolean"
+	CastDate     CastType = "date"
+	CastReal     CastType = "real"
+	CastDouble   CastType = "double"
+	CastJsonb    CastType = "jsonb"
+)
+
+// ErrUnknownColumn is returned when a column is not declared in the registry
+var ErrUnknownColumn = errors.New("unknown encrypted column")
+
+// ErrUnsupportedQuery is returned when a column has no index for the query type
+var ErrUnsupportedQuery = errors.New("unsupported query type for column")
+
+// queryIndexes maps each query type to the index required to serve it
+var queryIndexes = map[string]IndexType{
+	"match":      MatchIndex,
+	"ore":        OreIndex,
+	"unique":     UniqueIndex,
+	"ste_vec":    SteVecIndex,
+	"ejson_path": SteVecIndex,
+}
+
+// Column declares an encrypted column, its cast type and its enabled indexes
+type Column struct {
+	Table   string
+	Name    string
+	Cast    CastType
+	Indexes []IndexType
+	// MatchOptions configures the match index, EQL defaults are used when nil
+	MatchOptions *MatchOptions
+	// SteVec configures the ste_vec index, EQL defaults are used when nil
+	SteVec *SteVecOptions
+	// Keyset is the keyset values are encrypted under, in place of the keyset from the
+	// context, by every serializer once the registry is installed. The keyset from the
+	// context is used when nil.
+	Keyset *Keyset
+	// Version is the EQL version of the column's payloads, the context or default version is used when zero
+	Version EQLVersion
+}
+
+// HasIndex reports whether the index is enabled on the column
+func (c Column) HasIndex(index IndexType) bool {
+	for _, i := range c.Indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the column declaration is complete and uses known cast and index types
+func (c Column) Validate() error {
+	if c.Table == "" || c.Name == "" {
+		return fmt.Errorf("invalid column %q.%q: table and column name are required", c.Table, c.Name)
+	}
+	switch c.Cast {
+	case CastText, CastInt, CastSmallInt, CastBigInt, CastBoolean, CastDate, CastReal, CastDouble, CastJsonb:
+	default:
+		return fmt.Errorf("invalid column %s.%s: unknown cast type %q", c.Table, c.Name, c.Cast)
+	}
+	for _, i := range c.Indexes {
+		switch i {
+		case MatchIndex, OreIndex, UniqueIndex, SteVecIndex:
+		default:
+			return fmt.Errorf("invalid column %s.%s: unknown index type %q", c.Table, c.Name, i)
+		}
+	}
+	if c.Keyset != nil {
+		if err := c.Keyset.Validate(); err != nil {
+			return fmt.Errorf("invalid column %s.%s: %v", c.Table, c.Name, err)
+		}
+	}
+	if c.Version != 0 {
+		if err := c.Version.Validate(); err != nil {
+			return fmt.Errorf("invalid column %s.%s: %w", c.Table, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Registry is a set of declared encrypted columns, safe for concurrent use
+type Registry struct {
+	mu      sync.RWMutex
+	columns map[TableColumn]Column
+}
+
+// NewRegistry returns a registry with the given columns declared
+func NewRegistry(columns ...Column) (*Registry, error) {
+	r := &Registry{columns: make(map[TableColumn]Column)}
+	for _, c := range columns {
+		if err := r.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register declares a column, returning an error if it is invalid or already declared
+func (r *Registry) Register(c Column) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.columns == nil {
+		r.columns = make(map[TableColumn]Column)
+	}
+	key := TableColumn{T: c.Table, C: c.Name}
+	if _, ok := r.columns[key]; ok {
+		return fmt.Errorf("column %s.%s is already registered", c.Table, c.Name)
+	}
+	// Copy everything the caller could change through a shared slice or pointer
+	c.Indexes = append([]IndexType(nil), c.Indexes...)
+	if c.Keyset != nil {
+		keyset := *c.Keyset
+		c.Keyset = &keyset
+	}
+	if c.MatchOptions != nil {
+		match := *c.MatchOptions
+		match.TokenFilters = append([]TokenFilter(nil), match.TokenFilters...)
+		if match.Tokenizer != nil {
+			tokenizer := *match.Tokenizer
+			match.Tokenizer = &tokenizer
+		}
+		c.MatchOptions = &match
+	}
+	if c.SteVec != nil {
+		steVec := *c.SteVec
+		c.SteVec = &steVec
+	}
+	r.columns[key] = c
+	return nil
+}
+
+// Lookup returns the declared column for table and column
+func (r *Registry) Lookup(table string, column string) (Column, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.columns[TableColumn{T: table, C: column}]
+	return c, ok
+}
+
+// Columns returns all declared columns ordered by table then column
+func (r *Registry) Columns() []Column {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	columns := make([]Column, 0, len(r.columns))
+	for _, c := range r.columns {
+		columns = append(columns, c)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].Table != columns[j].Table {
+			return columns[i].Table < columns[j].Table
+		}
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+// CheckQuery returns an error unless the column is declared with an index that serves the query type,
+// given as a string or a QueryDescriptor
+func (r *Registry) CheckQuery(table string, column string, queryType any) error {
+	c, ok := r.Lookup(table, column)
+	if !ok {
+		return fmt.Errorf("%w: %s.%s", ErrUnknownColumn, table, column)
+	}
+
+	qt, ok := queryTypeOf(queryType)
+	if !ok {
+		return fmt.Errorf("%w: %s.%s does not support query type %v", ErrUnsupportedQuery, table, column, queryType)
+	}
+	index, ok := queryIndexes[qt]
+	if !ok || !c.HasIndex(index) {
+		return fmt.Errorf("%w: %s.%s does not support %s queries", ErrUnsupportedQuery, table, column, qt)
+	}
+	return nil
+}
+
+var activeRegistry atomic.Pointer[Registry]
+
+// UseRegistry installs r as the registry consulted by the query helpers.
+// Passing nil removes the registry, allowing queries against any column.
+func UseRegistry(r *Registry) {
+	activeRegistry.Store(r)
+}
+
+// checkRegistry checks the query against the installed registry, if any
+func checkRegistry(table string, column string, queryType any) error {
+	r := activeRegistry.Load()
+	if r == nil {
+		return nil
+	}
+	return r.CheckQuery(table, column, queryType)
+}
@@ -0,0 +1,155 @@
+package goeql
+
+import (
+	"errors"
+	"testing"
+)
+
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := NewRegistry(
+		Column{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{MatchIndex, UniqueIndex}},
+		Column{Table: "users", Name: "age", Cast: CastInt, Indexes: []IndexType{OreIndex}},
+		Column{Table: "users", Name: "attrs", Cast: CastJsonb, Indexes: []IndexType{SteVecIndex}},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	return r
+}
+
+// Test Registry column declarations
+func TestRegistry_Register(t *testing.T) {
+	r := testRegistry(t)
+
+	c, ok := r.Lookup("users", "email")
+	if !ok {
+		t.Fatalf("Expected users.email to be registered")
+	}
+	if c.Cast != CastText || !c.HasIndex(UniqueIndex) || c.HasIndex(OreIndex) {
+		t.Errorf("Unexpected column declaration: %+v", c)
+	}
+
+	if _, ok := r.Lookup("users", "missing"); ok {
+		t.Errorf("Expected users.missing to not be registered")
+	}
+
+	columns := r.Columns()
+	if len(columns) != 3 || columns[0].Name != "age" || columns[2].Name != "email" {
+		t.Errorf("Expected columns ordered by name, got %+v", columns)
+	}
+}
+
+// Test Registry keeps its own copy of a column, unaffected by later changes to the caller's value
+func TestRegistry_Register_Copies(t *testing.T) {
+	keyset := KeysetID("tenant-a")
+	c := Column{
+		Table:        "users",
+		Name:         "email",
+		Cast:         CastText,
+		Indexes:      []IndexType{MatchIndex},
+		Keyset:       &keyset,
+		MatchOptions: &MatchOptions{Tokenizer: &Tokenizer{Kind: "ngram", TokenLength: 3}, TokenFilters: []TokenFilter{{Kind: "downcase"}}},
+		SteVec:       &SteVecOptions{Prefix: "users/email"},
+	}
+	r, err := NewRegistry(c)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+
+	c.Indexes[0] = OreIndex
+	c.Keyset.ID = "tenant-b"
+	c.MatchOptions.Tokenizer.TokenLength = 5
+	c.MatchOptions.TokenFilters[0].Kind = "upcase"
+	c.SteVec.Prefix = "changed"
+
+	got, _ := r.Lookup("users", "email")
+	if got.Indexes[0] != MatchIndex {
+		t.Errorf("Expected indexes to be copied, got %v", got.Indexes)
+	}
+	if got.Keyset.ID != "tenant-a" {
+		t.Errorf("Expected the keyset to be copied, got %+v", got.Keyset)
+	}
+	if got.MatchOptions.Tokenizer.TokenLength != 3 || got.MatchOptions.TokenFilters[0].Kind != "downcase" {
+		t.Errorf("Expected match options to be copied, got %+v, %+v", got.MatchOptions.Tokenizer, got.MatchOptions.TokenFilters)
+	}
+	if got.SteVec.Prefix != "users/email" {
+		t.Errorf("Expected ste_vec options to be copied, got %+v", got.SteVec)
+	}
+}
+
+// Test Registry rejects invalid declarations
+func TestRegistry_Register_Error(t *testing.T) {
+	r := testRegistry(t)
+
+	invalid := []Column{
+		{Table: "users", Name: "email", Cast: CastText},
+		{Table: "", Name: "email", Cast: CastText},
+		{Table: "users", Name: "", Cast: CastText},
+		{Table: "users", Name: "name", Cast: "varchar"},
+		{Table: "users", Name: "name", Cast: CastText, Indexes: []IndexType{"bloom"}},
+	}
+
+	for _, c := range invalid {
+		if err := r.Register(c); err == nil {
+			t.Errorf("Expected error registering %+v, but got none", c)
+		}
+	}
+}
+
+// Test Registry query checks
+func TestRegistry_CheckQuery(t *testing.T) {
+	r := testRegistry(t)
+
+	tests := []struct {
+		column    string
+		queryType any
+		expected  error
+	}{
+		{column: "email", queryType: "match", expected: nil},
+		{column: "email", queryType: "unique", expected: nil},
+		{column: "email", queryType: "ore", expected: ErrUnsupportedQuery},
+		{column: "age", queryType: "ore", expected: nil},
+		{column: "age", queryType: "match", expected: ErrUnsupportedQuery},
+		{column: "attrs", queryType: "ste_vec", expected: nil},
+		{column: "attrs", queryType: "ejson_path", expected: nil},
+		{column: "attrs", queryType: "unknown", expected: ErrUnsupportedQuery},
+		{column: "attrs", queryType: 1, expected: ErrUnsupportedQuery},
+		{column: "missing", queryType: "match", expected: ErrUnknownColumn},
+	}
+
+	for _, tt := range tests {
+		err := r.CheckQuery("users", tt.column, tt.queryType)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("Expected error %v for %s %v query, got %v", tt.expected, tt.column, tt.queryType, err)
+		}
+	}
+}
+
+// Test query helpers consult the installed registry
+func TestUseRegistry(t *testing.T) {
+	UseRegistry(testRegistry(t))
+	defer UseRegistry(nil)
+
+	if _, err := MatchQuery("alice", "users", "email"); err != nil {
+		t.Errorf("MatchQuery returned error: %v", err)
+	}
+	if _, err := OreQuery(30, "users", "age"); err != nil {
+		t.Errorf("OreQuery returned error: %v", err)
+	}
+	if _, err := EJsonPathQuery("$.name", "users", "attrs"); err != nil {
+		t.Errorf("EJsonPathQuery returned error: %v", err)
+	}
+
+	if _, err := OreQuery("alice", "users", "email"); !errors.Is(err, ErrUnsupportedQuery) {
+		t.Errorf("Expected ErrUnsupportedQuery, got %v", err)
+	}
+	if _, err := UniqueQuery("alice", "users", "missing"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("Expected ErrUnknownColumn, got %v", err)
+	}
+
+	UseRegistry(nil)
+	if _, err := OreQuery("alice", "users", "email"); err != nil {
+		t.Errorf("Expected no error without a registry, got %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package goeql
+
+// Opt-in plaintext memory hygiene.
+//
+// Plaintext held in strings cannot be wiped, and passes through convertToString,
+// EncryptedColumn.P and encoding/json buffers that linger on the heap until they
+// are collected. The Secret* types hold their plaintext in a byte buffer that can
+// be zeroed with Wipe, serialize straight from that buffer into a payload buffer
+// that is allocated once at its final size, and decode payloads straight into a
+// buffer they own. There is one for each Encrypted* type: SecretText, SecretInt,
+// SecretBool, and SecretJsonb for both jsonb objects and arrays, which holds the
+// document as JSON text. Callers should wipe serialized payloads with WipeBytes
+// once they have been sent.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+// secretBuffer is the wipeable plaintext buffer of the Secret* types
+type secretBuffer struct {
+	b []byte
+}
+
+// Reveal returns the plaintext buffer, which is zeroed when the secret is wiped
+func (s *secretBuffer) Reveal() []byte {
+	return s.b
+}
+
+// Len returns the length of the plaintext in bytes
+func (s *secretBuffer) Len() int {
+	return len(s.b)
+}
+
+// Wipe zeroes the plaintext buffer and empties the secret
+func (s *secretBuffer) Wipe() {
+	WipeBytes(s.b)
+	s.b = nil
+}
+
+// String redacts the plaintext value
+func (s *secretBuffer) String() string { return Redacted }
+
+// LogValue redacts the plaintext value in log/slog
+func (s *secretBuffer) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+// serialize writes the plaintext into a payload without copying it to an intermediate string
+func (s *secretBuffer) serialize(ctx context.Context, table string, column string) (_ []byte, err error) {
+	if len(s.b) == 0 {
+		return nil, nil
+	}
+	call := startHooks(ctx, OperationEncrypt, table, column, "")
+	var dst []byte
+	defer func() { call.end(len(dst), err) }()
+
+	keyset, err := payloadKeyset(ctx, table, column)
+	if err != nil {
+		return nil, err
+	}
+	version, err := payloadVersion(ctx, table, column)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every byte escapes to at most 6 bytes, so sizing the buffer for the worst case
+	// ensures append never reallocates and leaves a copy of the plaintext behind
+	size := len(`{"k":"pt","p":"","i":{"t":"","c":""},"v":1,"q":null}`) + 6*(len(s.b)+len(table)+len(column))
+	if keyset != nil {
+		size += len(`,"ks":{"id":""}`) + 6*(len(keyset.ID)+len(keyset.Name))
+	}
+	dst = make([]byte, 0, size)
+	dst = append(dst, `{"k":"pt","p":`...)
+	dst = appendJSONString(dst, s.b)
+	dst = appendEnvelopeSuffix(dst, table, column, "", keyset, version)
+	return dst, nil
+}
+
+// deserialize decodes a payload into the buffer, wiping any previous plaintext, and
+// checks the plaintext with valid before keeping it
+func (s *secretBuffer) deserialize(ctx context.Context, data []byte, valid func(p []byte) error) (err error) {
+	s.Wipe()
+	if len(data) == 0 {
+		return nil
+	}
+	call := startHooks(ctx, OperationDecrypt, "", "", "")
+	defer func() { call.end(len(data), err) }()
+
+	// Unescaped plaintext is never longer than the payload, so the scratch buffer never
+	// reallocates and leaves a copy of the plaintext behind
+	d := Decoder{scratch: make([]byte, 0, len(data))}
+	defer d.Wipe()
+
+	h, err := d.scanCall(ctx, call, data)
+	if err != nil {
+		return err
+	}
+	if valid != nil {
+		if err := valid(h.p); err != nil {
+			return err
+		}
+	}
+	s.b = make([]byte, len(h.p))
+	copy(s.b, h.p)
+	return nil
+}
+
+// SecretText is a wipeable text value to be encrypted. The zero value is an empty secret.
+//
+// SecretText must not be copied after first use, as copies share the plaintext buffer.
+type SecretText struct {
+	secretBuffer
+}
+
+// NewSecretText returns a SecretText holding plaintext. The secret takes ownership
+// of the buffer, which is zeroed when the secret is wiped.
+func NewSecretText(plaintext []byte) *SecretText {
+	return &SecretText{secretBuffer{b: plaintext}}
+}
+
+// Serialize turns a SecretText value into a jsonb payload for CipherStash Proxy without
+// copying the plaintext to an intermediate string. The payload holds the plaintext and
+// should be wiped with WipeBytes once it has been sent.
+func (s *SecretText) Serialize(table string, column string) ([]byte, error) {
+	return s.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext is Serialize using the keyset from ctx
+func (s *SecretText) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
+	return s.serialize(ctx, table, column)
+}
+
+// Deserialize decodes a jsonb payload from CipherStash Proxy into the secret, wiping any
+// previous plaintext. The payload holds the plaintext and should be wiped by the caller.
+func (s *SecretText) Deserialize(data []byte) error {
+	return s.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext is Deserialize checking the installed policy for the principal in ctx
+func (s *SecretText) DeserializeContext(ctx context.Context, data []byte) error {
+	return s.deserialize(ctx, data, nil)
+}
+
+// GoString redacts the plaintext value
+func (s *SecretText) GoString() string { return "goeql.SecretText(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (s *SecretText) Format(f fmt.State, verb rune) { formatRedacted(f, verb, s.GoString()) }
+
+// SecretInt is a wipeable integer value to be encrypted, held as its decimal digits.
+// The zero value is an empty secret, which serializes to nil like a NULL column.
+//
+// SecretInt must not be copied after first use, as copies share the plaintext buffer.
+type SecretInt struct {
+	secretBuffer
+}
+
+// NewSecretInt returns a SecretInt holding n
+func NewSecretInt(n int64) *SecretInt {
+	return &SecretInt{secretBuffer{b: strconv.AppendInt(make([]byte, 0, 20), n, 10)}}
+}
+
+// Int returns the plaintext value
+func (s *SecretInt) Int() (int64, error) {
+	n, ok := parseIntBytes(s.b)
+	if !ok {
+		return 0, fmt.Errorf("invalid number format in secret")
+	}
+	return n, nil
+}
+
+// Serialize turns a SecretInt value into a jsonb payload for CipherStash Proxy, like SecretText.Serialize
+func (s *SecretInt) Serialize(table string, column string) ([]byte, error) {
+	return s.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext is Serialize using the keyset from ctx
+func (s *SecretInt) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
+	return s.serialize(ctx, table, column)
+}
+
+// Deserialize decodes a jsonb payload from CipherStash Proxy into the secret, like SecretText.Deserialize
+func (s *SecretInt) Deserialize(data []byte) error {
+	return s.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext is Deserialize checking the installed policy for the principal in ctx
+func (s *SecretInt) DeserializeContext(ctx context.Context, data []byte) error {
+	return s.deserialize(ctx, data, func(p []byte) error {
+		if _, ok := parseIntBytes(p); !ok {
+			return fmt.Errorf("invalid number format in 'p' field")
+		}
+		return nil
+	})
+}
+
+// GoString redacts the plaintext value
+func (s *SecretInt) GoString() string { return "goeql.SecretInt(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (s *SecretInt) Format(f fmt.State, verb rune) { formatRedacted(f, verb, s.GoString()) }
+
+// SecretBool is a wipeable boolean value to be encrypted, held as "true" or "false".
+// The zero value is an empty secret. Like EncryptedBool, empty and false secrets
+// serialize to nil, which is stored as NULL.
+//
+// SecretBool must not be copied after first use, as copies share the plaintext buffer.
+type SecretBool struct {
+	secretBuffer
+}
+
+// NewSecretBool returns a SecretBool holding v
+func NewSecretBool(v bool) *SecretBool {
+	return &SecretBool{secretBuffer{b: strconv.AppendBool(make([]byte, 0, 5), v)}}
+}
+
+// Bool returns the plaintext value
+func (s *SecretBool) Bool() (bool, error) {
+	h := header{p: s.b}
+	v, err := h.bool()
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean format in secret")
+	}
+	return bool(v), nil
+}
+
+// Serialize turns a SecretBool value into a jsonb payload for CipherStash Proxy, like SecretText.Serialize
+func (s *SecretBool) Serialize(table string, column string) ([]byte, error) {
+	return s.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext is Serialize using the keyset from ctx
+func (s *SecretBool) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
+	if string(s.b) == "false" {
+		return nil, nil
+	}
+	return s.serialize(ctx, table, column)
+}
+
+// Deserialize decodes a jsonb payload from CipherStash Proxy into the secret, like SecretText.Deserialize
+func (s *SecretBool) Deserialize(data []byte) error {
+	return s.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext is Deserialize checking the installed policy for the principal in ctx
+func (s *SecretBool) DeserializeContext(ctx context.Context, data []byte) error {
+	return s.deserialize(ctx, data, func(p []byte) error {
+		h := header{p: p}
+		_, err := h.bool()
+		return err
+	})
+}
+
+// GoString redacts the plaintext value
+func (s *SecretBool) GoString() string { return "goeql.SecretBool(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (s *SecretBool) Format(f fmt.State, verb rune) { formatRedacted(f, verb, s.GoString()) }
+
+// SecretJsonb is a wipeable jsonb document to be encrypted, a JSON object or array held
+// as JSON text, for EncryptedJsonb and EncryptedJsonbArray columns. The zero value is an
+// empty secret. Like EncryptedJsonb and EncryptedJsonbArray, empty secrets and empty
+// documents, {} and [], serialize to nil, which is stored as NULL. Decoding the document with encoding/json copies its plaintext, so read it
+// with Reveal where it must stay wipeable.
+//
+// SecretJsonb must not be copied after first use, as copies share the plaintext buffer.
+type SecretJsonb struct {
+	secretBuffer
+}
+
+// NewSecretJsonb returns a SecretJsonb holding the JSON document doc. The secret takes
+// ownership of the buffer, which is zeroed when the secret is wiped.
+func NewSecretJsonb(doc []byte) *SecretJsonb {
+	return &SecretJsonb{secretBuffer{b: doc}}
+}
+
+// Serialize turns a SecretJsonb value into a jsonb payload for CipherStash Proxy, like
+// SecretText.Serialize, returning an error if the document is not valid JSON
+func (s *SecretJsonb) Serialize(table string, column string) ([]byte, error) {
+	return s.SerializeContext(context.Background(), table, column)
+}
+
+// SerializeContext is Serialize using the keyset from ctx
+func (s *SecretJsonb) SerializeContext(ctx context.Context, table string, column string) ([]byte, error) {
+	if len(s.b) > 0 && !isJSONDocument(s.b) {
+		return nil, fmt.Errorf("error serializing: secret is not a valid JSON document")
+	}
+	if isEmptyJSONDocument(s.b) {
+		return nil, nil
+	}
+	return s.serialize(ctx, table, column)
+}
+
+// Deserialize decodes a jsonb payload from CipherStash Proxy into the secret, like SecretText.Deserialize
+func (s *SecretJsonb) Deserialize(data []byte) error {
+	return s.DeserializeContext(context.Background(), data)
+}
+
+// DeserializeContext is Deserialize checking the installed policy for the principal in ctx
+func (s *SecretJsonb) DeserializeContext(ctx context.Context, data []byte) error {
+	return s.deserialize(ctx, data, func(p []byte) error {
+		if !isJSONDocument(p) {
+			return fmt.Errorf("invalid format: 'p' field is not a JSON document")
+		}
+		return nil
+	})
+}
+
+// isJSONDocument reports whether b is a valid JSON object or array
+func isJSONDocument(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{', '[':
+			return json.Valid(b)
+		}
+		return false
+	}
+	return false
+}
+
+// isEmptyJSONDocument reports whether the valid JSON document b is {} or [], ignoring whitespace
+func isEmptyJSONDocument(b []byte) bool {
+	n := 0
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		if n++; n > 2 {
+			return false
+		}
+	}
+	return n == 2
+}
+
+// GoString redacts the plaintext value
+func (s *SecretJsonb) GoString() string { return "goeql.SecretJsonb(" + Redacted + ")" }
+
+// Format redacts the plaintext value for every fmt verb
+func (s *SecretJsonb) Format(f fmt.State, verb rune) { formatRedacted(f, verb, s.GoString()) }
+
+// Wipe zeroes the decoder's scratch buffer, which may hold plaintext from the last payload decoded
+func (d *Decoder) Wipe() {
+	WipeBytes(d.scratch[:cap(d.scratch)])
+	d.scratch = nil
+}
+
+// WipeBytes zeroes b, e.g. a serialized payload once it has been sent
+func WipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
@@ -0,0 +1,234 @@
+package goeql
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func isZeroed(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Test SecretText serializes the same payload as EncryptedText
+func TestSecretText_Serialize(t *testing.T) {
+	for _, plaintext := range []string{"card 4111-1111-1111-1111", `<"quoted"> & \ escaped` + "\n\x00", "unicode \u4f60\u597d \u2028 \xff"} {
+		expected, err := EncryptedText(plaintext).Serialize("payments", "card_number")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+
+		secret := NewSecretText([]byte(plaintext))
+		data, err := secret.Serialize("payments", "card_number")
+		if err != nil {
+			t.Fatalf("Serialize returned error: %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected %s, got %s", expected, data)
+		}
+	}
+
+	empty, err := (&SecretText{}).Serialize("payments", "card_number")
+	if err != nil || empty != nil {
+		t.Errorf("Expected empty secret to serialize to nil, got %s, %v", empty, err)
+	}
+}
+
+// Test SecretText Serialize allocates only the payload buffer
+func TestSecretText_Serialize_Allocs(t *testing.T) {
+	secret := NewSecretText([]byte(strings.Repeat("<secret>\n", 100)))
+	allocs := testing.AllocsPerRun(100, func() {
+		data, _ := secret.Serialize("payments", "card_number")
+		WipeBytes(data)
+	})
+	if allocs != 1 {
+		t.Errorf("Expected a single allocation, got %v", allocs)
+	}
+}
+
+// Test SecretText Wipe zeroes the plaintext buffer
+func TestSecretText_Wipe(t *testing.T) {
+	buf := []byte("4111-1111-1111-1111")
+	secret := NewSecretText(buf)
+
+	data, err := secret.Serialize("payments", "card_number")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+
+	secret.Wipe()
+	WipeBytes(data)
+
+	if !isZeroed(buf) {
+		t.Errorf("Expected plaintext buffer to be zeroed, got %q", buf)
+	}
+	if !isZeroed(data) {
+		t.Errorf("Expected payload buffer to be zeroed, got %q", data)
+	}
+	if secret.Len() != 0 || secret.Reveal() != nil {
+		t.Errorf("Expected wiped secret to be empty")
+	}
+}
+
+// Test SecretText Deserialize decodes into a buffer it owns
+func TestSecretText_Deserialize(t *testing.T) {
+	data, err := EncryptedText("line\nbreak \"quoted\"").Serialize("payments", "card_number")
+	if err != nil {
+		t.Fatalf("Serialize returned error: %v", err)
+	}
+
+	previous := []byte("previous")
+	secret := NewSecretText(previous)
+	if err := secret.Deserialize(data); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+
+	if string(secret.Reveal()) != "line\nbreak \"quoted\"" {
+		t.Errorf("Unexpected plaintext %q", secret.Reveal())
+	}
+	if !isZeroed(previous) {
+		t.Errorf("Expected previous plaintext to be wiped, got %q", previous)
+	}
+
+	decoded := secret.Reveal()
+	WipeBytes(data)
+	if string(decoded) != "line\nbreak \"quoted\"" {
+		t.Errorf("Expected secret to own its buffer, got %q after wiping the payload", decoded)
+	}
+
+	secret.Wipe()
+	if !isZeroed(decoded) {
+		t.Errorf("Expected decoded plaintext to be zeroed, got %q", decoded)
+	}
+
+	if err := secret.Deserialize([]byte(`{"p":"missing fields"}`)); err == nil {
+		t.Errorf("Expected error decoding invalid payload, but got none")
+	}
+}
+
+// Test Decoder Wipe zeroes the scratch buffer
+func TestDecoder_Wipe(t *testing.T) {
+	var d Decoder
+	data, _ := EncryptedText("escaped\nsecret").Serialize("payments", "card_number")
+	if _, err := d.Text(data); err != nil {
+		t.Fatalf("Text returned error: %v", err)
+	}
+
+	scratch := d.scratch[:cap(d.scratch)]
+	if !bytes.Contains(scratch, []byte("secret")) {
+		t.Fatalf("Expected scratch buffer to hold the unescaped plaintext")
+	}
+	d.Wipe()
+	if !isZeroed(scratch) {
+		t.Errorf("Expected scratch buffer to be zeroed, got %q", scratch)
+	}
+}
+
+// Test SecretText is redacted
+func TestSecretText_Redact(t *testing.T) {
+	secret := NewSecretText([]byte("s3cr3t"))
+	for _, verb := range redactVerbs {
+		if out := fmt.Sprintf(verb, secret); strings.Contains(out, "s3cr3t") {
+			t.Errorf("Format %s leaked plaintext: %s", verb, out)
+		}
+	}
+}
+
+// Test SecretInt, SecretBool and SecretJsonb serialize the same payloads as their Encrypted* types
+func TestSecrets_Serialize(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret interface {
+			Serialize(string, string) ([]byte, error)
+		}
+		expected func() ([]byte, error)
+	}{
+		{"int", NewSecretInt(-42), func() ([]byte, error) { return EncryptedInt(-42).Serialize("users", "age") }},
+		{"bool", NewSecretBool(true), func() ([]byte, error) { return EncryptedBool(true).Serialize("users", "age") }},
+		{"jsonb", NewSecretJsonb([]byte(`{"plan":"pro"}`)), func() ([]byte, error) { return EncryptedJsonb{"plan": "pro"}.Serialize("users", "age") }},
+		{"jsonb array", NewSecretJsonb([]byte(`["a",1]`)), func() ([]byte, error) { return EncryptedJsonbArray{"a", 1}.Serialize("users", "age") }},
+		{"int zero", NewSecretInt(0), func() ([]byte, error) { return EncryptedInt(0).Serialize("users", "age") }},
+		{"bool false", NewSecretBool(false), func() ([]byte, error) { return EncryptedBool(false).Serialize("users", "age") }},
+		{"jsonb empty", NewSecretJsonb([]byte(`{}`)), func() ([]byte, error) { return EncryptedJsonb{}.Serialize("users", "age") }},
+		{"jsonb empty spaced", NewSecretJsonb([]byte(` { } `)), func() ([]byte, error) { return EncryptedJsonb{}.Serialize("users", "age") }},
+		{"jsonb empty array", NewSecretJsonb([]byte(`[]`)), func() ([]byte, error) { return EncryptedJsonbArray{}.Serialize("users", "age") }},
+	}
+	for _, tt := range tests {
+		expected, err := tt.expected()
+		if err != nil {
+			t.Fatalf("%s: Serialize returned error: %v", tt.name, err)
+		}
+		data, err := tt.secret.Serialize("users", "age")
+		if err != nil || !bytes.Equal(data, expected) {
+			t.Errorf("%s: expected %s, got %s, %v", tt.name, expected, data, err)
+		}
+	}
+
+	if _, err := NewSecretJsonb([]byte(`"scalar"`)).Serialize("users", "attrs"); err == nil {
+		t.Errorf("Expected error serializing a jsonb secret that is not a document")
+	}
+	if data, err := (&SecretInt{}).Serialize("users", "age"); data != nil || err != nil {
+		t.Errorf("Expected empty secret to serialize to nil, got %s, %v", data, err)
+	}
+}
+
+// Test SecretInt, SecretBool and SecretJsonb decode and validate payloads into buffers they own
+func TestSecrets_Deserialize(t *testing.T) {
+	age, _ := EncryptedInt(30).Serialize("users", "age")
+	active, _ := EncryptedBool(true).Serialize("users", "active")
+	attrs, _ := EncryptedJsonb{"plan": "pro"}.Serialize("users", "attrs")
+	text, _ := EncryptedText("thirty").Serialize("users", "age")
+
+	var i SecretInt
+	if err := i.Deserialize(age); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	if n, err := i.Int(); err != nil || n != 30 {
+		t.Errorf("Expected 30, got %d, %v", n, err)
+	}
+	if err := i.Deserialize(text); err == nil || i.Len() != 0 {
+		t.Errorf("Expected error decoding text into a SecretInt, got %v with %d bytes kept", err, i.Len())
+	}
+
+	var b SecretBool
+	if err := b.Deserialize(active); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	if v, err := b.Bool(); err != nil || !v {
+		t.Errorf("Expected true, got %v, %v", v, err)
+	}
+	if err := b.Deserialize(text); err == nil {
+		t.Errorf("Expected error decoding text into a SecretBool")
+	}
+
+	var j SecretJsonb
+	if err := j.Deserialize(attrs); err != nil {
+		t.Fatalf("Deserialize returned error: %v", err)
+	}
+	decoded := j.Reveal()
+	if string(decoded) != `{"plan":"pro"}` {
+		t.Errorf("Unexpected document %s", decoded)
+	}
+	j.Wipe()
+	if !isZeroed(decoded) {
+		t.Errorf("Expected decoded document to be zeroed, got %q", decoded)
+	}
+	if err := j.Deserialize(text); err == nil {
+		t.Errorf("Expected error decoding text into a SecretJsonb")
+	}
+
+	secrets := []any{NewSecretInt(31337), NewSecretBool(true), NewSecretJsonb([]byte(`{"s3cr3t":1}`))}
+	for _, secret := range secrets {
+		for _, verb := range redactVerbs {
+			if out := fmt.Sprintf(verb, secret); strings.Contains(out, "31337") || strings.Contains(out, "true") || strings.Contains(out, "s3cr3t") {
+				t.Errorf("Format %s leaked plaintext: %s", verb, out)
+			}
+		}
+	}
+}
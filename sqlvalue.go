@@ -0,0 +1,105 @@
+package goeql
+
+// database/sql support for EQL columns.
+//
+// SQLValue implements driver.Valuer and sql.Scanner for an EQL column whose
+// table and column identity is fixed by its column type parameter, so query
+// code generated by tools such as sqlc can accept and return plaintext values
+// that are encoded and decoded through goeql. goeql gen generates an accessor
+// type and an SQLValue alias for each column in a schema.
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// SQLColumn identifies the EQL column of an SQLValue. SQLValue calls EQLColumn on the
+// zero value of the type, so the identity must not depend on the value's fields. The
+// accessor types generated by goeql gen implement it, returning the identity from
+// constants while their goeql.Column field serves the Encrypt, Decode and query methods.
+type SQLColumn interface {
+	EQLColumn() TableColumn
+}
+
+// Plaintext is the set of Encrypted* types an SQLValue can hold
+type Plaintext interface {
+	EncryptedText | EncryptedInt | EncryptedBool | EncryptedJsonb | EncryptedJsonbArray
+}
+
+// SQLValue is a nullable plaintext stored in the EQL column identified by C
+type SQLValue[T Plaintext, C SQLColumn] struct {
+	Plaintext T
+	// Valid is false for NULL
+	Valid bool
+}
+
+// Value serializes the plaintext into an EQL payload, or returns nil for NULL.
+// Unlike Serialize, zero values such as false and "" are stored rather than treated as NULL.
+func (v SQLValue[T, C]) Value() (driver.Value, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+	var c C
+	id := c.EQLColumn()
+	data, err := encryptValue(context.Background(), any(v.Plaintext).(plaintexter).plaintext(), id.T, id.C)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes an EQL payload, returning an error if it belongs to another column
+func (v *SQLValue[T, C]) Scan(src any) (err error) {
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		*v = SQLValue[T, C]{}
+		return nil
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		return fmt.Errorf("cannot scan %T into an EQL value", src)
+	}
+
+	var c C
+	id := c.EQLColumn()
+	call := startHooks(context.Background(), OperationDecrypt, id.T, id.C, "")
+	defer func() { call.end(len(data), err) }()
+
+	var d Decoder
+	h, err := d.scanCall(context.Background(), call, data)
+	if err != nil {
+		return err
+	}
+	if string(h.t) != id.T || string(h.c) != id.C {
+		return fmt.Errorf("payload for %s.%s does not belong to column %s.%s", h.t, h.c, id.T, id.C)
+	}
+
+	var plaintext any
+	switch any(v.Plaintext).(type) {
+	case EncryptedText:
+		plaintext = EncryptedText(h.p)
+	case EncryptedInt:
+		plaintext, err = h.int()
+	case EncryptedBool:
+		plaintext, err = h.bool()
+	case EncryptedJsonb:
+		var p map[string]any
+		err = json.Unmarshal(h.p, &p)
+		plaintext = EncryptedJsonb(p)
+	case EncryptedJsonbArray:
+		var p []any
+		err = json.Unmarshal(h.p, &p)
+		plaintext = EncryptedJsonbArray(p)
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding %s.%s: %v", id.T, id.C, err)
+	}
+
+	*v = SQLValue[T, C]{Plaintext: plaintext.(T), Valid: true}
+	return nil
+}
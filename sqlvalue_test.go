@@ -0,0 +1,112 @@
+package goeql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type usersEmail struct{}
+
+func (usersEmail) EQLColumn() TableColumn { return TableColumn{T: "users", C: "email"} }
+
+type usersActive struct{}
+
+func (usersActive) EQLColumn() TableColumn { return TableColumn{T: "users", C: "active"} }
+
+var (
+	_ driver.Valuer = SQLValue[EncryptedText, usersEmail]{}
+	_ sql.Scanner   = &SQLValue[EncryptedText, usersEmail]{}
+)
+
+// roundTrip stores v with Value and reads it back with Scan
+func roundTrip[T Plaintext, C SQLColumn](t *testing.T, v SQLValue[T, C]) SQLValue[T, C] {
+	t.Helper()
+	value, err := v.Value()
+	if err != nil {
+		t.Fatalf("Value returned error: %v", err)
+	}
+	if !driver.IsValue(value) {
+		t.Fatalf("Value returned %T, which is not a driver.Value", value)
+	}
+	var got SQLValue[T, C]
+	if err := got.Scan(value); err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+	return got
+}
+
+// Test SQLValue round trips each plaintext type
+func TestSQLValue_RoundTrip(t *testing.T) {
+	text := SQLValue[EncryptedText, usersEmail]{Plaintext: "alice@example.com", Valid: true}
+	if got := roundTrip(t, text); got != text {
+		t.Errorf("Expected %v, got %v", text.Plaintext.Reveal(), got.Plaintext.Reveal())
+	}
+
+	// false is stored, unlike EncryptedBool.Serialize which treats it as NULL
+	active := SQLValue[EncryptedBool, usersActive]{Plaintext: false, Valid: true}
+	if got := roundTrip(t, active); got != active {
+		t.Errorf("Expected a valid false, got %+v", got)
+	}
+
+	count := SQLValue[EncryptedInt, usersEmail]{Plaintext: -42, Valid: true}
+	if got := roundTrip(t, count); got != count {
+		t.Errorf("Expected -42, got %d", got.Plaintext.Reveal())
+	}
+
+	attrs := SQLValue[EncryptedJsonb, usersEmail]{Plaintext: EncryptedJsonb{"plan": "pro"}, Valid: true}
+	if got := roundTrip(t, attrs); !reflect.DeepEqual(got, attrs) {
+		t.Errorf("Expected %v, got %v", attrs.Plaintext.Reveal(), got.Plaintext.Reveal())
+	}
+}
+
+// Test SQLValue stores and scans NULL
+func TestSQLValue_Null(t *testing.T) {
+	value, err := SQLValue[EncryptedText, usersEmail]{}.Value()
+	if err != nil || value != nil {
+		t.Errorf("Expected NULL, got %v, %v", value, err)
+	}
+
+	v := SQLValue[EncryptedText, usersEmail]{Plaintext: "alice", Valid: true}
+	if err := v.Scan(nil); err != nil || v.Valid || v.Plaintext != "" {
+		t.Errorf("Expected NULL to reset the value, got %+v, %v", v, err)
+	}
+}
+
+// Test SQLValue serializes the column identity and rejects payloads for other columns
+func TestSQLValue_Identity(t *testing.T) {
+	value, err := SQLValue[EncryptedText, usersEmail]{Plaintext: "alice", Valid: true}.Value()
+	if err != nil {
+		t.Fatalf("Value returned error: %v", err)
+	}
+	expected := `{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null}`
+	if value != expected {
+		t.Errorf("Expected %s, got %v", expected, value)
+	}
+
+	var active SQLValue[EncryptedText, usersActive]
+	if err := active.Scan([]byte(expected)); err == nil {
+		t.Errorf("Expected error scanning a users.email payload into users.active")
+	}
+	if err := active.Scan(42); err == nil {
+		t.Errorf("Expected error scanning an int")
+	}
+}
+
+// Test SQLValue.Scan checks the installed policy
+func TestSQLValue_Policy(t *testing.T) {
+	UsePolicy(&Policy{Principal: "app", Rules: []PolicyRule{{Table: "users", Column: "email", Effect: Allow, Actions: []PolicyAction{ActionDecode}}}})
+	defer UsePolicy(nil)
+
+	var email SQLValue[EncryptedText, usersEmail]
+	if err := email.Scan(`{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":1,"q":null}`); err != nil {
+		t.Errorf("Expected users.email to be allowed, got %v", err)
+	}
+	var active SQLValue[EncryptedText, usersActive]
+	err := active.Scan(`{"k":"pt","p":"alice","i":{"t":"users","c":"active"},"v":1,"q":null}`)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected permission denied, got %v", err)
+	}
+}
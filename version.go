@@ -0,0 +1,170 @@
+package goeql
+
+// EQL versions and the payload shape and SQL names of each.
+//
+// EQL v1 payloads carry "v":1 and a "q" field that is null for stored values,
+// and v1 installs its functions as cs_*_v1. EQL v2 payloads carry "v":2 and
+// only include "q" in query payloads, and v2 installs its functions in the
+// eql_v2 schema. Payloads are produced for v1 unless another version is chosen
+// with UseEQLVersion, Column.Version or WithEQLVersion, while every supported
+// version decodes, so a database can move between EQL versions a column at a
+// time instead of in a single cut over.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// EQLVersion is a release of EQL, selecting the payload shape and SQL function names
+type EQLVersion int
+
+const (
+	// EQLv1 is the cs_*_v1 release of EQL, and the default
+	EQLv1 EQLVersion = 1
+	// EQLv2 is the eql_v2 release of EQL
+	EQLv2 EQLVersion = 2
+)
+
+// ErrUnsupportedVersion is returned for EQL versions goeql cannot produce or decode
+var ErrUnsupportedVersion = errors.New("unsupported EQL version")
+
+// Validate returns an error unless goeql supports the version
+func (v EQLVersion) Validate() error {
+	if _, ok := versionSQL[v]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, int(v))
+	}
+	return nil
+}
+
+// sqlNames holds the SQL objects of an EQL version. The ste_vec fields are format
+// strings taking the column and the query or path parameter.
+type sqlNames struct {
+	addColumn      string
+	addIndex       string
+	encrypt        string
+	activate       string
+	configuration  string
+	steVecContains string
+	steVecValue    string
+	steVecTerms    string
+}
+
+var versionSQL = map[EQLVersion]sqlNames{
+	EQLv1: {
+		addColumn:      "cs_add_column_v1",
+		addIndex:       "cs_add_index_v1",
+		encrypt:        "cs_encrypt_v1",
+		activate:       "cs_activate_v1",
+		configuration:  "cs_configuration_v1",
+		steVecContains: "cs_ste_vec_v1(%s) @> cs_ste_vec_v1(%s)",
+		steVecValue:    "cs_ste_vec_value_v1(%s, %s)",
+		steVecTerms:    "cs_ste_vec_terms_v1(%s, %s)",
+	},
+	EQLv2: {
+		addColumn:      "eql_v2.add_column",
+		addIndex:       "eql_v2.add_search_config",
+		encrypt:        "eql_v2.migrate_config",
+		activate:       "eql_v2.activate_config",
+		configuration:  "public.eql_v2_configuration",
+		steVecContains: "eql_v2.ste_vec_contains(%s, %s)",
+		steVecValue:    "eql_v2.jsonb_path_query_first(%s, %s)",
+		steVecTerms:    "eql_v2.jsonb_path_query(%s, %s)",
+	},
+}
+
+// sql returns the SQL names of the version, falling back to v1 for unsupported versions
+func (v EQLVersion) sql() sqlNames {
+	if names, ok := versionSQL[v]; ok {
+		return names
+	}
+	return versionSQL[EQLv1]
+}
+
+// SteVecContainsSQL renders the containment operator for a ste_vec query in the version
+func (v EQLVersion) SteVecContainsSQL(column string, param string) string {
+	return fmt.Sprintf(v.sql().steVecContains, column, param)
+}
+
+// SteVecValueSQL renders the function extracting the value at an ejson_path in the version
+func (v EQLVersion) SteVecValueSQL(column string, pathParam string) string {
+	return fmt.Sprintf(v.sql().steVecValue, column, pathParam)
+}
+
+// SteVecTermsSQL renders the function extracting the terms of an array at an ejson_path in the version
+func (v EQLVersion) SteVecTermsSQL(column string, pathParam string) string {
+	return fmt.Sprintf(v.sql().steVecTerms, column, pathParam)
+}
+
+var activeVersion atomic.Int32
+
+// UseEQLVersion sets the EQL version payloads and SQL are produced for when neither the
+// context nor the column chooses one. Passing 0 restores the default, EQLv1.
+func UseEQLVersion(v EQLVersion) error {
+	if v != 0 {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	activeVersion.Store(int32(v))
+	return nil
+}
+
+// DefaultEQLVersion returns the version set with UseEQLVersion, or EQLv1
+func DefaultEQLVersion() EQLVersion {
+	if v := EQLVersion(activeVersion.Load()); v != 0 {
+		return v
+	}
+	return EQLv1
+}
+
+type versionContextKey struct{}
+
+// WithEQLVersion returns a copy of ctx producing payloads for the EQL version, overriding
+// the version of the column in the installed registry and the default version
+func WithEQLVersion(ctx context.Context, v EQLVersion) context.Context {
+	return context.WithValue(ctx, versionContextKey{}, v)
+}
+
+// EQLVersionFromContext returns the EQL version carried by ctx, if any
+func EQLVersionFromContext(ctx context.Context) (EQLVersion, bool) {
+	v, ok := ctx.Value(versionContextKey{}).(EQLVersion)
+	return v, ok
+}
+
+// payloadVersion returns the version of payloads serialized for the column with ctx
+func payloadVersion(ctx context.Context, table string, column string) (EQLVersion, error) {
+	if v, ok := EQLVersionFromContext(ctx); ok {
+		if err := v.Validate(); err != nil {
+			return 0, err
+		}
+		return v, nil
+	}
+	return columnVersion(table, column), nil
+}
+
+// columnVersion returns the version of the column in the installed registry, or the default version
+func columnVersion(table string, column string) EQLVersion {
+	if r := activeRegistry.Load(); r != nil {
+		if c, ok := r.Lookup(table, column); ok && c.Version != 0 {
+			return c.Version
+		}
+	}
+	return DefaultEQLVersion()
+}
+
+// MarshalJSON encodes the payload in the shape of its version, omitting a null "q"
+// from payloads newer than v1
+func (ec EncryptedColumn) MarshalJSON() ([]byte, error) {
+	// envelope has the fields of EncryptedColumn without its methods
+	type envelope EncryptedColumn
+	if ec.V <= int(EQLv1) || ec.Q != nil {
+		return json.Marshal(envelope(ec))
+	}
+	return json.Marshal(struct {
+		envelope
+		Q any `json:"q,omitempty"`
+	}{envelope: envelope(ec)})
+}
@@ -0,0 +1,185 @@
+package goeql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useVersion sets the default EQL version for the duration of the test
+func useVersion(t *testing.T, v EQLVersion) {
+	t.Helper()
+	if err := UseEQLVersion(v); err != nil {
+		t.Fatalf("UseEQLVersion returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = UseEQLVersion(0) })
+}
+
+// Test EQLVersion validation
+func TestEQLVersion_Validate(t *testing.T) {
+	for _, v := range []EQLVersion{EQLv1, EQLv2} {
+		if err := v.Validate(); err != nil {
+			t.Errorf("Expected version %d to be valid, got %v", v, err)
+		}
+	}
+	for _, v := range []EQLVersion{0, 3, -1} {
+		if err := v.Validate(); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("Expected ErrUnsupportedVersion for version %d, got %v", v, err)
+		}
+	}
+	if err := UseEQLVersion(3); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected UseEQLVersion to reject version 3, got %v", err)
+	}
+	if v := DefaultEQLVersion(); v != EQLv1 {
+		t.Errorf("Expected default version 1, got %d", v)
+	}
+}
+
+// Test every serializer produces the v2 envelope once v2 is the default
+func TestUseEQLVersion(t *testing.T) {
+	useVersion(t, EQLv2)
+
+	stored := `{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":2}`
+	query := `{"k":"pt","p":"alice","i":{"t":"users","c":"email"},"v":2,"q":"unique"}`
+
+	serialized, err := EncryptedText("alice").Serialize("users", "email")
+	if err != nil || string(serialized) != stored {
+		t.Errorf("Serialize: expected %s, got %s, %v", stored, serialized, err)
+	}
+	encrypted, err := NewColumn("users", "email").Encrypt("alice")
+	if err != nil || string(encrypted) != stored {
+		t.Errorf("Column.Encrypt: expected %s, got %s, %v", stored, encrypted, err)
+	}
+	secret, err := NewSecretText([]byte("alice")).Serialize("users", "email")
+	if err != nil || string(secret) != stored {
+		t.Errorf("SecretText.Serialize: expected %s, got %s, %v", stored, secret, err)
+	}
+	batch, err := EncodeBatch("users", "email", []string{"alice"})
+	if err != nil || string(batch[0]) != stored {
+		t.Errorf("EncodeBatch: expected %s, got %s, %v", stored, batch[0], err)
+	}
+	if appended := AppendEncrypted(nil, "alice", "users", "email", ""); string(appended) != stored {
+		t.Errorf("AppendEncrypted: expected %s, got %s", stored, appended)
+	}
+	q, err := UniqueQuery("alice", "users", "email")
+	if err != nil || string(q) != query {
+		t.Errorf("UniqueQuery: expected %s, got %s, %v", query, q, err)
+	}
+
+	ec, err := ToEncryptedColumn("alice", "users", "email", nil)
+	if err != nil {
+		t.Fatalf("ToEncryptedColumn returned error: %v", err)
+	}
+	marshaled, err := json.Marshal(ec)
+	if err != nil || string(marshaled) != stored {
+		t.Errorf("ToEncryptedColumn: expected %s, got %s, %v", stored, marshaled, err)
+	}
+}
+
+// Test the context version overrides the registry and default versions, and Column methods use their own version
+func TestEQLVersion_Precedence(t *testing.T) {
+	r, err := NewRegistry(
+		Column{Table: "users", Name: "email", Cast: CastText, Version: EQLv2},
+		Column{Table: "users", Name: "name", Cast: CastText},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	UseRegistry(r)
+	defer UseRegistry(nil)
+
+	tests := []struct {
+		ctx      context.Context
+		column   string
+		expected int
+	}{
+		{ctx: context.Background(), column: "email", expected: 2},
+		{ctx: context.Background(), column: "name", expected: 1},
+		{ctx: WithEQLVersion(context.Background(), EQLv1), column: "email", expected: 1},
+		{ctx: WithEQLVersion(context.Background(), EQLv2), column: "name", expected: 2},
+	}
+	for _, tt := range tests {
+		data, err := EncryptedText("alice").SerializeContext(tt.ctx, "users", tt.column)
+		if err != nil {
+			t.Fatalf("SerializeContext returned error: %v", err)
+		}
+		payload, err := DecodePayload(data)
+		if err != nil || payload.V != tt.expected {
+			t.Errorf("users.%s: expected version %d, got %+v, %v", tt.column, tt.expected, payload, err)
+		}
+	}
+
+	column := Column{Table: "users", Name: "email", Cast: CastText, Version: EQLv1}
+	data, err := column.EncryptContext(WithEQLVersion(context.Background(), EQLv2), "alice")
+	if err != nil || !strings.Contains(string(data), `"v":1`) {
+		t.Errorf("Expected the column version to override the context, got %s, %v", data, err)
+	}
+
+	if _, err := EncryptedText("alice").SerializeContext(WithEQLVersion(context.Background(), 3), "users", "email"); err == nil || !strings.Contains(err.Error(), ErrUnsupportedVersion.Error()) {
+		t.Errorf("Expected ErrUnsupportedVersion from the context version, got %v", err)
+	}
+	if err := (Column{Table: "users", Name: "email", Cast: CastText, Version: 3}).Validate(); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion from Column.Validate, got %v", err)
+	}
+}
+
+// Test payloads of every version decode regardless of the version produced
+func TestEQLVersion_Decode(t *testing.T) {
+	useVersion(t, EQLv2)
+
+	payloads := []string{
+		`{"k":"pt","p":"42","i":{"t":"users","c":"age"},"v":1,"q":null}`,
+		`{"k":"pt","p":"42","i":{"t":"users","c":"age"},"v":2}`,
+	}
+	for _, p := range payloads {
+		var ei EncryptedInt
+		if got, err := ei.Deserialize([]byte(p)); err != nil || got != 42 {
+			t.Errorf("Deserialize %s: got %d, %v", p, got, err)
+		}
+		var d Decoder
+		if got, err := d.Int([]byte(p)); err != nil || got != 42 {
+			t.Errorf("Decoder %s: got %d, %v", p, got, err)
+		}
+	}
+}
+
+// Test the SQL helpers render the names of each version
+func TestEQLVersion_SQL(t *testing.T) {
+	if sql := EQLv2.SteVecContainsSQL("attrs", "$1"); sql != "eql_v2.ste_vec_contains(attrs, $1)" {
+		t.Errorf("Unexpected containment SQL: %s", sql)
+	}
+	if sql := EQLv2.SteVecValueSQL("attrs", "$2"); sql != "eql_v2.jsonb_path_query_first(attrs, $2)" {
+		t.Errorf("Unexpected value SQL: %s", sql)
+	}
+	if sql := EQLv2.SteVecTermsSQL("attrs", "$3"); sql != "eql_v2.jsonb_path_query(attrs, $3)" {
+		t.Errorf("Unexpected terms SQL: %s", sql)
+	}
+
+	useVersion(t, EQLv2)
+	if sql := SteVecContainsSQL("attrs", "$1"); sql != "eql_v2.ste_vec_contains(attrs, $1)" {
+		t.Errorf("Expected SteVecContainsSQL to follow the default version, got %s", sql)
+	}
+
+	sql, err := ConfigSQL(Column{Table: "users", Name: "email", Cast: CastText, Indexes: []IndexType{UniqueIndex}})
+	if err != nil {
+		t.Fatalf("ConfigSQL returned error: %v", err)
+	}
+	expected := `-- EQL configuration generated by goeql
+
+SELECT eql_v2.add_column('users', 'email', 'text')
+  WHERE NOT EXISTS (SELECT 1 FROM public.eql_v2_configuration WHERE state IN ('pending', 'active') AND data->'tables'->'users' ? 'email');
+SELECT eql_v2.add_search_config('users', 'email', 'unique', 'text', '{}')
+  WHERE NOT EXISTS (SELECT 1 FROM public.eql_v2_configuration WHERE state IN ('pending', 'active') AND data->'tables'->'users'->'email'->'indexes' ? 'unique');
+
+SELECT eql_v2.migrate_config() WHERE EXISTS (SELECT 1 FROM public.eql_v2_configuration WHERE state = 'pending');
+SELECT eql_v2.activate_config() WHERE EXISTS (SELECT 1 FROM public.eql_v2_configuration WHERE state = 'encrypting');
+`
+	if sql != expected {
+		t.Errorf("Unexpected v2 configuration SQL:\n%s", sql)
+	}
+	if _, err := EQLVersion(3).ConfigSQL(); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion from ConfigSQL, got %v", err)
+	}
+}
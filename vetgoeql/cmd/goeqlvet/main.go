@@ -0,0 +1,22 @@
+// Command goeqlvet reports SQL comparing encrypted columns with plaintext arguments.
+//
+// Usage:
+//
+//	goeqlvet -schema schema.yaml ./...
+//
+// or as a go vet tool:
+//
+//	go vet -vettool=$(which goeqlvet) -schema=$PWD/schema.yaml ./...
+//
+// The schema declares the encrypted columns in the EQL configuration format, as
+// JSON or YAML, as read by goeql gen. See package vetgoeql for the checks.
+package main
+
+import (
+	"github.com/cipherstash/goeql/vetgoeql"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(vetgoeql.Analyzer)
+}
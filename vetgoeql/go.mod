@@ -0,0 +1,15 @@
+module github.com/cipherstash/goeql/vetgoeql
+
+go 1.22.0
+
+require (
+	github.com/cipherstash/goeql v0.0.0-20261018205100-6b6248e27672
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,33 @@
+package a
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cipherstash/goeql"
+)
+
+func queries(ctx context.Context, db *sql.DB, email string, age int) {
+	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", email)                               // want `plaintext email compared with encrypted column users.email, use goeql.UniqueQuery`
+	db.QueryRowContext(ctx, "SELECT id FROM users WHERE age >= $1", age)                               // want `plaintext age compared with encrypted column users.age, use goeql.OreQuery`
+	db.Query("SELECT id FROM users WHERE email LIKE ?", "%alice%")                                     // want `plaintext "%alice%" compared with encrypted column users.email, use goeql.MatchQuery`
+	db.Exec("DELETE FROM users u WHERE $2 < u.age AND u.id = $1", 1, 30)                               // want `plaintext 30 compared with encrypted column users.age, use goeql.OreQuery`
+	db.Query("SELECT * FROM orders WHERE cs_ste_vec_v1(attrs) @> cs_ste_vec_v1($1::jsonb)", `{"a":1}`) // want `plaintext .* compared with encrypted column orders.attrs, use goeql.JsonbQuery`
+	db.Query(`SELECT id FROM "users" WHERE "email" = ?`, goeql.EncryptedText(email))                   // want `plaintext goeql.EncryptedText\(email\) compared with encrypted column users.email`
+}
+
+func helpers(ctx context.Context, db *sql.DB, email string, age int) {
+	query, _ := goeql.UniqueQuery(email, "users", "email")
+	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", query)
+	ore, _ := goeql.OreQuery(age, "users", "age")
+	db.QueryContext(ctx, "SELECT id FROM users WHERE age > $1 AND name = $2", ore, "alice")
+	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", goeql.SQLValue{})
+}
+
+func notComparisons(ctx context.Context, db *sql.DB, email string, id int) {
+	db.ExecContext(ctx, "UPDATE users SET email = $1 WHERE id = $2", email, id)
+	db.ExecContext(ctx, "INSERT INTO users (email) VALUES ($1)", email)
+	db.QueryContext(ctx, "SELECT id FROM accounts WHERE email = $1", email)
+	args := []any{email}
+	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", args...)
+}
@@ -0,0 +1,16 @@
+// Package goeql is a stub of the goeql types used by the analyzer tests
+package goeql
+
+import "database/sql/driver"
+
+type EncryptedText string
+
+type EncryptedInt int
+
+type SQLValue struct{}
+
+func (SQLValue) Value() (driver.Value, error) { return nil, nil }
+
+func UniqueQuery(value any, table string, column string) ([]byte, error) { return nil, nil }
+
+func OreQuery(value any, table string, column string) ([]byte, error) { return nil, nil }
@@ -0,0 +1,318 @@
+// Package vetgoeql provides a go/analysis analyzer that reports SQL comparing an
+// encrypted column against a plaintext argument instead of a goeql query.
+//
+// The analyzer inspects constant SQL strings passed to variadic query functions
+// such as (*sql.DB).QueryContext, finds comparisons between a placeholder and a
+// column declared in the schema, and reports the argument bound to the
+// placeholder when it is a plaintext string, number or bool rather than the
+// []byte returned by MatchQuery, OreQuery, UniqueQuery or JsonbQuery:
+//
+//	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", email) // reported
+//
+//	query, err := goeql.UniqueQuery(email, "users", "email")
+//	db.QueryContext(ctx, "SELECT id FROM users WHERE email = $1", query) // ok
+//
+// Analyzer reads the columns from the schema file given by its -schema flag, in
+// the format read by goeql.ReadConfigFile. NewAnalyzer checks a list of columns
+// instead, such as the columns of a goeql.Registry.
+package vetgoeql
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cipherstash/goeql"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `report plaintext comparisons against encrypted columns
+
+Encrypted columns can only be compared with an EQL query payload built by the
+goeql query helpers. Comparing one with a plaintext argument, as in
+"WHERE email = $1" bound to a string, bypasses the encrypted index.`
+
+// Analyzer checks the columns declared in the schema file given by the -schema flag
+var Analyzer = schemaAnalyzer()
+
+func schemaAnalyzer() *analysis.Analyzer {
+	c := &checker{}
+	a := c.analyzer()
+	a.Flags.StringVar(&c.schema, "schema", "", "EQL schema file declaring the encrypted columns, as JSON or YAML")
+	return a
+}
+
+// NewAnalyzer returns an analyzer checking the given columns
+func NewAnalyzer(columns ...goeql.Column) *analysis.Analyzer {
+	c := &checker{}
+	c.once.Do(func() { c.tables = tablesOf(columns) })
+	return c.analyzer()
+}
+
+// checker holds the encrypted columns by table and column name, loading them from
+// the schema file the first time a package is checked
+type checker struct {
+	schema string
+	once   sync.Once
+	tables map[string]map[string]goeql.Column
+	err    error
+}
+
+func (c *checker) analyzer() *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     "eqlplaintext",
+		Doc:      doc,
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+		Run:      c.run,
+	}
+}
+
+func (c *checker) load() (map[string]map[string]goeql.Column, error) {
+	c.once.Do(func() {
+		if c.schema == "" {
+			c.err = errors.New("no schema file, set the -schema flag")
+			return
+		}
+		cfg, err := goeql.ReadConfigFile(c.schema)
+		if err != nil {
+			c.err = fmt.Errorf("error reading schema: %v", err)
+			return
+		}
+		columns, err := cfg.Columns()
+		if err != nil {
+			c.err = fmt.Errorf("error reading schema: %v", err)
+			return
+		}
+		c.tables = tablesOf(columns)
+	})
+	return c.tables, c.err
+}
+
+func tablesOf(columns []goeql.Column) map[string]map[string]goeql.Column {
+	tables := make(map[string]map[string]goeql.Column)
+	for _, col := range columns {
+		if tables[col.Table] == nil {
+			tables[col.Table] = make(map[string]goeql.Column)
+		}
+		tables[col.Table][col.Name] = col
+	}
+	return tables
+}
+
+func (c *checker) run(pass *analysis.Pass) (any, error) {
+	tables, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		query, args, ok := sqlCall(pass.TypesInfo, n.(*ast.CallExpr))
+		if !ok {
+			return
+		}
+		reported := make(map[int]bool)
+		for _, cmp := range comparisons(query, tables) {
+			if cmp.arg >= len(args) || reported[cmp.arg] {
+				continue
+			}
+			arg := args[cmp.arg]
+			if !isPlaintext(pass.TypesInfo.TypeOf(arg)) {
+				continue
+			}
+			reported[cmp.arg] = true
+			pass.Reportf(arg.Pos(), "plaintext %s compared with encrypted column %s.%s, use goeql.%s",
+				types.ExprString(arg), cmp.column.Table, cmp.column.Name, cmp.helper)
+		}
+	})
+	return nil, nil
+}
+
+// sqlKeyword is matched by the SQL strings worth checking
+var sqlKeyword = regexp.MustCompile(`(?i)\b(select|update|delete|where)\b`)
+
+// sqlCall returns the constant SQL string and the arguments bound to its placeholders
+// for calls to variadic functions whose last fixed parameter is a string, such as
+// QueryContext(ctx, query, args...)
+func sqlCall(info *types.Info, call *ast.CallExpr) (string, []ast.Expr, bool) {
+	if call.Ellipsis.IsValid() {
+		return "", nil, false
+	}
+	t := info.TypeOf(call.Fun)
+	if t == nil {
+		return "", nil, false
+	}
+	sig, ok := t.Underlying().(*types.Signature)
+	if !ok || !sig.Variadic() || sig.Params().Len() < 2 {
+		return "", nil, false
+	}
+	i := sig.Params().Len() - 2
+	if i >= len(call.Args) {
+		return "", nil, false
+	}
+	tv := info.Types[call.Args[i]]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", nil, false
+	}
+	query := constant.StringVal(tv.Value)
+	if !sqlKeyword.MatchString(query) {
+		return "", nil, false
+	}
+	return query, call.Args[i+1:], true
+}
+
+// isPlaintext reports whether values of type t are bound as plaintext, excluding
+// types implementing driver.Valuer such as goeql.SQLValue
+func isPlaintext(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsString|types.IsNumeric|types.IsBoolean) == 0 {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Value")
+	_, isMethod := obj.(*types.Func)
+	return !isMethod
+}
+
+const (
+	ident       = `(?:"[^"]+"|[A-Za-z_][A-Za-z0-9_]*)`
+	columnRef   = `(?:[A-Za-z_][A-Za-z0-9_]*\()?(` + ident + `(?:\.` + ident + `)?)\)?`
+	operator    = `(<>|!=|<=|>=|=|<@|@>|<|>|!~~\*?|~~\*?|(?i:not\s+)?(?i:i?like))`
+	placeholder = `(?:[A-Za-z_][A-Za-z0-9_]*\()?(\$\d+|\?)(?:::[A-Za-z_][A-Za-z0-9_]*)?\)?`
+)
+
+var (
+	columnFirst      = regexp.MustCompile(columnRef + `\s*` + operator + `\s*` + placeholder)
+	placeholderFirst = regexp.MustCompile(placeholder + `\s*` + operator + `\s*` + columnRef)
+	clauseKeyword    = regexp.MustCompile(`(?i)\b(select|from|where|on|having|set|values|returning|order|group|limit)\b`)
+	tableRef         = regexp.MustCompile(`(?i)\b(?:from|join|update)\s+(` + ident + `(?:\.` + ident + `)?)(?:\s+(?:as\s+)?(` + ident + `))?`)
+)
+
+// comparison is a comparison between an encrypted column and a placeholder
+type comparison struct {
+	column goeql.Column
+	// arg is the index of the argument bound to the placeholder
+	arg    int
+	helper string
+}
+
+// comparisons finds the comparisons in query between placeholders and encrypted columns
+// of the tables the query reads or updates
+func comparisons(query string, tables map[string]map[string]goeql.Column) []comparison {
+	aliases := make(map[string]string)
+	var mentioned []string
+	for _, m := range tableRef.FindAllStringSubmatch(query, -1) {
+		table := unqualified(m[1])
+		if _, ok := tables[table]; !ok {
+			continue
+		}
+		mentioned = append(mentioned, table)
+		aliases[table] = table
+		if alias := normalize(m[2]); alias != "" {
+			aliases[alias] = table
+		}
+	}
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	lookup := func(ref string) (goeql.Column, bool) {
+		qualifier, name, ok := strings.Cut(ref, ".")
+		if !ok {
+			name = qualifier
+			for _, table := range mentioned {
+				if col, ok := tables[table][normalize(name)]; ok {
+					return col, true
+				}
+			}
+			return goeql.Column{}, false
+		}
+		table, ok := aliases[normalize(qualifier)]
+		if !ok {
+			return goeql.Column{}, false
+		}
+		col, ok := tables[table][normalize(name)]
+		return col, ok
+	}
+
+	clauses := clauseKeyword.FindAllStringIndex(query, -1)
+	var cmps []comparison
+	add := func(ref string, op string, ph string, pos int) {
+		if !inCondition(query, clauses, pos) {
+			return
+		}
+		col, ok := lookup(ref)
+		if !ok {
+			return
+		}
+		arg := strings.Count(query[:pos], "?")
+		if ph != "?" {
+			n, err := strconv.Atoi(ph[1:])
+			if err != nil || n < 1 {
+				return
+			}
+			arg = n - 1
+		}
+		cmps = append(cmps, comparison{column: col, arg: arg, helper: helperFor(op)})
+	}
+	for _, m := range columnFirst.FindAllStringSubmatchIndex(query, -1) {
+		add(query[m[2]:m[3]], query[m[4]:m[5]], query[m[6]:m[7]], m[6])
+	}
+	for _, m := range placeholderFirst.FindAllStringSubmatchIndex(query, -1) {
+		add(query[m[6]:m[7]], query[m[4]:m[5]], query[m[2]:m[3]], m[2])
+	}
+	return cmps
+}
+
+// inCondition reports whether pos is in a WHERE, ON or HAVING clause rather than
+// e.g. the SET clause of an UPDATE, given the positions of the clause keywords
+func inCondition(query string, clauses [][]int, pos int) bool {
+	clause := ""
+	for _, c := range clauses {
+		if c[0] > pos {
+			break
+		}
+		clause = strings.ToLower(query[c[0]:c[1]])
+	}
+	return clause == "where" || clause == "on" || clause == "having"
+}
+
+// helperFor returns the goeql query helper serving a comparison operator
+func helperFor(op string) string {
+	switch op {
+	case "=", "<>", "!=":
+		return "UniqueQuery"
+	case "<", "<=", ">", ">=":
+		return "OreQuery"
+	case "@>", "<@":
+		return "JsonbQuery"
+	default:
+		return "MatchQuery"
+	}
+}
+
+// unqualified returns the normalized table name without its schema
+func unqualified(ref string) string {
+	if i := strings.LastIndex(ref, "."); i >= 0 {
+		ref = ref[i+1:]
+	}
+	return normalize(ref)
+}
+
+// normalize unquotes a quoted identifier and folds an unquoted one to lower case, as Postgres does
+func normalize(ident string) string {
+	if len(ident) >= 2 && ident[0] == '"' && ident[len(ident)-1] == '"' {
+		return ident[1 : len(ident)-1]
+	}
+	return strings.ToLower(ident)
+}
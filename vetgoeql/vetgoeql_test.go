@@ -0,0 +1,55 @@
+package vetgoeql
+
+import (
+	"testing"
+
+	"github.com/cipherstash/goeql"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// Test the analyzer reports plaintext comparisons against the columns in the schema file
+func TestAnalyzer(t *testing.T) {
+	if err := Analyzer.Flags.Set("schema", "testdata/schema.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+// Test NewAnalyzer checks declared columns, such as those of a registry
+func TestNewAnalyzer(t *testing.T) {
+	r, err := goeql.NewRegistry(
+		goeql.Column{Table: "users", Name: "email", Cast: goeql.CastText, Indexes: []goeql.IndexType{goeql.UniqueIndex, goeql.MatchIndex}},
+		goeql.Column{Table: "users", Name: "age", Cast: goeql.CastInt, Indexes: []goeql.IndexType{goeql.OreIndex}},
+		goeql.Column{Table: "orders", Name: "attrs", Cast: goeql.CastJsonb, Indexes: []goeql.IndexType{goeql.SteVecIndex}},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry returned error: %v", err)
+	}
+	analysistest.Run(t, analysistest.TestData(), NewAnalyzer(r.Columns()...), "a")
+}
+
+// Test comparisons resolves aliases and placeholders
+func TestComparisons(t *testing.T) {
+	tables := tablesOf([]goeql.Column{
+		{Table: "users", Name: "email", Cast: goeql.CastText},
+		{Table: "orders", Name: "email", Cast: goeql.CastText},
+	})
+
+	cmps := comparisons(`SELECT o.id FROM public.orders AS o JOIN users u ON u.email = $2 WHERE o.email <> $1`, tables)
+	if len(cmps) != 2 {
+		t.Fatalf("Expected 2 comparisons, got %+v", cmps)
+	}
+	if cmps[0].column.Table != "users" || cmps[0].arg != 1 || cmps[0].helper != "UniqueQuery" {
+		t.Errorf("Expected users.email bound to $2, got %+v", cmps[0])
+	}
+	if cmps[1].column.Table != "orders" || cmps[1].arg != 0 {
+		t.Errorf("Expected orders.email bound to $1, got %+v", cmps[1])
+	}
+
+	if cmps := comparisons(`SELECT id FROM users WHERE id = ? OR email NOT ILIKE ?`, tables); len(cmps) != 1 || cmps[0].arg != 1 || cmps[0].helper != "MatchQuery" {
+		t.Errorf("Expected a match comparison bound to the second ?, got %+v", cmps)
+	}
+	if cmps := comparisons(`UPDATE users SET email = $1 WHERE id = $2`, tables); len(cmps) != 0 {
+		t.Errorf("Expected no comparisons in a SET clause, got %+v", cmps)
+	}
+}